@@ -10,11 +10,15 @@ import (
 var Config *ConfigStruct
 
 type ConfigStruct struct {
-	Server ServerConfig `yaml:"server"`
-	MySQL  MySQLConfig  `yaml:"mysql"`
-	Redis  RedisConfig  `yaml:"redis"`
-	JWT    JWTConfig    `yaml:"jwt"`
-	S3     S3           `yaml:"s3"`
+	Server    ServerConfig    `yaml:"server"`
+	MySQL     MySQLConfig     `yaml:"mysql"`
+	Redis     RedisConfig     `yaml:"redis"`
+	JWT       JWTConfig       `yaml:"jwt"`
+	S3        S3              `yaml:"s3"`
+	Search    SearchConfig    `yaml:"search"`
+	Recommend RecommendConfig `yaml:"recommend"`
+	AI        AIConfig        `yaml:"ai"`
+	Jobs      JobsConfig      `yaml:"jobs"`
 }
 
 func LoadConfig(configPath string) {
@@ -19,8 +19,9 @@ type RedisConfig struct {
 }
 
 type JWTConfig struct {
-	Secret string `yaml:"secret"`
-	Expire int    `yaml:"expire"`
+	Secret        string `yaml:"secret"`
+	Expire        int    `yaml:"expire"`
+	RefreshExpire int    `yaml:"refresh_expire"`
 }
 
 type S3 struct {
@@ -29,3 +30,65 @@ type S3 struct {
 	BucketName      string `yaml:"bucket_name"`
 	Endpoint        string `yaml:"endpoint"`
 }
+
+type SearchConfig struct {
+	// 检索索引文件路径，为空时索引只保存在内存中，每次启动重建
+	IndexPath string `yaml:"index_path"`
+}
+
+type RecommendConfig struct {
+	// 个性化推荐中内容相似度、协同过滤和流行度三种信号的混合权重，全部为0时使用默认值
+	ContentWeight       float64 `yaml:"content_weight"`
+	CollaborativeWeight float64 `yaml:"collaborative_weight"`
+	PopularityWeight    float64 `yaml:"popularity_weight"`
+	// 协同过滤模型的缓存时间（秒），为0时使用默认值
+	CollaborativeTTL int `yaml:"collaborative_ttl"`
+	// MMR 多样化中相关度的权重，取值 (0, 1]，1 表示不做多样化，为0时使用默认值
+	DiversityLambda float64 `yaml:"diversity_lambda"`
+	// 计算TF-IDF使用的分词器：bigram（默认，二元切分）或 dictionary（按词典分词，需要配置 dictionary_path）
+	Tokenizer string `yaml:"tokenizer"`
+	// 词典文件路径，每行第一列为词，兼容 jieba 的词典格式
+	DictionaryPath string `yaml:"dictionary_path"`
+	// 停用词文件路径，每行一个词，与内置的中英文停用词合并
+	StopWordsPath string `yaml:"stop_words_path"`
+}
+
+type AIConfig struct {
+	// 使用的大语言模型服务：openai、ollama 或 fake（测试用），为空时不启用摘要功能
+	Provider string       `yaml:"provider"`
+	OpenAI   OpenAIConfig `yaml:"openai"`
+	Ollama   OllamaConfig `yaml:"ollama"`
+	// 摘要提示词模板目录，其中的 <语言>.yaml 覆盖同一语言的内置模板，为空时只使用内置模板
+	PromptsDir string `yaml:"prompts_dir"`
+}
+
+type OpenAIConfig struct {
+	// OpenAI 兼容接口的地址，为空时使用 OpenAI 官方地址
+	BaseURL string `yaml:"base_url"`
+	Model   string `yaml:"model"`
+	// 为空时读取环境变量 OPENAI_API_KEY
+	APIKey string `yaml:"api_key"`
+	// 请求超时时间（秒），为0时默认60秒
+	Timeout int `yaml:"timeout"`
+	// 模型的上下文窗口（token 数），为0时默认4096
+	ContextWindow int `yaml:"context_window"`
+}
+
+type OllamaConfig struct {
+	// Ollama 服务地址，为空时使用 http://localhost:11434
+	BaseURL string `yaml:"base_url"`
+	Model   string `yaml:"model"`
+	// 请求超时时间（秒），为0时默认60秒
+	Timeout int `yaml:"timeout"`
+	// 模型的上下文窗口（token 数），为0时默认4096
+	ContextWindow int `yaml:"context_window"`
+}
+
+type JobsConfig struct {
+	// 执行后台任务的 worker 数量，为0时默认2
+	Workers int `yaml:"workers"`
+	// 每个任务最多执行的次数，为0时默认3
+	MaxAttempts int `yaml:"max_attempts"`
+	// 执行中任务的租约时长（秒），服务崩溃后租约过期的任务会重新执行，为0时默认60秒
+	Lease int `yaml:"lease"`
+}
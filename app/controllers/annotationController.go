@@ -0,0 +1,306 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sd0ric4/book-reader-backend/app/database"
+	middlewares "github.com/sd0ric4/book-reader-backend/app/middleewares"
+	"github.com/sd0ric4/book-reader-backend/app/models"
+	"github.com/sd0ric4/book-reader-backend/app/services"
+)
+
+// loadBookChapter 加载请求中的章节，章节必须属于这本书
+func loadBookChapter(c *gin.Context, book *models.Book, chapterID uint) (*models.BookChapter, bool) {
+	chapter, err := models.GetChapterByID(database.MySQLDB, chapterID)
+	if err != nil || chapter.BookID != book.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Chapter does not belong to this book"})
+		return nil, false
+	}
+	return chapter, true
+}
+
+// parseAnnotationID 解析路径中的书签或高亮ID
+func parseAnnotationID(c *gin.Context) (uint, bool) {
+	uintID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return 0, false
+	}
+	return uint(uintID), true
+}
+
+// GetBookAnnotations 返回当前用户在某本书中的书签和高亮，返回前按当前章节内容重新定位
+func GetBookAnnotations(c *gin.Context) {
+	book, ok := loadVisibleBook(c)
+	if !ok {
+		return
+	}
+
+	user, _ := middlewares.CurrentUser(c)
+	annotations, err := services.ReanchorBookAnnotations(database.MySQLDB, user.ID, book.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to fetch annotations"})
+		return
+	}
+	c.JSON(http.StatusOK, annotations)
+}
+
+func CreateBookmark(c *gin.Context) {
+	book, ok := loadVisibleBook(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreateBookmarkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	chapter, ok := loadBookChapter(c, book, req.ChapterID)
+	if !ok {
+		return
+	}
+
+	anchor, err := services.NewTextAnchor(chapter, req.Offset, req.Offset, req.CFI)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Offset is outside the chapter"})
+		return
+	}
+
+	user, _ := middlewares.CurrentUser(c)
+	bookmark := &models.Bookmark{UserID: user.ID, BookID: book.ID, TextAnchor: anchor, Label: req.Label}
+	if err := models.CreateBookmark(database.MySQLDB, bookmark); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create bookmark"})
+		return
+	}
+	c.JSON(http.StatusOK, bookmark)
+}
+
+// loadOwnBookmark 加载属于当前用户的书签，失败时写入错误响应
+func loadOwnBookmark(c *gin.Context) (*models.Bookmark, bool) {
+	id, ok := parseAnnotationID(c)
+	if !ok {
+		return nil, false
+	}
+
+	user, _ := middlewares.CurrentUser(c)
+	bookmark, err := models.GetBookmarkByID(database.MySQLDB, id)
+	if err != nil || bookmark.UserID != user.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bookmark not found"})
+		return nil, false
+	}
+	return bookmark, true
+}
+
+func GetBookmark(c *gin.Context) {
+	bookmark, ok := loadOwnBookmark(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, bookmark)
+}
+
+func UpdateBookmark(c *gin.Context) {
+	bookmark, ok := loadOwnBookmark(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateBookmarkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Label != nil {
+		bookmark.Label = *req.Label
+	}
+
+	if err := models.UpdateBookmark(database.MySQLDB, bookmark); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update bookmark"})
+		return
+	}
+	c.JSON(http.StatusOK, bookmark)
+}
+
+func DeleteBookmark(c *gin.Context) {
+	bookmark, ok := loadOwnBookmark(c)
+	if !ok {
+		return
+	}
+
+	if err := models.DeleteBookmark(database.MySQLDB, bookmark.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete bookmark"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Bookmark deleted successfully"})
+}
+
+func CreateHighlight(c *gin.Context) {
+	book, ok := loadVisibleBook(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreateHighlightRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Color == "" {
+		req.Color = models.HighlightYellow
+	}
+	if !models.IsValidHighlightColor(req.Color) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid color"})
+		return
+	}
+	if req.EndOffset <= req.StartOffset {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end_offset must be greater than start_offset"})
+		return
+	}
+	chapter, ok := loadBookChapter(c, book, req.ChapterID)
+	if !ok {
+		return
+	}
+
+	anchor, err := services.NewTextAnchor(chapter, req.StartOffset, req.EndOffset, req.CFI)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Offsets are outside the chapter"})
+		return
+	}
+
+	user, _ := middlewares.CurrentUser(c)
+	highlight := &models.Highlight{
+		UserID:     user.ID,
+		BookID:     book.ID,
+		TextAnchor: anchor,
+		Color:      req.Color,
+		Label:      req.Label,
+		Note:       req.Note,
+	}
+	if err := models.CreateHighlight(database.MySQLDB, highlight); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create highlight"})
+		return
+	}
+	c.JSON(http.StatusOK, highlight)
+}
+
+// loadOwnHighlight 加载属于当前用户的高亮，失败时写入错误响应
+func loadOwnHighlight(c *gin.Context) (*models.Highlight, bool) {
+	id, ok := parseAnnotationID(c)
+	if !ok {
+		return nil, false
+	}
+
+	user, _ := middlewares.CurrentUser(c)
+	highlight, err := models.GetHighlightByID(database.MySQLDB, id)
+	if err != nil || highlight.UserID != user.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Highlight not found"})
+		return nil, false
+	}
+	return highlight, true
+}
+
+func GetHighlight(c *gin.Context) {
+	highlight, ok := loadOwnHighlight(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, highlight)
+}
+
+func UpdateHighlight(c *gin.Context) {
+	highlight, ok := loadOwnHighlight(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateHighlightRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Color != nil {
+		if !models.IsValidHighlightColor(*req.Color) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid color"})
+			return
+		}
+		highlight.Color = *req.Color
+	}
+	if req.Label != nil {
+		highlight.Label = *req.Label
+	}
+	if req.Note != nil {
+		highlight.Note = *req.Note
+	}
+
+	if err := models.UpdateHighlight(database.MySQLDB, highlight); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update highlight"})
+		return
+	}
+	c.JSON(http.StatusOK, highlight)
+}
+
+func DeleteHighlight(c *gin.Context) {
+	highlight, ok := loadOwnHighlight(c)
+	if !ok {
+		return
+	}
+
+	if err := models.DeleteHighlight(database.MySQLDB, highlight.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete highlight"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Highlight deleted successfully"})
+}
+
+// exportFormat 读取并校验导出格式，默认 Markdown
+func exportFormat(c *gin.Context) (string, bool) {
+	format := c.DefaultQuery("format", services.ExportFormatMarkdown)
+	if !services.IsValidExportFormat(format) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid export format"})
+		return "", false
+	}
+	return format, true
+}
+
+// streamAnnotationExport 以附件形式流式写出导出内容，响应头发出后无法再返回错误码
+func streamAnnotationExport(c *gin.Context, format, fileName string, books []models.Book) {
+	user, _ := middlewares.CurrentUser(c)
+	c.Header("Content-Type", services.ExportContentType(format))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, services.ExportFileName(fileName, format)))
+	c.Status(http.StatusOK)
+
+	if err := services.ExportAnnotations(database.MySQLDB, c.Writer, format, user.ID, books); err != nil {
+		c.Error(err)
+	}
+}
+
+func ExportBookAnnotations(c *gin.Context) {
+	book, ok := loadVisibleBook(c)
+	if !ok {
+		return
+	}
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+	streamAnnotationExport(c, format, fmt.Sprintf("book-%d-highlights", book.ID), []models.Book{*book})
+}
+
+func ExportUserAnnotations(c *gin.Context) {
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+
+	user, _ := middlewares.CurrentUser(c)
+	books, err := models.GetHighlightedBooks(database.MySQLDB, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to fetch books"})
+		return
+	}
+	streamAnnotationExport(c, format, "highlights", books)
+}
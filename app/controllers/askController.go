@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sd0ric4/book-reader-backend/app/database"
+	middlewares "github.com/sd0ric4/book-reader-backend/app/middleewares"
+	"github.com/sd0ric4/book-reader-backend/app/services"
+)
+
+// 问题的最大长度（字符数）
+const maxQuestionLength = 500
+
+type askRequest struct {
+	Question string `json:"question" binding:"required"`
+}
+
+// AskBook 根据读者已读的章节回答关于书籍的问题，返回答案和引用的段落。
+// 只检索阅读进度之前的内容，避免剧透。
+func AskBook(c *gin.Context) {
+	book, ok := loadVisibleBook(c)
+	if !ok {
+		return
+	}
+
+	var req askRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	question := strings.TrimSpace(req.Question)
+	if question == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Question is required"})
+		return
+	}
+	if utf8.RuneCountInString(question) > maxQuestionLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Question is too long"})
+		return
+	}
+	if services.LLM == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Question answering is not configured"})
+		return
+	}
+
+	user, _ := middlewares.CurrentUser(c)
+	answer, err := services.AskBook(c.Request.Context(), database.MySQLDB, services.LLM, book, user.ID, question)
+	if errors.Is(err, services.ErrNoPassages) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No relevant passages found in the chapters you have read"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to answer question"})
+		return
+	}
+	c.JSON(http.StatusOK, answer)
+}
@@ -1,29 +1,118 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sd0ric4/book-reader-backend/app/config"
 	"github.com/sd0ric4/book-reader-backend/app/database"
+	middlewares "github.com/sd0ric4/book-reader-backend/app/middleewares"
 	"github.com/sd0ric4/book-reader-backend/app/models"
 	"github.com/sd0ric4/book-reader-backend/app/services"
+	"github.com/sd0ric4/book-reader-backend/app/services/prompts"
 	"github.com/sd0ric4/book-reader-backend/app/utils"
+	"gorm.io/gorm"
 )
 
-func GetBooks(c *gin.Context) {
-	books, err := models.GetBooks(database.MySQLDB)
+// parseBookListQuery 解析书籍列表的查询参数，defaultSort 为未指定排序字段时使用的排序，
+// 除通用的排序字段外也可以显式指定
+func parseBookListQuery(c *gin.Context, defaultSort string) (models.BookListQuery, error) {
+	query := models.BookListQuery{
+		Author: c.Query("author"),
+		Tag:    c.Query("tag"),
+		Format: c.Query("format"),
+		Sort:   c.Query("sort"),
+		Cursor: c.Query("cursor"),
+	}
+
+	if query.Sort == "" {
+		query.Sort = defaultSort
+	}
+	if query.Sort != defaultSort && !models.IsValidBookSort(query.Sort) {
+		return query, fmt.Errorf("invalid sort field: %s", query.Sort)
+	}
+	switch order := c.Query("order"); order {
+	case "":
+		// 书名、作者和书架内顺序默认正序，时间和评分默认倒序
+		query.Desc = query.Sort != models.BookSortTitle && query.Sort != models.BookSortAuthor && query.Sort != models.BookSortPosition
+	case "asc", "desc":
+		query.Desc = order == "desc"
+	default:
+		return query, fmt.Errorf("invalid order: %s", order)
+	}
+
+	var err error
+	if page := c.Query("page"); page != "" {
+		if query.Page, err = strconv.Atoi(page); err != nil || query.Page <= 0 {
+			return query, fmt.Errorf("invalid page: %s", page)
+		}
+	}
+	if pageSize := c.Query("page_size"); pageSize != "" {
+		if query.PageSize, err = strconv.Atoi(pageSize); err != nil || query.PageSize <= 0 || query.PageSize > models.MaxBookPageSize {
+			return query, fmt.Errorf("page_size must be between 1 and %d", models.MaxBookPageSize)
+		}
+	}
+	if query.Page > 0 && query.Cursor != "" {
+		return query, fmt.Errorf("page and cursor cannot be used together")
+	}
+	if query.CreatedAfter, err = parseDateParam(c.Query("created_after")); err != nil {
+		return query, err
+	}
+	if query.CreatedBefore, err = parseDateParam(c.Query("created_before")); err != nil {
+		return query, err
+	}
+	return query, nil
+}
+
+// parseDateParam 解析 RFC3339 时间或 YYYY-MM-DD 日期，为空时返回 nil
+func parseDateParam(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid date: %s", value)
+}
+
+// listBookPage 按请求的查询参数分页查询 db 范围内的书籍，失败时写入错误响应
+func listBookPage(c *gin.Context, db *gorm.DB, defaultSort string) (*models.BookPage, bool) {
+	query, err := parseBookListQuery(c, defaultSort)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	page, err := models.ListBooks(db, query)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to fetch books"})
+		if errors.Is(err, models.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to fetch books"})
+		}
+		return nil, false
+	}
+	return page, true
+}
+
+func GetBooks(c *gin.Context) {
+	user, _ := middlewares.CurrentUser(c)
+	page, ok := listBookPage(c, database.MySQLDB.Scopes(models.VisibleTo(user)), models.BookSortCreated)
+	if !ok {
 		return
 	}
-	c.JSON(http.StatusOK, books)
+	c.JSON(http.StatusOK, page)
 }
 
 func GetBookByID(c *gin.Context) {
@@ -38,6 +127,13 @@ func GetBookByID(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
 		return
 	}
+
+	// 无权查看的书籍与不存在的书籍返回相同的结果
+	user, _ := middlewares.CurrentUser(c)
+	if !book.CanBeViewedBy(user, c.Query("share_token")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+		return
+	}
 	c.JSON(http.StatusOK, book)
 }
 
@@ -63,6 +159,17 @@ func UpdateBook(c *gin.Context) {
 		return
 	}
 
+	// 只有上传者或管理员可以编辑
+	user, _ := middlewares.CurrentUser(c)
+	if !bookInDB.CanBeViewedBy(user, "") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+		return
+	}
+	if !bookInDB.CanBeModifiedBy(user) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+
 	// 更新书籍信息
 	if book.Title != "" {
 		bookInDB.Title = book.Title
@@ -76,12 +183,33 @@ func UpdateBook(c *gin.Context) {
 	if book.CoverURL != "" {
 		bookInDB.CoverURL = book.CoverURL
 	}
+	if book.Language != "" {
+		bookInDB.Language = prompts.Normalize(book.Language)
+		if bookInDB.Language == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid language"})
+			return
+		}
+	}
+	if book.Visibility != "" {
+		if !models.IsValidVisibility(book.Visibility) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid visibility"})
+			return
+		}
+		if err := bookInDB.SetVisibility(book.Visibility); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update book"})
+			return
+		}
+	}
 
 	// 保存更新后的书籍信息
 	if err := models.UpdateBook(database.MySQLDB, bookInDB); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update book"})
 		return
 	}
+	if err := services.IndexBook(database.MySQLDB, bookInDB.ID); err != nil {
+		log.Printf("Failed to index book %d: %s", bookInDB.ID, err)
+	}
+	services.UpdateRecommendIndex(*bookInDB)
 
 	// 返回成功响应
 	c.JSON(http.StatusOK, gin.H{"message": "Book updated successfully", "book": bookInDB})
@@ -95,11 +223,51 @@ func DeleteBook(c *gin.Context) {
 		return
 	}
 
-	// 删除书籍
-	if err := models.DeleteBook(database.MySQLDB, uint(uintID)); err != nil {
+	book, err := models.GetBookByID(database.MySQLDB, uint(uintID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+		return
+	}
+
+	// 只有上传者或管理员可以删除
+	user, _ := middlewares.CurrentUser(c)
+	if !book.CanBeViewedBy(user, "") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+		return
+	}
+	if !book.CanBeModifiedBy(user) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+
+	// 在一个事务中删除书籍及其章节、阅读进度、收藏、书架条目、标注、评分和摘要，任何一步失败都不留下残缺的数据
+	err = database.MySQLDB.Transaction(func(tx *gorm.DB) error {
+		steps := []func(*gorm.DB, uint) error{
+			models.DeleteBookReadingProgress,
+			models.DeleteBookFavorites,
+			models.DeleteBookShelfItems,
+			models.DeleteBookAnnotations,
+			models.DeleteBookRatings,
+			services.InvalidateSummary,
+			models.DeleteBookChapters,
+			models.DeleteBook,
+		}
+		for _, step := range steps {
+			if err := step(tx, book.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete book"})
 		return
 	}
+	if err := services.RemoveBookFromIndex(book.ID); err != nil {
+		log.Printf("Failed to remove book %d from search index: %s", book.ID, err)
+	}
+	services.RemoveBookFromRecommendIndex(book.ID)
+	services.RemovePassageIndex(book.ID)
 
 	// 返回成功响应
 	c.JSON(http.StatusOK, gin.H{"message": "Book deleted successfully"})
@@ -115,6 +283,8 @@ func UploadBook(c *gin.Context) {
 	book.Description = c.DefaultPostForm("description", "")
 	book.CoverURL = c.DefaultPostForm("cover_url", "")
 	book.Tags = c.DefaultPostForm("tags", "")
+	book.Language = prompts.Normalize(c.DefaultPostForm("language", ""))
+	visibility := c.DefaultPostForm("visibility", models.VisibilityPrivate)
 
 	// 将tags转为json数组
 	if book.Tags != "" {
@@ -125,6 +295,14 @@ func UploadBook(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Title and Author are required"})
 		return
 	}
+	if !models.IsValidVisibility(visibility) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid visibility"})
+		return
+	}
+	if err := book.SetVisibility(visibility); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate share token"})
+		return
+	}
 
 	// 获取文件数据
 	file, err := c.FormFile("file")
@@ -182,6 +360,13 @@ func UploadBook(c *gin.Context) {
 		}
 		tempFile.Close()
 
+		// 没有指定语言时使用 EPUB 元数据中的语言
+		if book.Language == "" {
+			if metadata, err := utils.GetEpubMetadata(tempFile.Name()); err == nil {
+				book.Language = prompts.Normalize(metadata["language"])
+			}
+		}
+
 		// 创建临时目录用于存储封面
 		tempCoverDir, err := os.MkdirTemp("", "covers-*")
 		if err != nil {
@@ -229,11 +414,19 @@ func UploadBook(c *gin.Context) {
 	// 设置书籍的URL
 	book.BookURL = fmt.Sprintf("http://%s/%s/%s", cfg.S3.Endpoint, bucketName, objectName)
 
+	// 记录上传者
+	user, _ := middlewares.CurrentUser(c)
+	book.OwnerID = &user.ID
+
 	// 将书籍信息保存到数据库
 	if err := models.CreateBook(database.MySQLDB, &book); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save book to database"})
 		return
 	}
+	if err := services.IndexBook(database.MySQLDB, book.ID); err != nil {
+		log.Printf("Failed to index book %d: %s", book.ID, err)
+	}
+	services.UpdateRecommendIndex(book)
 
 	// 返回成功响应
 	c.JSON(http.StatusOK, gin.H{
@@ -241,7 +434,3 @@ func UploadBook(c *gin.Context) {
 		"book":    book,
 	})
 }
-
-func SummarizeBook(c *gin.Context) {
-	services.GetBookSummary(c)
-}
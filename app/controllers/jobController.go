@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	middlewares "github.com/sd0ric4/book-reader-backend/app/middleewares"
+	"github.com/sd0ric4/book-reader-backend/app/models"
+	"github.com/sd0ric4/book-reader-backend/app/services"
+	"github.com/sd0ric4/book-reader-backend/app/services/jobs"
+)
+
+// loadOwnJob 加载当前用户提交的任务，管理员可以查看所有任务，失败时写入错误响应
+func loadOwnJob(c *gin.Context) (*jobs.Job, bool) {
+	if services.Jobs == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return nil, false
+	}
+
+	job, err := services.Jobs.Get(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, jobs.ErrJobNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to fetch job"})
+		return nil, false
+	}
+
+	user, _ := middlewares.CurrentUser(c)
+	if job.UserID != user.ID && !user.HasRole(models.RoleAdmin) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return nil, false
+	}
+	return job, true
+}
+
+// GetJob 返回后台任务的状态、进度和结果
+func GetJob(c *gin.Context) {
+	job, ok := loadOwnJob(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// RetryJob 重新执行失败的任务
+func RetryJob(c *gin.Context) {
+	job, ok := loadOwnJob(c)
+	if !ok {
+		return
+	}
+
+	job, err := services.Jobs.Retry(c.Request.Context(), job.ID)
+	if errors.Is(err, jobs.ErrJobNotRetryable) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only failed jobs can be retried"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retry job"})
+		return
+	}
+	c.JSON(http.StatusAccepted, job)
+}
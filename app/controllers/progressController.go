@@ -0,0 +1,181 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sd0ric4/book-reader-backend/app/database"
+	middlewares "github.com/sd0ric4/book-reader-backend/app/middleewares"
+	"github.com/sd0ric4/book-reader-backend/app/models"
+	"github.com/sd0ric4/book-reader-backend/app/services"
+	"gorm.io/gorm"
+)
+
+// loadVisibleBook 解析路径中的书籍ID并加载当前用户可见的书籍，失败时写入错误响应
+func loadVisibleBook(c *gin.Context) (*models.Book, bool) {
+	uintID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+		return nil, false
+	}
+
+	book, err := models.GetBookByID(database.MySQLDB, uint(uintID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+		return nil, false
+	}
+
+	user, _ := middlewares.CurrentUser(c)
+	if !book.CanBeViewedBy(user, c.Query("share_token")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+		return nil, false
+	}
+	return book, true
+}
+
+func GetReadingProgress(c *gin.Context) {
+	book, ok := loadVisibleBook(c)
+	if !ok {
+		return
+	}
+
+	user, _ := middlewares.CurrentUser(c)
+	progress, err := models.GetReadingProgress(database.MySQLDB, user.ID, book.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Reading progress not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to fetch reading progress"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, progress)
+}
+
+func UpdateReadingProgress(c *gin.Context) {
+	book, ok := loadVisibleBook(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateProgressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Percentage < 0 || req.Percentage > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Percentage must be between 0 and 100"})
+		return
+	}
+	if req.Offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Offset must not be negative"})
+		return
+	}
+
+	// 章节必须属于这本书
+	if req.ChapterID != nil {
+		chapter, err := models.GetChapterByID(database.MySQLDB, *req.ChapterID)
+		if err != nil || chapter.BookID != book.ID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Chapter does not belong to this book"})
+			return
+		}
+	}
+
+	user, _ := middlewares.CurrentUser(c)
+	progress := &models.ReadingProgress{
+		UserID:     user.ID,
+		BookID:     book.ID,
+		ChapterID:  req.ChapterID,
+		Offset:     req.Offset,
+		CFI:        req.CFI,
+		Percentage: req.Percentage,
+	}
+	if err := models.UpsertReadingProgress(database.MySQLDB, progress); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save reading progress"})
+		return
+	}
+
+	c.JSON(http.StatusOK, progress)
+}
+
+func GetContinueReading(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit <= 0 || limit > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+
+	user, _ := middlewares.CurrentUser(c)
+	items, err := models.GetContinueReading(database.MySQLDB, user, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to fetch reading list"})
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+func SyncReadingProgress(c *gin.Context) {
+	book, ok := loadVisibleBook(c)
+	if !ok {
+		return
+	}
+
+	var req services.SyncProgressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.DeviceID == "" || req.ClientUpdatedAt.IsZero() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "device_id and client_updated_at are required"})
+		return
+	}
+	if req.Policy != "" && !services.IsValidSyncPolicy(req.Policy) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sync policy"})
+		return
+	}
+	if req.Percentage < 0 || req.Percentage > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Percentage must be between 0 and 100"})
+		return
+	}
+	if req.Offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Offset must not be negative"})
+		return
+	}
+	if req.ChapterID != nil {
+		chapter, err := models.GetChapterByID(database.MySQLDB, *req.ChapterID)
+		if err != nil || chapter.BookID != book.ID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Chapter does not belong to this book"})
+			return
+		}
+	}
+
+	user, _ := middlewares.CurrentUser(c)
+	result, err := services.SyncReadingProgress(database.MySQLDB, user.ID, book.ID, req)
+	if err != nil {
+		if errors.Is(err, services.ErrSyncConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Too many concurrent updates, please retry"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sync reading progress"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func GetReadingDevices(c *gin.Context) {
+	book, ok := loadVisibleBook(c)
+	if !ok {
+		return
+	}
+
+	user, _ := middlewares.CurrentUser(c)
+	devices, err := models.GetReadingDevices(database.MySQLDB, user.ID, book.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to fetch devices"})
+		return
+	}
+	c.JSON(http.StatusOK, devices)
+}
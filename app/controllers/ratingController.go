@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sd0ric4/book-reader-backend/app/database"
+	middlewares "github.com/sd0ric4/book-reader-backend/app/middleewares"
+	"github.com/sd0ric4/book-reader-backend/app/models"
+	"gorm.io/gorm"
+)
+
+// GetBookRating 返回书籍的评分统计以及当前用户的评分（未评分时为空）
+func GetBookRating(c *gin.Context) {
+	book, ok := loadVisibleBook(c)
+	if !ok {
+		return
+	}
+
+	stats, err := models.GetBookRatingStats(database.MySQLDB, book.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to fetch rating"})
+		return
+	}
+
+	user, _ := middlewares.CurrentUser(c)
+	rating, err := models.GetBookRating(database.MySQLDB, user.ID, book.ID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to fetch rating"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"stats": stats, "rating": rating})
+}
+
+func RateBook(c *gin.Context) {
+	book, ok := loadVisibleBook(c)
+	if !ok {
+		return
+	}
+
+	var req models.RateBookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !models.IsValidBookRating(req.Rating) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Rating must be between 1 and 5"})
+		return
+	}
+
+	user, _ := middlewares.CurrentUser(c)
+	rating := &models.BookRating{UserID: user.ID, BookID: book.ID, Rating: req.Rating}
+	if err := models.UpsertBookRating(database.MySQLDB, rating); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save rating"})
+		return
+	}
+	c.JSON(http.StatusOK, rating)
+}
+
+func DeleteBookRating(c *gin.Context) {
+	book, ok := loadVisibleBook(c)
+	if !ok {
+		return
+	}
+
+	user, _ := middlewares.CurrentUser(c)
+	if err := models.DeleteBookRating(database.MySQLDB, user.ID, book.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete rating"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Rating deleted successfully"})
+}
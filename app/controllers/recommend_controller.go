@@ -2,13 +2,17 @@ package controllers
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sd0ric4/book-reader-backend/app/database"
+	middlewares "github.com/sd0ric4/book-reader-backend/app/middleewares"
 	"github.com/sd0ric4/book-reader-backend/app/models"
 	"github.com/sd0ric4/book-reader-backend/app/services"
 )
 
-// RecommendBooksHandler 处理书籍推荐的HTTP请求
+// RecommendBooksHandler 处理书籍推荐的HTTP请求。
+// 书籍列表由客户端提供，已被 GetUserRecommendations 取代，保留以兼容旧客户端。
 func RecommendBooksHandler(c *gin.Context) {
 	// 解析请求数据
 	var reqBody models.RecommendationRequest
@@ -22,6 +26,10 @@ func RecommendBooksHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "User books are required"})
 		return
 	}
+	if reqBody.Limit < 0 || reqBody.Limit > 50 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
 
 	// 调用推荐服务
 	recommendations, err := services.RecommendBooks(reqBody)
@@ -36,3 +44,41 @@ func RecommendBooksHandler(c *gin.Context) {
 		"recommendations": recommendations,
 	})
 }
+
+// GetUserRecommendations 根据当前用户的阅读进度、收藏和评分推荐书籍
+func GetUserRecommendations(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit <= 0 || limit > 50 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+
+	user, _ := middlewares.CurrentUser(c)
+	recommendations, err := services.RecommendForUser(database.MySQLDB, user, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Recommendation failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recommendations": recommendations})
+}
+
+// GetSimilarBooks 返回与一本书相似的书籍
+func GetSimilarBooks(c *gin.Context) {
+	book, ok := loadVisibleBook(c)
+	if !ok {
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit <= 0 || limit > 50 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+
+	user, _ := middlewares.CurrentUser(c)
+	similar, err := services.SimilarBooks(database.MySQLDB, user, book, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Recommendation failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"similar": similar})
+}
@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sd0ric4/book-reader-backend/app/database"
+	middlewares "github.com/sd0ric4/book-reader-backend/app/middleewares"
+	"github.com/sd0ric4/book-reader-backend/app/models"
+	"github.com/sd0ric4/book-reader-backend/app/services"
+	"github.com/sd0ric4/book-reader-backend/app/services/search"
+)
+
+// SearchBooks 在书籍元数据和章节内容中检索当前用户可见的书籍
+func SearchBooks(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Query is required"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 || limit > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset"})
+		return
+	}
+	if services.SearchIndex == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Search is not available"})
+		return
+	}
+
+	// 索引中包含所有书籍，检索时只保留命中的书中当前用户可见的
+	user, _ := middlewares.CurrentUser(c)
+	var visibleErr error
+	results, total := services.SearchIndex.Search(query, search.Options{
+		Limit:  limit,
+		Offset: offset,
+		Allow: func(bookIDs []uint) map[uint]bool {
+			visibleIDs, err := models.FilterBookIDs(database.MySQLDB.Scopes(models.VisibleTo(user)), bookIDs)
+			if err != nil {
+				visibleErr = err
+				return nil
+			}
+			visible := make(map[uint]bool, len(visibleIDs))
+			for _, id := range visibleIDs {
+				visible[id] = true
+			}
+			return visible
+		},
+	})
+	if visibleErr != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to search books"})
+		return
+	}
+
+	bookIDs := make([]uint, len(results))
+	for i, result := range results {
+		bookIDs[i] = result.BookID
+	}
+	books, err := models.GetBooksByIDs(database.MySQLDB, bookIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to search books"})
+		return
+	}
+	booksByID := make(map[uint]models.Book, len(books))
+	for _, book := range books {
+		booksByID[book.ID] = book
+	}
+
+	items := make([]search.BookResult, 0, len(results))
+	for _, result := range results {
+		// 索引尚未同步时可能包含已删除的书
+		if book, ok := booksByID[result.BookID]; ok {
+			items = append(items, search.BookResult{Book: book, Score: result.Score, Matches: result.Matches})
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"total": total, "items": items})
+}
+
+// FindInBook 在一本书的章节中查找文本，返回每处匹配的章节、字符偏移和上下文
+func FindInBook(c *gin.Context) {
+	book, ok := loadVisibleBook(c)
+	if !ok {
+		return
+	}
+
+	query := c.Query("q")
+	if strings.TrimSpace(query) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Query is required"})
+		return
+	}
+	mode := c.DefaultQuery("mode", services.FindModePlain)
+	if !services.IsValidFindMode(mode) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mode"})
+		return
+	}
+	caseSensitive, err := strconv.ParseBool(c.DefaultQuery("case_sensitive", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid case_sensitive"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "200"))
+	if err != nil || limit <= 0 || limit > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+
+	result, err := services.FindInBook(database.MySQLDB, book.ID, services.FindInBookRequest{
+		Query:         query,
+		Mode:          mode,
+		CaseSensitive: caseSensitive,
+		Limit:         limit,
+	})
+	if errors.Is(err, services.ErrInvalidFindQuery) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to search book"})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// ReindexSearch 重新建立全部书籍的检索索引
+func ReindexSearch(c *gin.Context) {
+	if services.SearchIndex == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Search is not available"})
+		return
+	}
+
+	count, err := services.SyncSearchIndex(database.MySQLDB, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rebuild search index"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Search index rebuilt", "books": count})
+}
@@ -0,0 +1,299 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sd0ric4/book-reader-backend/app/database"
+	middlewares "github.com/sd0ric4/book-reader-backend/app/middleewares"
+	"github.com/sd0ric4/book-reader-backend/app/models"
+	"gorm.io/gorm"
+)
+
+// loadOwnShelf 加载路径中指定的、属于当前用户的书架，失败时写入错误响应
+func loadOwnShelf(c *gin.Context) (*models.Shelf, bool) {
+	uintID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid shelf ID"})
+		return nil, false
+	}
+
+	user, _ := middlewares.CurrentUser(c)
+	shelf, err := models.GetShelfByID(database.MySQLDB, uint(uintID))
+	if err != nil || shelf.UserID != user.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shelf not found"})
+		return nil, false
+	}
+	return shelf, true
+}
+
+func AddFavorite(c *gin.Context) {
+	book, ok := loadVisibleBook(c)
+	if !ok {
+		return
+	}
+
+	user, _ := middlewares.CurrentUser(c)
+	if err := models.AddFavorite(database.MySQLDB, user.ID, book.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add favorite"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Book added to favorites"})
+}
+
+func RemoveFavorite(c *gin.Context) {
+	uintID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+		return
+	}
+
+	user, _ := middlewares.CurrentUser(c)
+	if err := models.RemoveFavorite(database.MySQLDB, user.ID, uint(uintID)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove favorite"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Book removed from favorites"})
+}
+
+func GetFavorites(c *gin.Context) {
+	// 默认按收藏时间倒序
+	user, _ := middlewares.CurrentUser(c)
+	page, ok := listBookPage(c, database.MySQLDB.Scopes(models.VisibleTo(user), models.FavoritedBy(user.ID)), models.BookSortFavorited)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+func GetShelves(c *gin.Context) {
+	user, _ := middlewares.CurrentUser(c)
+	if err := models.EnsureDefaultShelves(database.MySQLDB, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to create default shelves"})
+		return
+	}
+
+	shelves, err := models.GetShelves(database.MySQLDB, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to fetch shelves"})
+		return
+	}
+	c.JSON(http.StatusOK, shelves)
+}
+
+func CreateShelf(c *gin.Context) {
+	var req models.ShelfRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Shelf name is required"})
+		return
+	}
+
+	user, _ := middlewares.CurrentUser(c)
+	shelf := &models.Shelf{UserID: user.ID, Name: strings.TrimSpace(req.Name)}
+	if err := models.CreateShelf(database.MySQLDB, shelf); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create shelf"})
+		return
+	}
+	c.JSON(http.StatusOK, shelf)
+}
+
+func UpdateShelf(c *gin.Context) {
+	shelf, ok := loadOwnShelf(c)
+	if !ok {
+		return
+	}
+
+	var req models.ShelfRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if name := strings.TrimSpace(req.Name); name != "" {
+		shelf.Name = name
+		if err := models.UpdateShelf(database.MySQLDB, shelf); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update shelf"})
+			return
+		}
+	}
+	if req.Position != nil {
+		if err := models.MoveShelf(database.MySQLDB, shelf, *req.Position); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move shelf"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, shelf)
+}
+
+func DeleteShelf(c *gin.Context) {
+	shelf, ok := loadOwnShelf(c)
+	if !ok {
+		return
+	}
+
+	if err := models.DeleteShelf(database.MySQLDB, shelf.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete shelf"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Shelf deleted successfully"})
+}
+
+func GetShelfBooks(c *gin.Context) {
+	shelf, ok := loadOwnShelf(c)
+	if !ok {
+		return
+	}
+
+	// 默认按书架内的顺序排列
+	user, _ := middlewares.CurrentUser(c)
+	page, ok := listBookPage(c, database.MySQLDB.Scopes(models.VisibleTo(user), models.OnShelf(shelf.ID)), models.BookSortPosition)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+func AddShelfBook(c *gin.Context) {
+	shelf, ok := loadOwnShelf(c)
+	if !ok {
+		return
+	}
+
+	var req models.ShelfBookRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.BookID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "book_id is required"})
+		return
+	}
+
+	user, _ := middlewares.CurrentUser(c)
+	book, err := models.GetBookByID(database.MySQLDB, req.BookID)
+	if err != nil || !book.CanBeViewedBy(user, "") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+		return
+	}
+
+	if err := models.AddBookToShelf(database.MySQLDB, shelf.ID, book.ID, req.Position); err != nil {
+		if errors.Is(err, models.ErrBookAlreadyOnShelf) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Book is already on the shelf"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add book to shelf"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Book added to shelf"})
+}
+
+func RemoveShelfBook(c *gin.Context) {
+	shelf, ok := loadOwnShelf(c)
+	if !ok {
+		return
+	}
+
+	bookID, err := strconv.ParseUint(c.Param("book_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+		return
+	}
+
+	if err := models.RemoveBookFromShelf(database.MySQLDB, shelf.ID, uint(bookID)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Book is not on the shelf"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove book from shelf"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Book removed from shelf"})
+}
+
+// MoveShelfBook 调整书籍在书架内的顺序，或移动到另一个书架
+func MoveShelfBook(c *gin.Context) {
+	shelf, ok := loadOwnShelf(c)
+	if !ok {
+		return
+	}
+
+	bookID, err := strconv.ParseUint(c.Param("book_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+		return
+	}
+
+	var req models.ShelfBookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	targetID := shelf.ID
+	if req.ShelfID != 0 && req.ShelfID != shelf.ID {
+		user, _ := middlewares.CurrentUser(c)
+		target, err := models.GetShelfByID(database.MySQLDB, req.ShelfID)
+		if err != nil || target.UserID != user.ID {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Target shelf not found"})
+			return
+		}
+		targetID = target.ID
+	}
+
+	if err := models.MoveBookOnShelves(database.MySQLDB, shelf.ID, targetID, uint(bookID), req.Position); err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Book is not on the shelf"})
+		case errors.Is(err, models.ErrBookAlreadyOnShelf):
+			c.JSON(http.StatusConflict, gin.H{"error": "Book is already on the target shelf"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move book"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Book moved successfully"})
+}
+
+func ShareShelf(c *gin.Context) {
+	setShelfShared(c, true)
+}
+
+func UnshareShelf(c *gin.Context) {
+	setShelfShared(c, false)
+}
+
+func setShelfShared(c *gin.Context, shared bool) {
+	shelf, ok := loadOwnShelf(c)
+	if !ok {
+		return
+	}
+
+	if err := shelf.SetShared(shared); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate share token"})
+		return
+	}
+	if err := models.UpdateShelf(database.MySQLDB, shelf); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update shelf"})
+		return
+	}
+	c.JSON(http.StatusOK, shelf)
+}
+
+// GetSharedShelf 通过分享链接只读查看书架，只展示公开书籍
+func GetSharedShelf(c *gin.Context) {
+	shelf, err := models.GetShelfByShareToken(database.MySQLDB, c.Param("token"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shelf not found"})
+		return
+	}
+
+	page, ok := listBookPage(c, database.MySQLDB.Scopes(models.VisibleTo(nil), models.OnShelf(shelf.ID)), models.BookSortPosition)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"shelf": gin.H{"id": shelf.ID, "name": shelf.Name},
+		"books": page,
+	})
+}
@@ -0,0 +1,218 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sd0ric4/book-reader-backend/app/database"
+	middlewares "github.com/sd0ric4/book-reader-backend/app/middleewares"
+	"github.com/sd0ric4/book-reader-backend/app/models"
+	"github.com/sd0ric4/book-reader-backend/app/services"
+	"gorm.io/gorm"
+)
+
+// SummarizeBook 生成书籍的人物和内容摘要。已有摘要时直接返回，否则提交后台任务，
+// 通过 GET /jobs/:id 查询进度和结果。能找到对应的书籍且有章节内容时根据章节内容生成，
+// 否则只根据书名和作者生成。
+func SummarizeBook(c *gin.Context) {
+	req, book, ok := bindSummaryRequest(c)
+	if !ok {
+		return
+	}
+
+	// 先从数据库查询
+	db := database.MySQLDB
+	if summary, err := services.GetSavedSummary(db, book, req.BookTitle, req.Author, req.Language); err == nil {
+		c.JSON(http.StatusOK, summary)
+		return
+	}
+
+	user, _ := middlewares.CurrentUser(c)
+	enqueueSummary(c, user, summaryPayload(req, book))
+}
+
+// StreamSummary 以 Server-Sent Events 的方式生成书籍摘要，生成过程中发送模型输出的片段、
+// 内容概述和解析出的人物，最后发送校验通过的摘要并保存。已有摘要时只发送 summary 事件。
+func StreamSummary(c *gin.Context) {
+	req, book, ok := bindSummaryRequest(c)
+	if !ok {
+		return
+	}
+
+	db := database.MySQLDB
+	cached, err := services.GetSavedSummary(db, book, req.BookTitle, req.Author, req.Language)
+	if err != nil && services.LLM == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Summary service is not configured"})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	emit := func(event string, data interface{}) {
+		c.SSEvent(event, data)
+		c.Writer.Flush()
+	}
+	if err == nil {
+		emit(services.SummaryEventSummary, cached)
+		return
+	}
+
+	user, _ := middlewares.CurrentUser(c)
+	summary, err := services.StreamSummary(c.Request.Context(), db, services.LLM, book, req.BookTitle, req.Author, req.Language, emit)
+	if err == nil {
+		err = services.SaveReview(db, user.ID, book, summary)
+	}
+	if err != nil {
+		if c.Request.Context().Err() == nil {
+			emit(services.SummaryEventError, gin.H{"error": "Failed to generate summary"})
+		}
+		return
+	}
+	emit(services.SummaryEventSummary, summary)
+}
+
+// RegenerateSummary 提交后台任务重新生成书籍的摘要，替换已保存的同一语言的摘要，只有上传者或管理员可以操作。
+// 可以通过 language 参数指定摘要的语言，默认使用书籍的语言。
+func RegenerateSummary(c *gin.Context) {
+	book, ok := loadModifiableBook(c)
+	if !ok {
+		return
+	}
+	language, ok := summaryLanguage(c, book, book.Title, book.Author, c.Query("language"))
+	if !ok {
+		return
+	}
+
+	user, _ := middlewares.CurrentUser(c)
+	payload := summaryPayload(services.RequestData{Language: language}, book)
+	payload.Regenerate = true
+	enqueueSummary(c, user, payload)
+}
+
+// InvalidateSummary 删除书籍已保存的所有语言的摘要和章节概要，只有上传者或管理员可以操作
+func InvalidateSummary(c *gin.Context) {
+	book, ok := loadModifiableBook(c)
+	if !ok {
+		return
+	}
+
+	if err := services.InvalidateSummary(database.MySQLDB, book.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete summary"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Summary deleted successfully"})
+}
+
+// bindSummaryRequest 解析摘要请求，查找对应的书籍并确定摘要的语言，失败时写入错误响应
+func bindSummaryRequest(c *gin.Context) (services.RequestData, *models.Book, bool) {
+	req, book, ok := bindSummaryBook(c)
+	if !ok {
+		return req, nil, false
+	}
+	title, author := req.BookTitle, req.Author
+	if book != nil {
+		title, author = book.Title, book.Author
+	}
+	req.Language, ok = summaryLanguage(c, book, title, author, req.Language)
+	return req, book, ok
+}
+
+// summaryLanguage 检查请求的语言并返回摘要使用的语言，不支持该语言时写入错误响应
+func summaryLanguage(c *gin.Context, book *models.Book, title, author, requested string) (string, bool) {
+	if requested != "" && !services.Prompts.Supports(requested) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported language"})
+		return "", false
+	}
+	return services.SummaryLanguage(database.MySQLDB, book, title, author, requested), true
+}
+
+// bindSummaryBook 解析摘要请求并查找对应的书籍，失败时写入错误响应。
+// 指定 book_id 时书籍必须存在且可见；只指定书名时找不到书籍返回 nil，只根据书名和作者生成。
+func bindSummaryBook(c *gin.Context) (services.RequestData, *models.Book, bool) {
+	var req services.RequestData
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return req, nil, false
+	}
+
+	db := database.MySQLDB
+	user, _ := middlewares.CurrentUser(c)
+	visible := db.Scopes(models.VisibleTo(user))
+	if req.BookID != 0 {
+		book, err := models.GetBookByID(visible, req.BookID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+			return req, nil, false
+		}
+		return req, book, true
+	}
+	if req.BookTitle == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "book_id or book_title is required"})
+		return req, nil, false
+	}
+
+	book, err := models.FindBookByTitle(visible, req.BookTitle, req.Author)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return req, nil, true
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to fetch book"})
+		return req, nil, false
+	}
+	return req, book, true
+}
+
+// summaryPayload 返回摘要任务的参数，找到书籍时以书籍的书名和作者为准
+func summaryPayload(req services.RequestData, book *models.Book) services.SummaryJobPayload {
+	if book == nil {
+		return services.SummaryJobPayload{BookTitle: req.BookTitle, Author: req.Author, Language: req.Language}
+	}
+	return services.SummaryJobPayload{BookID: book.ID, BookTitle: book.Title, Author: book.Author, Language: req.Language}
+}
+
+// enqueueSummary 提交摘要任务并返回任务
+func enqueueSummary(c *gin.Context, user *models.User, payload services.SummaryJobPayload) {
+	if services.LLM == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Summary service is not configured"})
+		return
+	}
+	job, err := services.EnqueueSummary(c.Request.Context(), user.ID, payload)
+	if errors.Is(err, services.ErrSummaryUnavailable) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Summary service is not configured"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create summary job"})
+		return
+	}
+	c.JSON(http.StatusAccepted, job)
+}
+
+// loadModifiableBook 加载当前用户可以修改的书籍，失败时写入错误响应
+func loadModifiableBook(c *gin.Context) (*models.Book, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+		return nil, false
+	}
+	book, err := models.GetBookByID(database.MySQLDB, uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+		return nil, false
+	}
+
+	user, _ := middlewares.CurrentUser(c)
+	if !book.CanBeViewedBy(user, "") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+		return nil, false
+	}
+	if !book.CanBeModifiedBy(user) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return nil, false
+	}
+	return book, true
+}
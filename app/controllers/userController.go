@@ -1,16 +1,15 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
-	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/sd0ric4/book-reader-backend/app/config"
 	"github.com/sd0ric4/book-reader-backend/app/database"
+	middlewares "github.com/sd0ric4/book-reader-backend/app/middleewares"
 	"github.com/sd0ric4/book-reader-backend/app/models"
+	"github.com/sd0ric4/book-reader-backend/app/services"
 	"golang.org/x/crypto/bcrypt"
-	"gorm.io/gorm"
 )
 
 func Register(c *gin.Context) {
@@ -43,6 +42,9 @@ func Register(c *gin.Context) {
 	}
 	user.Password = string(hashedPassword)
 
+	// 角色不能由注册者自行指定，管理员通过启动参数 -admin 指定
+	user.Role = models.RoleReader
+
 	if err := database.MySQLDB.Create(&user).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
 		return
@@ -69,23 +71,98 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	// 生成JWT
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": dbUser.ID,
-		"exp":     time.Now().Add(time.Hour * 72).Unix(),
+	// 创建会话并生成访问令牌和刷新令牌
+	tokens, err := services.CreateSession(c.Request.Context(), dbUser.ID, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Login successful",
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
 	})
+}
+
+func RefreshToken(c *gin.Context) {
+	var req models.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.RefreshToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
 
-	tokenString, err := token.SignedString([]byte(config.Config.JWT.Secret))
+	tokens, err := services.RefreshSession(c.Request.Context(), req.RefreshToken, c.ClientIP())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		switch {
+		case errors.Is(err, services.ErrRefreshTokenReused):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token reuse detected, session revoked"})
+		case errors.Is(err, services.ErrSessionNotFound), errors.Is(err, services.ErrInvalidRefreshToken):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		}
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Login successful",
-		"token":   tokenString,
+		"message":       "Token refreshed",
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
 	})
 }
+
+func Logout(c *gin.Context) {
+	user, _ := middlewares.CurrentUser(c)
+	if err := services.RevokeSession(c.Request.Context(), user.ID, middlewares.CurrentSessionID(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+func LogoutAll(c *gin.Context) {
+	user, _ := middlewares.CurrentUser(c)
+	if err := services.RevokeAllSessions(c.Request.Context(), user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out from all devices"})
+}
+
+func GetSessions(c *gin.Context) {
+	user, _ := middlewares.CurrentUser(c)
+	sessions, err := services.ListSessions(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to fetch sessions"})
+		return
+	}
+
+	currentSessionID := middlewares.CurrentSessionID(c)
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+func RevokeSession(c *gin.Context) {
+	user, _ := middlewares.CurrentUser(c)
+	if err := services.RevokeSession(c.Request.Context(), user.ID, c.Param("id")); err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
 func ChangePassword(c *gin.Context) {
 	var req models.ChangePasswordRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -93,13 +170,14 @@ func ChangePassword(c *gin.Context) {
 		return
 	}
 
-	var user models.User
-	if err := database.MySQLDB.Where("email = ?", req.Email).First(&user).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-		}
+	// 只允许修改当前登录用户的密码
+	user, ok := middlewares.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+	if req.Email != "" && req.Email != user.Email {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot change another user's password"})
 		return
 	}
 
@@ -115,10 +193,27 @@ func ChangePassword(c *gin.Context) {
 	}
 
 	user.Password = string(hashedPassword)
-	if err := database.MySQLDB.Save(&user).Error; err != nil {
+	if err := database.MySQLDB.Save(user).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
 		return
 	}
 
+	// 修改密码后注销其他设备上的会话，会话已被同时注销时不算失败
+	sessions, err := services.ListSessions(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+	currentSessionID := middlewares.CurrentSessionID(c)
+	for _, session := range sessions {
+		if session.ID == currentSessionID {
+			continue
+		}
+		if err := services.RevokeSession(c.Request.Context(), user.ID, session.ID); err != nil && !errors.Is(err, services.ErrSessionNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
 }
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"log"
 	"time"
@@ -9,16 +11,56 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/sd0ric4/book-reader-backend/app/config"
 	"github.com/sd0ric4/book-reader-backend/app/database"
+	"github.com/sd0ric4/book-reader-backend/app/models"
 	"github.com/sd0ric4/book-reader-backend/app/routes"
+	"github.com/sd0ric4/book-reader-backend/app/services"
 )
 
 func main() {
+	// 启动时可将指定邮箱的用户提升为管理员
+	adminEmail := flag.String("admin", "", "promote the user with this email to admin on startup")
+	flag.Parse()
 
 	// 加载配置
 	config.LoadConfig("../config/config.yaml")
 
 	// 初始化数据库
-	database.InitMySQL()
+	database.InitDatabases()
+	models.Migrate(database.MySQLDB)
+
+	if *adminEmail != "" {
+		user, err := models.GetUserByEmail(database.MySQLDB, *adminEmail)
+		if err != nil {
+			log.Fatalf("Failed to find admin user %s: %s", *adminEmail, err)
+		}
+		if err := models.UpdateUserRole(database.MySQLDB, user.ID, models.RoleAdmin); err != nil {
+			log.Fatalf("Failed to promote admin user %s: %s", *adminEmail, err)
+		}
+		log.Printf("User %s promoted to admin", *adminEmail)
+	}
+
+	// 初始化全文检索索引
+	if err := services.InitSearchIndex(database.MySQLDB, config.Config.Search.IndexPath); err != nil {
+		log.Printf("Failed to initialize search index: %s", err)
+	}
+	// 初始化推荐索引，分词器配置有误时使用默认的二元切分
+	if err := services.InitRecommendTokenizer(config.Config.Recommend); err != nil {
+		log.Printf("Failed to initialize recommendation tokenizer: %s", err)
+	}
+	if err := services.InitRecommendIndex(database.MySQLDB); err != nil {
+		log.Printf("Failed to initialize recommendation index: %s", err)
+	}
+	// 初始化生成摘要使用的大语言模型服务
+	if err := services.InitLLM(config.Config.AI); err != nil {
+		log.Printf("Failed to initialize LLM provider: %s", err)
+	}
+	if err := services.InitPrompts(config.Config.AI.PromptsDir); err != nil {
+		log.Printf("Failed to load prompt templates: %s", err)
+	}
+	// 启动后台任务 worker
+	services.InitJobs(database.RedisDB, config.Config.Jobs)
+	go services.RunJobs(context.Background(), config.Config.Jobs)
+
 	// 创建Gin实例
 	r := gin.Default()
 	// 配置 CORS
@@ -5,6 +5,15 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sd0ric4/book-reader-backend/app/database"
+	"github.com/sd0ric4/book-reader-backend/app/models"
+	"github.com/sd0ric4/book-reader-backend/app/services"
+)
+
+// 当前登录用户和会话在 gin.Context 中的键
+const (
+	currentUserKey    = "currentUser"
+	currentSessionKey = "currentSession"
 )
 
 func AuthMiddleware() gin.HandlerFunc {
@@ -17,17 +26,76 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// 解析 Token
-		token := strings.TrimPrefix(authHeader, "Bearer ")
-		if token == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Bearer token is missing"})
-			c.Abort()
+		if authenticate(c, authHeader) {
+			c.Next()
+		}
+	}
+}
+
+// OptionalAuthMiddleware 允许匿名访问；携带了 Token 时与 AuthMiddleware 一样校验
+func OptionalAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" {
+			c.Next()
 			return
 		}
 
-		// 这里应该解析Token并验证，假设验证通过
-		// 你可以使用JWT包进行验证
+		if authenticate(c, authHeader) {
+			c.Next()
+		}
+	}
+}
 
-		c.Next()
+// authenticate 校验 Token 并把用户放入上下文，失败时写入错误响应并中止请求
+func authenticate(c *gin.Context, authHeader string) bool {
+	// 解析 Token
+	token := strings.TrimPrefix(authHeader, "Bearer ")
+	if token == "" || token == authHeader {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Bearer token is missing"})
+		c.Abort()
+		return false
 	}
+
+	// 校验签名、算法和过期时间
+	claims, err := services.ParseToken(token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		c.Abort()
+		return false
+	}
+
+	// 会话被注销或吊销后，其访问令牌立即失效
+	if err := services.ValidateSession(c.Request.Context(), claims.UserID, claims.SessionID, c.ClientIP()); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked"})
+		c.Abort()
+		return false
+	}
+
+	// 加载 Token 对应的用户
+	user, err := models.GetUserByID(database.MySQLDB, claims.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		c.Abort()
+		return false
+	}
+
+	c.Set(currentUserKey, user)
+	c.Set(currentSessionKey, claims.SessionID)
+	return true
+}
+
+// CurrentUser 返回经 AuthMiddleware 验证后的当前用户
+func CurrentUser(c *gin.Context) (*models.User, bool) {
+	value, exists := c.Get(currentUserKey)
+	if !exists {
+		return nil, false
+	}
+	user, ok := value.(*models.User)
+	return user, ok
+}
+
+// CurrentSessionID 返回当前请求所属的会话ID
+func CurrentSessionID(c *gin.Context) string {
+	return c.GetString(currentSessionKey)
 }
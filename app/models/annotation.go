@@ -0,0 +1,195 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 高亮可选的颜色
+const (
+	HighlightYellow = "yellow"
+	HighlightGreen  = "green"
+	HighlightBlue   = "blue"
+	HighlightPink   = "pink"
+	HighlightPurple = "purple"
+)
+
+// TextAnchor 把标注定位到章节中的一段文字，偏移按字符（rune）计算。
+// 同时保存选中的原文及其前后文，章节重新提取导致偏移或章节ID失效时据此重新定位。
+type TextAnchor struct {
+	ChapterID   uint   `gorm:"not null;index" json:"chapter_id"`
+	StartOffset int    `gorm:"not null" json:"start_offset"`
+	EndOffset   int    `gorm:"not null" json:"end_offset"`
+	CFI         string `gorm:"size:255" json:"cfi"` // EPUB CFI
+	Quote       string `gorm:"type:text" json:"quote"`
+	Prefix      string `gorm:"size:255" json:"prefix"`
+	Suffix      string `gorm:"size:255" json:"suffix"`
+	// 原文在当前章节内容中已无法找到
+	Orphaned bool `gorm:"not null;default:false" json:"orphaned"`
+}
+
+// Bookmark 表示书签，锚点的起止偏移相同
+type Bookmark struct {
+	ID         uint `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID     uint `gorm:"not null;index:idx_bookmark_user_book" json:"user_id"`
+	BookID     uint `gorm:"not null;index:idx_bookmark_user_book;index" json:"book_id"`
+	TextAnchor `gorm:"embedded"`
+	Label      string    `gorm:"size:100" json:"label"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// Highlight 表示高亮及其笔记
+type Highlight struct {
+	ID         uint `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID     uint `gorm:"not null;index:idx_highlight_user_book" json:"user_id"`
+	BookID     uint `gorm:"not null;index:idx_highlight_user_book;index" json:"book_id"`
+	TextAnchor `gorm:"embedded"`
+	Color      string    `gorm:"size:20;not null;default:yellow" json:"color"`
+	Label      string    `gorm:"size:100" json:"label"`
+	Note       string    `gorm:"type:text" json:"note"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+type CreateBookmarkRequest struct {
+	ChapterID uint   `json:"chapter_id" binding:"required"`
+	Offset    int    `json:"offset"`
+	CFI       string `json:"cfi"`
+	Label     string `json:"label"`
+}
+
+type UpdateBookmarkRequest struct {
+	Label *string `json:"label"`
+}
+
+type CreateHighlightRequest struct {
+	ChapterID   uint   `json:"chapter_id" binding:"required"`
+	StartOffset int    `json:"start_offset"`
+	EndOffset   int    `json:"end_offset"`
+	CFI         string `json:"cfi"`
+	Color       string `json:"color"`
+	Label       string `json:"label"`
+	Note        string `json:"note"`
+}
+
+type UpdateHighlightRequest struct {
+	Color *string `json:"color"`
+	Label *string `json:"label"`
+	Note  *string `json:"note"`
+}
+
+// BookAnnotations 是某本书中用户的全部书签和高亮
+type BookAnnotations struct {
+	Bookmarks  []Bookmark  `json:"bookmarks"`
+	Highlights []Highlight `json:"highlights"`
+}
+
+// IsValidHighlightColor 判断高亮颜色是否合法
+func IsValidHighlightColor(color string) bool {
+	switch color {
+	case HighlightYellow, HighlightGreen, HighlightBlue, HighlightPink, HighlightPurple:
+		return true
+	}
+	return false
+}
+
+// 锚点相关的列，重新定位时只更新这些列
+var anchorColumns = []string{"chapter_id", "start_offset", "end_offset", "orphaned"}
+
+// 创建书签
+func CreateBookmark(db *gorm.DB, bookmark *Bookmark) error {
+	return db.Create(bookmark).Error
+}
+
+// 根据ID获取书签
+func GetBookmarkByID(db *gorm.DB, id uint) (*Bookmark, error) {
+	var bookmark Bookmark
+	if err := db.First(&bookmark, id).Error; err != nil {
+		return nil, err
+	}
+	return &bookmark, nil
+}
+
+// 获取用户在某本书中的书签，按章节和位置排序
+func GetBookmarks(db *gorm.DB, userID, bookID uint) ([]Bookmark, error) {
+	var bookmarks []Bookmark
+	if err := db.Where("user_id = ? AND book_id = ?", userID, bookID).Order("chapter_id ASC, start_offset ASC, id ASC").Find(&bookmarks).Error; err != nil {
+		return nil, err
+	}
+	return bookmarks, nil
+}
+
+// 更新书签
+func UpdateBookmark(db *gorm.DB, bookmark *Bookmark) error {
+	return db.Save(bookmark).Error
+}
+
+// 更新书签的锚点位置
+func UpdateBookmarkAnchor(db *gorm.DB, id uint, anchor TextAnchor) error {
+	return db.Model(&Bookmark{ID: id}).Select(anchorColumns).Updates(&Bookmark{TextAnchor: anchor}).Error
+}
+
+// 删除书签
+func DeleteBookmark(db *gorm.DB, id uint) error {
+	return db.Delete(&Bookmark{}, id).Error
+}
+
+// 创建高亮
+func CreateHighlight(db *gorm.DB, highlight *Highlight) error {
+	return db.Create(highlight).Error
+}
+
+// 根据ID获取高亮
+func GetHighlightByID(db *gorm.DB, id uint) (*Highlight, error) {
+	var highlight Highlight
+	if err := db.First(&highlight, id).Error; err != nil {
+		return nil, err
+	}
+	return &highlight, nil
+}
+
+// 获取用户在某本书中的高亮，按章节和位置排序
+func GetHighlights(db *gorm.DB, userID, bookID uint) ([]Highlight, error) {
+	var highlights []Highlight
+	if err := db.Where("user_id = ? AND book_id = ?", userID, bookID).Order("chapter_id ASC, start_offset ASC, id ASC").Find(&highlights).Error; err != nil {
+		return nil, err
+	}
+	return highlights, nil
+}
+
+// 更新高亮
+func UpdateHighlight(db *gorm.DB, highlight *Highlight) error {
+	return db.Save(highlight).Error
+}
+
+// 更新高亮的锚点位置
+func UpdateHighlightAnchor(db *gorm.DB, id uint, anchor TextAnchor) error {
+	return db.Model(&Highlight{ID: id}).Select(anchorColumns).Updates(&Highlight{TextAnchor: anchor}).Error
+}
+
+// 删除高亮
+func DeleteHighlight(db *gorm.DB, id uint) error {
+	return db.Delete(&Highlight{}, id).Error
+}
+
+// 删除书籍的所有书签和高亮
+func DeleteBookAnnotations(db *gorm.DB, bookID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("book_id = ?", bookID).Delete(&Bookmark{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("book_id = ?", bookID).Delete(&Highlight{}).Error
+	})
+}
+
+// 获取用户做过高亮且仍然可见的书籍，按书名排序
+func GetHighlightedBooks(db *gorm.DB, user *User) ([]Book, error) {
+	var books []Book
+	bookIDs := db.Model(&Highlight{}).Select("book_id").Where("user_id = ?", user.ID)
+	if err := db.Scopes(VisibleTo(user)).Where("id IN (?)", bookIDs).Order("title ASC, id ASC").Find(&books).Error; err != nil {
+		return nil, err
+	}
+	return books, nil
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"strings"
 	"time"
@@ -17,14 +19,108 @@ type Book struct {
 	CoverURL    string    `gorm:"size:255" json:"cover_url"`
 	Format      string    `gorm:"size:50" json:"format"`
 	Tags        string    `gorm:"type:json" json:"tags"`
+	Language    string    `gorm:"size:20" json:"language,omitempty"`
 	Score       float64   `json:"score,omitempty"`
+	OwnerID     *uint     `gorm:"index" json:"owner_id"`
+	Visibility  string    `gorm:"size:20;not null;default:public;index" json:"visibility"`
+	ShareToken  string    `gorm:"size:64;index" json:"share_token,omitempty"`
 	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 }
 
+// Book visibilities
+const (
+	VisibilityPrivate = "private" // 仅上传者可见
+	VisibilityLink    = "link"    // 持有分享链接者可见
+	VisibilityPublic  = "public"  // 所有人可见
+)
+
+// IsValidVisibility 判断可见性取值是否合法
+func IsValidVisibility(visibility string) bool {
+	return visibility == VisibilityPrivate || visibility == VisibilityLink || visibility == VisibilityPublic
+}
+
 type RecommendationRequest struct {
 	UserID    int    `json:"user_id"`
 	UserBooks []Book `json:"user_books"`
+	Limit     int    `json:"limit"`
+}
+
+// TagList 返回书籍的标签，tags 不是 JSON 数组时按空白切分
+func (b *Book) TagList() []string {
+	var tags []string
+	if err := json.Unmarshal([]byte(b.Tags), &tags); err != nil {
+		return strings.Fields(b.Tags)
+	}
+	return tags
+}
+
+// CanBeModifiedBy 判断用户是否可以编辑或删除该书籍：上传者本人或管理员
+func (b *Book) CanBeModifiedBy(user *User) bool {
+	if user == nil {
+		return false
+	}
+	if user.HasRole(RoleAdmin) {
+		return true
+	}
+	return b.OwnerID != nil && *b.OwnerID == user.ID
+}
+
+// CanBeViewedBy 判断用户（可为空）是否可以查看该书籍，shareToken 为分享链接中的令牌
+func (b *Book) CanBeViewedBy(user *User, shareToken string) bool {
+	switch {
+	case b.Visibility == VisibilityPublic:
+		return true
+	case b.Visibility == VisibilityLink && shareToken != "" && shareToken == b.ShareToken:
+		return true
+	case user == nil:
+		return false
+	case user.HasRole(RoleAdmin):
+		return true
+	default:
+		return b.OwnerID != nil && *b.OwnerID == user.ID
+	}
+}
+
+// SetVisibility 修改可见性，设为分享链接时生成分享令牌，取消分享时令牌失效
+func (b *Book) SetVisibility(visibility string) error {
+	b.Visibility = visibility
+	if visibility != VisibilityLink {
+		b.ShareToken = ""
+		return nil
+	}
+	if b.ShareToken != "" {
+		return nil
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		return err
+	}
+	b.ShareToken = token
+	return nil
+}
+
+// newShareToken 生成用于分享链接的随机令牌
+func newShareToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// VisibleTo 将查询限定为用户可以在列表中看到的书籍，user 为空表示匿名访问
+func VisibleTo(user *User) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if user == nil {
+			return db.Where("visibility = ?", VisibilityPublic)
+		}
+		if user.HasRole(RoleAdmin) {
+			return db
+		}
+		return db.Where("visibility = ? OR owner_id = ?", VisibilityPublic, user.ID)
+	}
 }
 
 // 获取书籍列表
@@ -36,6 +132,54 @@ func GetBooks(db *gorm.DB) ([]Book, error) {
 	return books, nil
 }
 
+// 按给定的ID顺序返回书籍，查不到的ID会被跳过
+func GetBooksByIDs(db *gorm.DB, bookIDs []uint) ([]Book, error) {
+	books := make([]Book, 0, len(bookIDs))
+	if len(bookIDs) == 0 {
+		return books, nil
+	}
+
+	var found []Book
+	if err := db.Where("id IN ?", bookIDs).Find(&found).Error; err != nil {
+		return nil, err
+	}
+	booksByID := make(map[uint]Book, len(found))
+	for _, book := range found {
+		booksByID[book.ID] = book
+	}
+
+	for _, id := range bookIDs {
+		if book, ok := booksByID[id]; ok {
+			books = append(books, book)
+		}
+	}
+	return books, nil
+}
+
+// 返回 bookIDs 中存在的书籍ID，db 上可以预先加上可见性等范围
+func FilterBookIDs(db *gorm.DB, bookIDs []uint) ([]uint, error) {
+	ids := make([]uint, 0, len(bookIDs))
+	if len(bookIDs) == 0 {
+		return ids, nil
+	}
+	if err := db.Model(&Book{}).Where("id IN ?", bookIDs).Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// 按ID顺序获取前 limit 本不在 excludeIDs 中的书籍
+func GetBooksExcluding(db *gorm.DB, excludeIDs []uint, limit int) ([]Book, error) {
+	if len(excludeIDs) > 0 {
+		db = db.Where("id NOT IN ?", excludeIDs)
+	}
+	var books []Book
+	if err := db.Order("id").Limit(limit).Find(&books).Error; err != nil {
+		return nil, err
+	}
+	return books, nil
+}
+
 // 根据ID获取书籍
 func GetBookByID(db *gorm.DB, id uint) (*Book, error) {
 	var book Book
@@ -45,6 +189,19 @@ func GetBookByID(db *gorm.DB, id uint) (*Book, error) {
 	return &book, nil
 }
 
+// 按书名查找书籍，author 不为空时同时匹配作者，db 上可以预先加上可见性等范围
+func FindBookByTitle(db *gorm.DB, title, author string) (*Book, error) {
+	query := db.Where("title = ?", title)
+	if author != "" {
+		query = query.Where("author = ?", author)
+	}
+	var book Book
+	if err := query.Order("id ASC").First(&book).Error; err != nil {
+		return nil, err
+	}
+	return &book, nil
+}
+
 // 更新书籍
 func UpdateBook(db *gorm.DB, book *Book) error {
 	if err := db.Save(book).Error; err != nil {
@@ -61,6 +218,19 @@ func CreateBook(db *gorm.DB, book *Book) error {
 	return nil
 }
 
+// 分批读取所有书籍，避免一次把全部书籍读入内存
+func ForEachBook(db *gorm.DB, batchSize int, fn func(book *Book) error) error {
+	var batch []Book
+	return db.FindInBatches(&batch, batchSize, func(tx *gorm.DB, _ int) error {
+		for i := range batch {
+			if err := fn(&batch[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
 // 删除书籍
 func DeleteBook(db *gorm.DB, id uint) error {
 	if err := db.Delete(&Book{}, id).Error; err != nil {
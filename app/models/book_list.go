@@ -0,0 +1,280 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 书籍列表可用的排序字段
+const (
+	BookSortTitle   = "title"
+	BookSortAuthor  = "author"
+	BookSortCreated = "created"
+	BookSortUpdated = "updated"
+	BookSortScore   = "score"
+	// BookSortPosition 按书架内的顺序排序，只能和 OnShelf 一起使用
+	BookSortPosition = "position"
+	// BookSortFavorited 按收藏时间排序，只能和 FavoritedBy 一起使用
+	BookSortFavorited = "favorited"
+)
+
+// 分页大小
+const (
+	DefaultBookPageSize = 20
+	MaxBookPageSize     = 100
+)
+
+var (
+	ErrInvalidCursor   = errors.New("invalid cursor")
+	ErrInvalidBookSort = errors.New("invalid sort field")
+)
+
+// 排序字段对应的列，带上表名以便和书架、收藏联表查询
+var bookSortColumns = map[string]string{
+	BookSortTitle:   "books.title",
+	BookSortAuthor:  "books.author",
+	BookSortCreated: "books.created_at",
+	BookSortUpdated: "books.updated_at",
+	BookSortScore:   "books.score",
+}
+
+// 只能和联表范围一起使用的排序字段对应的列，查询时以 <排序字段>_value 为别名取出用于生成游标
+var joinedSortColumns = map[string]string{
+	BookSortPosition:  "shelf_items.position",
+	BookSortFavorited: "favorite_books.created_at",
+}
+
+// BookListQuery 是书籍列表的筛选、排序和分页参数。
+// Page 大于0时使用偏移分页，否则使用 Cursor 做游标分页（为空表示第一页）。
+type BookListQuery struct {
+	Author        string
+	Tag           string
+	Format        string
+	CreatedAfter  *time.Time // 包含
+	CreatedBefore *time.Time // 不包含
+	Sort          string
+	Desc          bool
+	Page          int
+	PageSize      int
+	Cursor        string
+}
+
+// BookPage 是分页后的书籍列表，NextCursor 为空表示没有下一页
+type BookPage struct {
+	Items      []Book `json:"items"`
+	Total      int64  `json:"total"`
+	Page       int    `json:"page,omitempty"`
+	PageSize   int    `json:"page_size"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// bookCursor 记录上一页最后一本书的排序值和ID，排序方式变化时游标失效
+type bookCursor struct {
+	Sort  string          `json:"s"`
+	Desc  bool            `json:"d"`
+	ID    uint            `json:"id"`
+	Value json.RawMessage `json:"v"`
+}
+
+// listedBook 是列表查询的一行，ShelfPosition 和 FavoritedAt 只在按对应字段排序时有值
+type listedBook struct {
+	Book          `gorm:"embedded"`
+	ShelfPosition int       `gorm:"column:position_value"`
+	FavoritedAt   time.Time `gorm:"column:favorited_value"`
+}
+
+// IsValidBookSort 判断排序字段是否合法，只能和联表范围一起使用的排序字段不包括在内
+func IsValidBookSort(sort string) bool {
+	_, ok := bookSortColumns[sort]
+	return ok
+}
+
+// OnShelf 只查询书架上的书，可以按书架内的顺序（BookSortPosition）排序
+func OnShelf(shelfID uint) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Joins("JOIN shelf_items ON shelf_items.book_id = books.id AND shelf_items.shelf_id = ?", shelfID)
+	}
+}
+
+// FavoritedBy 只查询用户收藏的书，可以按收藏时间（BookSortFavorited）排序
+func FavoritedBy(userID uint) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Joins("JOIN favorite_books ON favorite_books.book_id = books.id AND favorite_books.user_id = ?", userID)
+	}
+}
+
+// ListBooks 按条件分页查询书籍，db 上可以预先加上可见性、书架等范围
+func ListBooks(db *gorm.DB, query BookListQuery) (*BookPage, error) {
+	if query.Sort == "" {
+		query.Sort = BookSortCreated
+		query.Desc = true
+	}
+	column, ok := bookSortColumns[query.Sort]
+	selects := "books.*"
+	if joined, found := joinedSortColumns[query.Sort]; found {
+		column, ok = joined, true
+		selects += ", " + joined + " AS " + query.Sort + "_value"
+	}
+	if !ok {
+		return nil, ErrInvalidBookSort
+	}
+	if query.PageSize <= 0 {
+		query.PageSize = DefaultBookPageSize
+	}
+	if query.PageSize > MaxBookPageSize {
+		query.PageSize = MaxBookPageSize
+	}
+
+	filtered := db.Model(&Book{}).Scopes(bookFilters(query))
+
+	var total int64
+	if err := filtered.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	direction := "ASC"
+	if query.Desc {
+		direction = "DESC"
+	}
+	stmt := filtered.Session(&gorm.Session{}).Order(column + " " + direction).Order("books.id " + direction)
+
+	page := &BookPage{Total: total, PageSize: query.PageSize}
+	if query.Page > 0 {
+		page.Page = query.Page
+		stmt = stmt.Offset((query.Page - 1) * query.PageSize)
+	} else if query.Cursor != "" {
+		cursor, value, err := decodeBookCursor(query.Cursor)
+		if err != nil || cursor.Sort != query.Sort || cursor.Desc != query.Desc {
+			return nil, ErrInvalidCursor
+		}
+		op := ">"
+		if query.Desc {
+			op = "<"
+		}
+		stmt = stmt.Where("("+column+" "+op+" ? OR ("+column+" = ? AND books.id "+op+" ?))", value, value, cursor.ID)
+	}
+
+	// 多取一条用于判断是否还有下一页
+	var rows []listedBook
+	if err := stmt.Select(selects).Limit(query.PageSize + 1).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	if len(rows) > query.PageSize {
+		rows = rows[:query.PageSize]
+		cursor, err := encodeBookCursor(query, rows[len(rows)-1])
+		if err != nil {
+			return nil, err
+		}
+		page.NextCursor = cursor
+	}
+	page.Items = make([]Book, len(rows))
+	for i, row := range rows {
+		page.Items[i] = row.Book
+	}
+	return page, nil
+}
+
+// bookFilters 按作者、标签、格式和创建时间筛选
+func bookFilters(query BookListQuery) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if query.Author != "" {
+			db = db.Where("books.author = ?", query.Author)
+		}
+		if query.Format != "" {
+			db = db.Where("books.format = ?", query.Format)
+		}
+		if query.Tag != "" {
+			// tags 是 JSON 数组，匹配带引号的标签以避免部分匹配
+			tag, _ := json.Marshal(query.Tag)
+			db = db.Where("books.tags LIKE ? ESCAPE '!'", "%"+escapeLike(string(tag))+"%")
+		}
+		if query.CreatedAfter != nil {
+			db = db.Where("books.created_at >= ?", *query.CreatedAfter)
+		}
+		if query.CreatedBefore != nil {
+			db = db.Where("books.created_at < ?", *query.CreatedBefore)
+		}
+		return db
+	}
+}
+
+// escapeLike 转义 LIKE 中的通配符，使用 ! 作为转义符以兼容 MySQL 和 SQLite
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
+
+// bookSortValue 返回书籍在排序字段上的值
+func bookSortValue(sort string, row listedBook) interface{} {
+	book := row.Book
+	switch sort {
+	case BookSortPosition:
+		return row.ShelfPosition
+	case BookSortFavorited:
+		return row.FavoritedAt
+	case BookSortTitle:
+		return book.Title
+	case BookSortAuthor:
+		return book.Author
+	case BookSortUpdated:
+		return book.UpdatedAt
+	case BookSortScore:
+		return book.Score
+	default:
+		return book.CreatedAt
+	}
+}
+
+func encodeBookCursor(query BookListQuery, last listedBook) (string, error) {
+	value, err := json.Marshal(bookSortValue(query.Sort, last))
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(bookCursor{Sort: query.Sort, Desc: query.Desc, ID: last.ID, Value: value})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeBookCursor 解码游标，并按排序字段还原排序值的类型
+func decodeBookCursor(encoded string) (*bookCursor, interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, nil, err
+	}
+	var cursor bookCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, nil, err
+	}
+
+	var value interface{}
+	switch cursor.Sort {
+	case BookSortTitle, BookSortAuthor:
+		var s string
+		err = json.Unmarshal(cursor.Value, &s)
+		value = s
+	case BookSortCreated, BookSortUpdated, BookSortFavorited:
+		var t time.Time
+		err = json.Unmarshal(cursor.Value, &t)
+		value = t
+	case BookSortScore:
+		var f float64
+		err = json.Unmarshal(cursor.Value, &f)
+		value = f
+	case BookSortPosition:
+		var n int
+		err = json.Unmarshal(cursor.Value, &n)
+		value = n
+	default:
+		err = ErrInvalidCursor
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return &cursor, value, nil
+}
@@ -115,3 +115,55 @@ func ChapterExists(db *gorm.DB, bookID uint, chapterName string) (bool, error) {
 	}
 	return count > 0, nil
 }
+
+// 获取书籍的章节目录（不含内容），按章节顺序排列
+func GetChapterTitles(db *gorm.DB, bookID uint) ([]BookChapter, error) {
+	var chapters []BookChapter
+	if err := db.Select("id", "book_id", "chapter_name").Where("book_id = ?", bookID).Order("id ASC").Find(&chapters).Error; err != nil {
+		return nil, err
+	}
+	return chapters, nil
+}
+
+// ChapterStats 是一本书的章节数量和最后更新时间，用于判断派生数据（如检索索引）是否过期
+type ChapterStats struct {
+	BookID      uint
+	Count       int64
+	LastUpdated string
+}
+
+// 获取书籍的章节统计，db 上可以预先加上筛选条件
+func GetChapterStats(db *gorm.DB) (map[uint]ChapterStats, error) {
+	var rows []ChapterStats
+	if err := db.Model(&BookChapter{}).Select("book_id, COUNT(*) AS count, MAX(updated_at) AS last_updated").Group("book_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	stats := make(map[uint]ChapterStats, len(rows))
+	for _, row := range rows {
+		stats[row.BookID] = row
+	}
+	return stats, nil
+}
+
+// GetChapterSamples 按章节顺序返回书籍前 limit 个有内容的章节的内容
+func GetChapterSamples(db *gorm.DB, bookID uint, limit int) ([]string, error) {
+	var contents []string
+	err := db.Model(&BookChapter{}).
+		Where("book_id = ? AND chapter_content <> ''", bookID).
+		Order("id").Limit(limit).
+		Pluck("chapter_content", &contents).Error
+	return contents, err
+}
+
+// 按章节顺序分批读取书籍的章节，避免一次把整本书读入内存
+func ForEachChapter(db *gorm.DB, bookID uint, batchSize int, fn func(chapter *BookChapter) error) error {
+	var batch []BookChapter
+	return db.Where("book_id = ?", bookID).FindInBatches(&batch, batchSize, func(tx *gorm.DB, _ int) error {
+		for i := range batch {
+			if err := fn(&batch[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ChapterSummary 是由大语言模型生成的章节概要，ContentHash 是生成时章节内容的哈希，
+// 章节内容变化后概要需要重新生成
+type ChapterSummary struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	BookID      uint      `gorm:"not null;index" json:"book_id"`
+	ChapterID   uint      `gorm:"not null;uniqueIndex" json:"chapter_id"`
+	ChapterName string    `gorm:"size:255" json:"chapter_name"`
+	Summary     string    `gorm:"type:text" json:"summary"`
+	ContentHash string    `gorm:"size:64" json:"-"`
+	Model       string    `gorm:"size:100" json:"model"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// 获取书籍的章节概要，按章节顺序排列
+func GetChapterSummaries(db *gorm.DB, bookID uint) ([]ChapterSummary, error) {
+	var summaries []ChapterSummary
+	if err := db.Where("book_id = ?", bookID).Order("chapter_id ASC").Find(&summaries).Error; err != nil {
+		return nil, err
+	}
+	return summaries, nil
+}
+
+// 获取章节的概要
+func GetChapterSummary(db *gorm.DB, chapterID uint) (*ChapterSummary, error) {
+	var summary ChapterSummary
+	if err := db.Where("chapter_id = ?", chapterID).First(&summary).Error; err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// 创建或更新章节概要，每个章节只保留一条记录
+func UpsertChapterSummary(db *gorm.DB, summary *ChapterSummary) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "chapter_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"chapter_name", "summary", "content_hash", "model", "updated_at"}),
+	}).Create(summary).Error
+}
+
+// 删除书籍的所有章节概要
+func DeleteChapterSummaries(db *gorm.DB, bookID uint) error {
+	return db.Where("book_id = ?", bookID).Delete(&ChapterSummary{}).Error
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FavoriteBook 表示用户收藏的书籍
+type FavoriteBook struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_favorite_user_book" json:"user_id"`
+	BookID    uint      `gorm:"not null;uniqueIndex:idx_favorite_user_book;index" json:"book_id"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (FavoriteBook) TableName() string {
+	return "favorite_books"
+}
+
+// 收藏书籍，重复收藏不报错
+func AddFavorite(db *gorm.DB, userID, bookID uint) error {
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&FavoriteBook{UserID: userID, BookID: bookID}).Error
+}
+
+// 取消收藏
+func RemoveFavorite(db *gorm.DB, userID, bookID uint) error {
+	return db.Where("user_id = ? AND book_id = ?", userID, bookID).Delete(&FavoriteBook{}).Error
+}
+
+// 获取用户收藏的书籍ID
+func GetFavoriteBookIDs(db *gorm.DB, userID uint) ([]uint, error) {
+	var bookIDs []uint
+	if err := db.Model(&FavoriteBook{}).Where("user_id = ?", userID).Order("created_at DESC").Pluck("book_id", &bookIDs).Error; err != nil {
+		return nil, err
+	}
+	return bookIDs, nil
+}
+
+// 获取所有用户的收藏记录，只包含用户和书籍
+func GetAllFavorites(db *gorm.DB) ([]FavoriteBook, error) {
+	var favorites []FavoriteBook
+	if err := db.Select("user_id", "book_id").Find(&favorites).Error; err != nil {
+		return nil, err
+	}
+	return favorites, nil
+}
+
+// BookFavoriteCount 是一本书被收藏的次数
+type BookFavoriteCount struct {
+	BookID uint
+	Count  int64
+}
+
+// 获取每本书被收藏的次数
+func GetFavoriteCounts(db *gorm.DB) ([]BookFavoriteCount, error) {
+	var counts []BookFavoriteCount
+	err := db.Model(&FavoriteBook{}).
+		Select("book_id, COUNT(*) AS count").
+		Group("book_id").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// 删除书籍的所有收藏记录
+func DeleteBookFavorites(db *gorm.DB, bookID uint) error {
+	return db.Where("book_id = ?", bookID).Delete(&FavoriteBook{}).Error
+}
@@ -4,5 +4,5 @@ import "gorm.io/gorm"
 
 func Migrate(db *gorm.DB) {
 	// 执行数据库迁移
-	db.AutoMigrate(&Book{})
+	db.AutoMigrate(&User{}, &Book{}, &BookChapter{}, &ReadingProgress{}, &ReadingDevice{}, &FavoriteBook{}, &Shelf{}, &ShelfItem{}, &Bookmark{}, &Highlight{}, &BookRating{}, &ChapterSummary{}, &Review{})
 }
@@ -0,0 +1,126 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 评分范围
+const (
+	MinBookRating = 1
+	MaxBookRating = 5
+)
+
+// BookRating 是用户给书籍的评分
+type BookRating struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_book_rating_user_book" json:"user_id"`
+	BookID    uint      `gorm:"not null;uniqueIndex:idx_book_rating_user_book;index" json:"book_id"`
+	Rating    int       `gorm:"not null" json:"rating"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (BookRating) TableName() string {
+	return "book_ratings"
+}
+
+type RateBookRequest struct {
+	Rating int `json:"rating" binding:"required"`
+}
+
+// BookRatingStats 是一本书的评分统计
+type BookRatingStats struct {
+	BookID  uint    `json:"book_id"`
+	Count   int64   `json:"count"`
+	Average float64 `json:"average"`
+}
+
+// IsValidBookRating 判断评分是否在允许范围内
+func IsValidBookRating(rating int) bool {
+	return rating >= MinBookRating && rating <= MaxBookRating
+}
+
+// 创建或更新评分，每个用户每本书只保留一条记录
+func UpsertBookRating(db *gorm.DB, rating *BookRating) error {
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "book_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rating", "updated_at"}),
+	}).Create(rating).Error
+	if err != nil {
+		return err
+	}
+
+	// 冲突更新时不会回填主键，重新读取完整记录
+	saved, err := GetBookRating(db, rating.UserID, rating.BookID)
+	if err != nil {
+		return err
+	}
+	*rating = *saved
+	return nil
+}
+
+// 获取用户对某本书的评分
+func GetBookRating(db *gorm.DB, userID, bookID uint) (*BookRating, error) {
+	var rating BookRating
+	if err := db.Where("user_id = ? AND book_id = ?", userID, bookID).First(&rating).Error; err != nil {
+		return nil, err
+	}
+	return &rating, nil
+}
+
+// 获取用户的全部评分
+func GetUserRatings(db *gorm.DB, userID uint) ([]BookRating, error) {
+	var ratings []BookRating
+	if err := db.Where("user_id = ?", userID).Find(&ratings).Error; err != nil {
+		return nil, err
+	}
+	return ratings, nil
+}
+
+// 获取所有用户的评分，只包含用户、书籍和评分
+func GetAllRatings(db *gorm.DB) ([]BookRating, error) {
+	var ratings []BookRating
+	if err := db.Select("user_id", "book_id", "rating").Find(&ratings).Error; err != nil {
+		return nil, err
+	}
+	return ratings, nil
+}
+
+// 删除用户对某本书的评分
+func DeleteBookRating(db *gorm.DB, userID, bookID uint) error {
+	return db.Where("user_id = ? AND book_id = ?", userID, bookID).Delete(&BookRating{}).Error
+}
+
+// 删除书籍的所有评分
+func DeleteBookRatings(db *gorm.DB, bookID uint) error {
+	return db.Where("book_id = ?", bookID).Delete(&BookRating{}).Error
+}
+
+// 获取一本书的评分统计
+func GetBookRatingStats(db *gorm.DB, bookID uint) (*BookRatingStats, error) {
+	stats := BookRatingStats{BookID: bookID}
+	err := db.Model(&BookRating{}).
+		Select("COUNT(*) AS count, COALESCE(AVG(rating), 0) AS average").
+		Where("book_id = ?", bookID).
+		Scan(&stats).Error
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// 获取所有书籍的评分统计
+func GetAllBookRatingStats(db *gorm.DB) ([]BookRatingStats, error) {
+	var stats []BookRatingStats
+	err := db.Model(&BookRating{}).
+		Select("book_id, COUNT(*) AS count, AVG(rating) AS average").
+		Group("book_id").
+		Scan(&stats).Error
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
@@ -0,0 +1,220 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReadingProgress 表示用户在某本书中的阅读位置
+type ReadingProgress struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID     uint      `gorm:"not null;uniqueIndex:idx_reading_progress_user_book" json:"user_id"`
+	BookID     uint      `gorm:"not null;uniqueIndex:idx_reading_progress_user_book;index" json:"book_id"`
+	ChapterID  *uint     `json:"chapter_id"`
+	Offset     int       `gorm:"column:char_offset" json:"offset"` // 章节内的字符偏移
+	CFI        string    `gorm:"size:255" json:"cfi"`              // EPUB CFI
+	Percentage float64   `json:"percentage"`
+	LastReadAt time.Time `gorm:"index" json:"last_read_at"`
+	// 同步元数据：每次位置变化版本号加一，并记录写入该位置的设备及其本地时间
+	Version         int64     `gorm:"not null;default:0" json:"version"`
+	DeviceID        string    `gorm:"size:100" json:"device_id"`
+	DeviceUpdatedAt time.Time `json:"device_updated_at"`
+	CreatedAt       time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt       time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (ReadingProgress) TableName() string {
+	return "reading_progress"
+}
+
+// ReadingDevice 记录每台设备最后一次同步的位置以及它已看到的服务端版本
+type ReadingDevice struct {
+	ID              uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID          uint      `gorm:"not null;uniqueIndex:idx_reading_device" json:"user_id"`
+	BookID          uint      `gorm:"not null;uniqueIndex:idx_reading_device" json:"book_id"`
+	DeviceID        string    `gorm:"size:100;not null;uniqueIndex:idx_reading_device" json:"device_id"`
+	ChapterID       *uint     `json:"chapter_id"`
+	Offset          int       `gorm:"column:char_offset" json:"offset"`
+	CFI             string    `gorm:"size:255" json:"cfi"`
+	Percentage      float64   `json:"percentage"`
+	ClientUpdatedAt time.Time `json:"client_updated_at"`
+	SeenVersion     int64     `json:"seen_version"`
+	SyncedAt        time.Time `json:"synced_at"`
+}
+
+type UpdateProgressRequest struct {
+	ChapterID  *uint   `json:"chapter_id"`
+	Offset     int     `json:"offset"`
+	CFI        string  `json:"cfi"`
+	Percentage float64 `json:"percentage"`
+}
+
+// ContinueReadingItem 是"继续阅读"列表中的一项
+type ContinueReadingItem struct {
+	Book     Book            `json:"book"`
+	Progress ReadingProgress `json:"progress"`
+}
+
+// 获取用户在某本书中的阅读进度
+func GetReadingProgress(db *gorm.DB, userID, bookID uint) (*ReadingProgress, error) {
+	var progress ReadingProgress
+	if err := db.Where("user_id = ? AND book_id = ?", userID, bookID).First(&progress).Error; err != nil {
+		return nil, err
+	}
+	return &progress, nil
+}
+
+// 获取用户的全部阅读进度
+func GetUserReadingProgress(db *gorm.DB, userID uint) ([]ReadingProgress, error) {
+	var progresses []ReadingProgress
+	if err := db.Where("user_id = ?", userID).Find(&progresses).Error; err != nil {
+		return nil, err
+	}
+	return progresses, nil
+}
+
+// 获取所有用户的阅读进度，只包含用户、书籍和百分比
+func GetAllReadingProgress(db *gorm.DB) ([]ReadingProgress, error) {
+	var progresses []ReadingProgress
+	if err := db.Select("user_id", "book_id", "percentage").Find(&progresses).Error; err != nil {
+		return nil, err
+	}
+	return progresses, nil
+}
+
+// 创建或更新阅读进度，每个用户每本书只保留一条记录
+func UpsertReadingProgress(db *gorm.DB, progress *ReadingProgress) error {
+	if progress.LastReadAt.IsZero() {
+		progress.LastReadAt = time.Now()
+	}
+
+	// 新记录从版本1开始，更新时在原版本上加一
+	progress.Version = 1
+	updates := append(
+		clause.AssignmentColumns([]string{"chapter_id", "char_offset", "cfi", "percentage", "last_read_at", "device_id", "device_updated_at", "updated_at"}),
+		clause.Assignment{Column: clause.Column{Name: "version"}, Value: gorm.Expr("version + 1")},
+	)
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "book_id"}},
+		DoUpdates: updates,
+	}).Create(progress).Error
+	if err != nil {
+		return err
+	}
+
+	// 冲突更新时不会回填主键，重新读取完整记录
+	saved, err := GetReadingProgress(db, progress.UserID, progress.BookID)
+	if err != nil {
+		return err
+	}
+	*progress = *saved
+	return nil
+}
+
+// 仅当记录不存在时创建阅读进度，返回是否创建成功
+func CreateReadingProgressIfAbsent(db *gorm.DB, progress *ReadingProgress) (bool, error) {
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(progress)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// 在版本号未被其他设备改变的前提下更新阅读进度（乐观锁），返回是否更新成功
+func UpdateReadingProgressIfVersion(db *gorm.DB, progress *ReadingProgress, expectedVersion int64) (bool, error) {
+	result := db.Model(&ReadingProgress{}).
+		Where("id = ? AND version = ?", progress.ID, expectedVersion).
+		Updates(map[string]interface{}{
+			"chapter_id":        progress.ChapterID,
+			"char_offset":       progress.Offset,
+			"cfi":               progress.CFI,
+			"percentage":        progress.Percentage,
+			"last_read_at":      progress.LastReadAt,
+			"device_id":         progress.DeviceID,
+			"device_updated_at": progress.DeviceUpdatedAt,
+			"version":           expectedVersion + 1,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	progress.Version = expectedVersion + 1
+	return true, nil
+}
+
+// 获取某台设备最后一次同步的状态
+func GetReadingDevice(db *gorm.DB, userID, bookID uint, deviceID string) (*ReadingDevice, error) {
+	var device ReadingDevice
+	if err := db.Where("user_id = ? AND book_id = ? AND device_id = ?", userID, bookID, deviceID).First(&device).Error; err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
+
+// 获取用户在某本书上所有设备的同步状态
+func GetReadingDevices(db *gorm.DB, userID, bookID uint) ([]ReadingDevice, error) {
+	var devices []ReadingDevice
+	if err := db.Where("user_id = ? AND book_id = ?", userID, bookID).Order("synced_at DESC").Find(&devices).Error; err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+// 创建或更新设备同步状态
+func UpsertReadingDevice(db *gorm.DB, device *ReadingDevice) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "book_id"}, {Name: "device_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"chapter_id", "char_offset", "cfi", "percentage", "client_updated_at", "seen_version", "synced_at"}),
+	}).Create(device).Error
+}
+
+// 获取用户正在阅读（未读完）的书籍，按最后阅读时间倒序
+func GetContinueReading(db *gorm.DB, user *User, limit int) ([]ContinueReadingItem, error) {
+	var progresses []ReadingProgress
+	if err := db.Where("user_id = ? AND percentage < ?", user.ID, 100).
+		Order("last_read_at DESC").
+		Limit(limit).
+		Find(&progresses).Error; err != nil {
+		return nil, err
+	}
+	if len(progresses) == 0 {
+		return []ContinueReadingItem{}, nil
+	}
+
+	bookIDs := make([]uint, len(progresses))
+	for i, progress := range progresses {
+		bookIDs[i] = progress.BookID
+	}
+
+	// 已经不可见的书籍不再出现在列表中
+	var books []Book
+	if err := db.Scopes(VisibleTo(user)).Where("id IN ?", bookIDs).Find(&books).Error; err != nil {
+		return nil, err
+	}
+	booksByID := make(map[uint]Book, len(books))
+	for _, book := range books {
+		booksByID[book.ID] = book
+	}
+
+	items := make([]ContinueReadingItem, 0, len(progresses))
+	for _, progress := range progresses {
+		book, ok := booksByID[progress.BookID]
+		if !ok {
+			continue
+		}
+		items = append(items, ContinueReadingItem{Book: book, Progress: progress})
+	}
+	return items, nil
+}
+
+// 删除书籍的所有阅读进度及设备同步状态
+func DeleteBookReadingProgress(db *gorm.DB, bookID uint) error {
+	if err := db.Where("book_id = ?", bookID).Delete(&ReadingDevice{}).Error; err != nil {
+		return err
+	}
+	return db.Where("book_id = ?", bookID).Delete(&ReadingProgress{}).Error
+}
@@ -0,0 +1,254 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultShelfNames 是用户第一次查看书架时自动创建的书架
+var DefaultShelfNames = []string{"To read", "Reading", "Finished"}
+
+var ErrBookAlreadyOnShelf = errors.New("book is already on the shelf")
+
+// Shelf 表示用户自定义的书架
+type Shelf struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID     uint      `gorm:"not null;index" json:"user_id"`
+	Name       string    `gorm:"size:100;not null" json:"name"`
+	Position   int       `gorm:"not null;default:0" json:"position"`
+	ShareToken string    `gorm:"size:64;index" json:"share_token,omitempty"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// ShelfItem 表示书架上的一本书及其排序
+type ShelfItem struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	ShelfID   uint      `gorm:"not null;uniqueIndex:idx_shelf_item_shelf_book" json:"shelf_id"`
+	BookID    uint      `gorm:"not null;uniqueIndex:idx_shelf_item_shelf_book;index" json:"book_id"`
+	Position  int       `gorm:"not null;default:0" json:"position"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+type ShelfRequest struct {
+	Name     string `json:"name"`
+	Position *int   `json:"position"`
+}
+
+type ShelfBookRequest struct {
+	BookID   uint `json:"book_id"`
+	ShelfID  uint `json:"shelf_id"` // 移动时的目标书架，为空表示在当前书架内调整顺序
+	Position *int `json:"position"`
+}
+
+// SetShared 开启或关闭只读分享链接
+func (s *Shelf) SetShared(shared bool) error {
+	if !shared {
+		s.ShareToken = ""
+		return nil
+	}
+	if s.ShareToken != "" {
+		return nil
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		return err
+	}
+	s.ShareToken = token
+	return nil
+}
+
+// 获取用户的书架，按位置排序
+func GetShelves(db *gorm.DB, userID uint) ([]Shelf, error) {
+	var shelves []Shelf
+	if err := db.Where("user_id = ?", userID).Order("position ASC, id ASC").Find(&shelves).Error; err != nil {
+		return nil, err
+	}
+	return shelves, nil
+}
+
+// 用户没有任何书架时创建默认书架
+func EnsureDefaultShelves(db *gorm.DB, userID uint) error {
+	var count int64
+	if err := db.Model(&Shelf{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	shelves := make([]Shelf, len(DefaultShelfNames))
+	for i, name := range DefaultShelfNames {
+		shelves[i] = Shelf{UserID: userID, Name: name, Position: i}
+	}
+	return db.Create(&shelves).Error
+}
+
+// 根据ID获取书架
+func GetShelfByID(db *gorm.DB, id uint) (*Shelf, error) {
+	var shelf Shelf
+	if err := db.First(&shelf, id).Error; err != nil {
+		return nil, err
+	}
+	return &shelf, nil
+}
+
+// 根据分享令牌获取书架
+func GetShelfByShareToken(db *gorm.DB, token string) (*Shelf, error) {
+	var shelf Shelf
+	if err := db.Where("share_token = ?", token).First(&shelf).Error; err != nil {
+		return nil, err
+	}
+	return &shelf, nil
+}
+
+// 创建书架，放在用户所有书架的最后
+func CreateShelf(db *gorm.DB, shelf *Shelf) error {
+	var count int64
+	if err := db.Model(&Shelf{}).Where("user_id = ?", shelf.UserID).Count(&count).Error; err != nil {
+		return err
+	}
+	shelf.Position = int(count)
+	return db.Create(shelf).Error
+}
+
+// 更新书架
+func UpdateShelf(db *gorm.DB, shelf *Shelf) error {
+	return db.Save(shelf).Error
+}
+
+// 将书架移动到用户书架列表中的指定位置
+func MoveShelf(db *gorm.DB, shelf *Shelf, position int) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		shelves, err := GetShelves(tx, shelf.UserID)
+		if err != nil {
+			return err
+		}
+
+		ordered := make([]uint, 0, len(shelves))
+		for _, s := range shelves {
+			if s.ID != shelf.ID {
+				ordered = append(ordered, s.ID)
+			}
+		}
+		ordered = insertAt(ordered, shelf.ID, position)
+
+		for i, id := range ordered {
+			if err := tx.Model(&Shelf{}).Where("id = ?", id).Update("position", i).Error; err != nil {
+				return err
+			}
+		}
+		shelf.Position = clampPosition(position, len(ordered)-1)
+		return nil
+	})
+}
+
+// 删除书架及其中的书籍条目
+func DeleteShelf(db *gorm.DB, id uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("shelf_id = ?", id).Delete(&ShelfItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&Shelf{}, id).Error
+	})
+}
+
+// 获取书架上的书籍ID，按位置排序
+func GetShelfBookIDs(db *gorm.DB, shelfID uint) ([]uint, error) {
+	var bookIDs []uint
+	if err := db.Model(&ShelfItem{}).Where("shelf_id = ?", shelfID).Order("position ASC, id ASC").Pluck("book_id", &bookIDs).Error; err != nil {
+		return nil, err
+	}
+	return bookIDs, nil
+}
+
+// 将书籍放到书架的指定位置，position 为空时放在最后
+func AddBookToShelf(db *gorm.DB, shelfID, bookID uint, position *int) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		bookIDs, err := GetShelfBookIDs(tx, shelfID)
+		if err != nil {
+			return err
+		}
+		for _, id := range bookIDs {
+			if id == bookID {
+				return ErrBookAlreadyOnShelf
+			}
+		}
+
+		if err := tx.Create(&ShelfItem{ShelfID: shelfID, BookID: bookID}).Error; err != nil {
+			return err
+		}
+
+		pos := len(bookIDs)
+		if position != nil {
+			pos = *position
+		}
+		return reorderShelf(tx, shelfID, insertAt(bookIDs, bookID, pos))
+	})
+}
+
+// 从书架上移除书籍
+func RemoveBookFromShelf(db *gorm.DB, shelfID, bookID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("shelf_id = ? AND book_id = ?", shelfID, bookID).Delete(&ShelfItem{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		bookIDs, err := GetShelfBookIDs(tx, shelfID)
+		if err != nil {
+			return err
+		}
+		return reorderShelf(tx, shelfID, bookIDs)
+	})
+}
+
+// 将书籍移动到目标书架（可以是同一书架）的指定位置
+func MoveBookOnShelves(db *gorm.DB, fromShelfID, toShelfID, bookID uint, position *int) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := RemoveBookFromShelf(tx, fromShelfID, bookID); err != nil {
+			return err
+		}
+		return AddBookToShelf(tx, toShelfID, bookID, position)
+	})
+}
+
+// 删除书籍在所有书架上的条目
+func DeleteBookShelfItems(db *gorm.DB, bookID uint) error {
+	return db.Where("book_id = ?", bookID).Delete(&ShelfItem{}).Error
+}
+
+// reorderShelf 按给定顺序重写书架上各书籍的位置
+func reorderShelf(db *gorm.DB, shelfID uint, bookIDs []uint) error {
+	for i, id := range bookIDs {
+		if err := db.Model(&ShelfItem{}).Where("shelf_id = ? AND book_id = ?", shelfID, id).Update("position", i).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertAt 将 id 插入到切片的指定位置，越界时放在两端
+func insertAt(ids []uint, id uint, position int) []uint {
+	position = clampPosition(position, len(ids))
+	result := make([]uint, 0, len(ids)+1)
+	result = append(result, ids[:position]...)
+	result = append(result, id)
+	return append(result, ids[position:]...)
+}
+
+func clampPosition(position, max int) int {
+	if position < 0 {
+		return 0
+	}
+	if position > max {
+		return max
+	}
+	return position
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -36,20 +37,30 @@ func (rd *BookReviewDataList) Scan(value interface{}) error {
 		return errors.New("scanning null value")
 	}
 
-	bytes, ok := value.([]byte)
-	if !ok {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, &rd.Items)
+	case string:
+		return json.Unmarshal([]byte(v), &rd.Items)
+	default:
 		return errors.New("invalid scan source")
 	}
-
-	return json.Unmarshal(bytes, &rd.Items)
 }
 
-// 修改 Review 结构体
+// Review 是生成的书籍摘要。根据书籍生成的摘要以 BookID 和语言区分，
+// 只根据书名和作者生成的摘要 BookID 为空，以书名、作者和语言区分。
 type Review struct {
-	ID         uint64             `json:"id" gorm:"primaryKey"`
-	UserID     uint64             `json:"user_id"`
-	BookID     uint64             `json:"book_id"`
-	ReviewData BookReviewDataList `json:"review_data" gorm:"type:json"`
+	ID            uint64             `json:"id" gorm:"primaryKey"`
+	UserID        uint64             `json:"user_id"`
+	BookID        *uint64            `json:"book_id" gorm:"index"`
+	Title         string             `json:"title" gorm:"size:255;index:idx_reviews_title_author"`
+	Author        string             `json:"author" gorm:"size:255;index:idx_reviews_title_author"`
+	Language      string             `json:"language" gorm:"size:20;not null;default:zh"` // 早期的摘要都是中文
+	Model         string             `json:"model" gorm:"size:100"`
+	PromptVersion string             `json:"prompt_version" gorm:"size:50"`
+	ReviewData    BookReviewDataList `json:"review_data" gorm:"type:json"`
+	CreatedAt     time.Time          `json:"created_at"`
+	UpdatedAt     time.Time          `json:"updated_at"`
 }
 
 func GetReviews(db *gorm.DB) ([]Review, error) {
@@ -60,10 +71,30 @@ func GetReviews(db *gorm.DB) ([]Review, error) {
 	return reviews, nil
 }
 
-// 修改 GetReviewByTitle 函数
-func GetReviewByTitle(db *gorm.DB, title string) (*Review, error) {
+// GetBookReview 返回根据书籍生成的指定语言的摘要
+func GetBookReview(db *gorm.DB, bookID uint, language string) (*Review, error) {
+	var review Review
+	err := db.Where("book_id = ? AND language = ?", bookID, language).Order("id DESC").First(&review).Error
+	if err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+// GetTitleReview 返回只根据书名和作者生成的指定语言的摘要
+func GetTitleReview(db *gorm.DB, title, author, language string) (*Review, error) {
 	var review Review
-	if err := db.Where("JSON_EXTRACT(review_data, '$[0].title') = ?", title).First(&review).Error; err != nil {
+	err := db.Where("book_id IS NULL AND title = ? AND author = ? AND language = ?", title, author, language).
+		Order("id DESC").First(&review).Error
+	if err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+func GetReviewByID(db *gorm.DB, id uint64) (*Review, error) {
+	var review Review
+	if err := db.First(&review, id).Error; err != nil {
 		return nil, err
 	}
 	return &review, nil
@@ -89,3 +120,8 @@ func DeleteReview(db *gorm.DB, review *Review) error {
 	}
 	return nil
 }
+
+// DeleteBookReviews 删除书籍所有语言的摘要
+func DeleteBookReviews(db *gorm.DB, bookID uint) error {
+	return db.Where("book_id = ?", bookID).Delete(&Review{}).Error
+}
@@ -2,6 +2,15 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
+)
+
+// User roles
+const (
+	RoleReader   = "reader"
+	RoleUploader = "uploader"
+	RoleAdmin    = "admin"
 )
 
 // User represents a user in the book reading application
@@ -10,6 +19,61 @@ type User struct {
 	Username  string    `json:"username" gorm:"unique;not null"`
 	Email     string    `json:"email" gorm:"unique;not null"`
 	Password  string    `json:"password" gorm:"not null"`
+	Role      string    `json:"role" gorm:"size:20;not null;default:reader"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
+
+type UpdateRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// IsValidRole 判断角色名是否合法
+func IsValidRole(role string) bool {
+	return role == RoleReader || role == RoleUploader || role == RoleAdmin
+}
+
+// HasRole 判断用户是否拥有给定角色之一，管理员拥有所有权限
+func (u *User) HasRole(roles ...string) bool {
+	if u.Role == RoleAdmin {
+		return true
+	}
+	for _, role := range roles {
+		if u.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+// 根据ID获取用户
+func GetUserByID(db *gorm.DB, id uint) (*User, error) {
+	var user User
+	if err := db.First(&user, id).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// 根据邮箱获取用户
+func GetUserByEmail(db *gorm.DB, email string) (*User, error) {
+	var user User
+	if err := db.Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// 获取用户列表
+func GetUsers(db *gorm.DB) ([]User, error) {
+	var users []User
+	if err := db.Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// 更新用户角色
+func UpdateUserRole(db *gorm.DB, id uint, role string) error {
+	return db.Model(&User{}).Where("id = ?", id).Update("role", role).Error
+}
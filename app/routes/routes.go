@@ -6,6 +6,8 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/sd0ric4/book-reader-backend/app/controllers"
+	middlewares "github.com/sd0ric4/book-reader-backend/app/middleewares"
+	"github.com/sd0ric4/book-reader-backend/app/models"
 )
 
 type TextResponse struct {
@@ -13,21 +15,89 @@ type TextResponse struct {
 }
 
 func SetupRoutes(r *gin.Engine) {
+	auth := middlewares.AuthMiddleware()
+	optionalAuth := middlewares.OptionalAuthMiddleware()
+
 	// Upload
-	r.POST("/books/upload", controllers.UploadBook)
-	r.POST("/books/summarize", controllers.SummarizeBook)
+	r.POST("/books/upload", auth, middlewares.RequireRole(models.RoleUploader), controllers.UploadBook)
+	r.POST("/books/summarize", auth, controllers.SummarizeBook)
+	r.POST("/books/summarize/stream", auth, controllers.StreamSummary)
+	r.POST("/books/:id/summary/regenerate", auth, controllers.RegenerateSummary)
+	r.DELETE("/books/:id/summary", auth, controllers.InvalidateSummary)
+	r.POST("/books/:id/ask", auth, controllers.AskBook)
+	// Background jobs
+	r.GET("/jobs/:id", auth, controllers.GetJob)
+	r.POST("/jobs/:id/retry", auth, controllers.RetryJob)
 	// Recommendation
 	r.POST("/books/recommend", controllers.RecommendBooksHandler)
+	r.GET("/users/me/recommendations", auth, controllers.GetUserRecommendations)
+	r.GET("/books/:id/similar", optionalAuth, controllers.GetSimilarBooks)
 	// Book related routes
-	r.GET("/books/list", controllers.GetBooks)
-	r.GET("/books/:id", controllers.GetBookByID)
-	r.PUT("/books/:id", controllers.UpdateBook)
-	r.DELETE("/books/:id", controllers.DeleteBook)
+	r.GET("/books/list", optionalAuth, controllers.GetBooks)
+	r.GET("/books/:id", optionalAuth, controllers.GetBookByID)
+	r.PUT("/books/:id", auth, controllers.UpdateBook)
+	r.DELETE("/books/:id", auth, controllers.DeleteBook)
+	// Search
+	r.GET("/search", optionalAuth, controllers.SearchBooks)
+	r.GET("/books/:id/search", optionalAuth, controllers.FindInBook)
+
+	// Reading progress
+	r.GET("/books/:id/progress", auth, controllers.GetReadingProgress)
+	r.PUT("/books/:id/progress", auth, controllers.UpdateReadingProgress)
+	r.POST("/books/:id/progress/sync", auth, controllers.SyncReadingProgress)
+	r.GET("/books/:id/progress/devices", auth, controllers.GetReadingDevices)
+	r.GET("/users/me/continue-reading", auth, controllers.GetContinueReading)
+
+	// Bookmarks and highlights
+	r.GET("/books/:id/annotations", auth, controllers.GetBookAnnotations)
+	r.GET("/books/:id/annotations/export", auth, controllers.ExportBookAnnotations)
+	r.GET("/users/me/annotations/export", auth, controllers.ExportUserAnnotations)
+	r.POST("/books/:id/bookmarks", auth, controllers.CreateBookmark)
+	r.POST("/books/:id/highlights", auth, controllers.CreateHighlight)
+	r.GET("/bookmarks/:id", auth, controllers.GetBookmark)
+	r.PUT("/bookmarks/:id", auth, controllers.UpdateBookmark)
+	r.DELETE("/bookmarks/:id", auth, controllers.DeleteBookmark)
+	r.GET("/highlights/:id", auth, controllers.GetHighlight)
+	r.PUT("/highlights/:id", auth, controllers.UpdateHighlight)
+	r.DELETE("/highlights/:id", auth, controllers.DeleteHighlight)
+
+	// Ratings
+	r.GET("/books/:id/rating", auth, controllers.GetBookRating)
+	r.PUT("/books/:id/rating", auth, controllers.RateBook)
+	r.DELETE("/books/:id/rating", auth, controllers.DeleteBookRating)
+
+	// Favorites and shelves
+	r.POST("/books/:id/favorite", auth, controllers.AddFavorite)
+	r.DELETE("/books/:id/favorite", auth, controllers.RemoveFavorite)
+	r.GET("/users/me/favorites", auth, controllers.GetFavorites)
+	shelves := r.Group("/shelves", auth)
+	shelves.GET("", controllers.GetShelves)
+	shelves.POST("", controllers.CreateShelf)
+	shelves.PUT("/:id", controllers.UpdateShelf)
+	shelves.DELETE("/:id", controllers.DeleteShelf)
+	shelves.GET("/:id/books", controllers.GetShelfBooks)
+	shelves.POST("/:id/books", controllers.AddShelfBook)
+	shelves.PUT("/:id/books/:book_id", controllers.MoveShelfBook)
+	shelves.DELETE("/:id/books/:book_id", controllers.RemoveShelfBook)
+	shelves.POST("/:id/share", controllers.ShareShelf)
+	shelves.DELETE("/:id/share", controllers.UnshareShelf)
+	r.GET("/shared/shelves/:token", controllers.GetSharedShelf)
 
 	// User related routes
 	r.POST("/users/register", controllers.Register)
 	r.POST("/users/login", controllers.Login)
-	r.PUT("/users/change-password", controllers.ChangePassword)
+	r.POST("/users/refresh", controllers.RefreshToken)
+	r.POST("/users/logout", auth, controllers.Logout)
+	r.POST("/users/logout-all", auth, controllers.LogoutAll)
+	r.GET("/users/sessions", auth, controllers.GetSessions)
+	r.DELETE("/users/sessions/:id", auth, controllers.RevokeSession)
+	r.PUT("/users/change-password", auth, controllers.ChangePassword)
+
+	// Admin routes
+	admin := r.Group("/admin", auth, middlewares.RequireRole(models.RoleAdmin))
+	admin.GET("/users", controllers.GetUsers)
+	admin.PUT("/users/:id/role", controllers.UpdateUserRole)
+	admin.POST("/search/reindex", controllers.ReindexSearch)
 	r.GET("/text", func(c *gin.Context) {
 		// 读取文本文件
 		content, err := os.ReadFile("../config/book.txt")
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sd0ric4/book-reader-backend/app/models"
+	"gorm.io/gorm"
+)
+
+// 锚点前后文保存的字符数
+const anchorContextLen = 32
+
+var ErrInvalidAnchor = errors.New("anchor is outside the chapter content")
+
+// NewTextAnchor 根据章节内容和字符偏移生成锚点，保存选中的原文和前后文
+func NewTextAnchor(chapter *models.BookChapter, start, end int, cfi string) (models.TextAnchor, error) {
+	runes := []rune(chapter.ChapterContent)
+	if start < 0 || end < start || end > len(runes) {
+		return models.TextAnchor{}, ErrInvalidAnchor
+	}
+
+	return models.TextAnchor{
+		ChapterID:   chapter.ID,
+		StartOffset: start,
+		EndOffset:   end,
+		CFI:         cfi,
+		Quote:       string(runes[start:end]),
+		Prefix:      string(runes[max(0, start-anchorContextLen):start]),
+		Suffix:      string(runes[end:min(len(runes), end+anchorContextLen)]),
+	}, nil
+}
+
+// ResolveAnchor 在章节内容中重新定位锚点，返回新的起止偏移。
+// 依次尝试：原位置未变；按原文查找并用前后文挑选最佳位置；原文被修改时用前后文夹出新范围。
+func ResolveAnchor(content string, anchor models.TextAnchor) (start, end int, ok bool) {
+	runes := []rune(content)
+	quote := []rune(anchor.Quote)
+	prefix := []rune(anchor.Prefix)
+	suffix := []rune(anchor.Suffix)
+
+	if matchesAt(runes, anchor.StartOffset, quote, prefix, suffix) {
+		return anchor.StartOffset, anchor.StartOffset + len(quote), true
+	}
+
+	if len(quote) > 0 {
+		if start, ok := bestMatch(runes, quote, prefix, suffix, anchor.StartOffset); ok {
+			return start, start + len(quote), true
+		}
+		return betweenContext(runes, prefix, suffix, len(quote), anchor.StartOffset)
+	}
+
+	// 书签没有原文，只能依靠前后文定位
+	if len(prefix)+len(suffix) == 0 {
+		return 0, 0, false
+	}
+	context := append(append([]rune{}, prefix...), suffix...)
+	if pos, ok := bestMatch(runes, context, nil, nil, anchor.StartOffset-len(prefix)); ok {
+		return pos + len(prefix), pos + len(prefix), true
+	}
+	if len(suffix) > 0 {
+		if pos, ok := bestMatch(runes, suffix, prefix, nil, anchor.StartOffset); ok {
+			return pos, pos, true
+		}
+	}
+	if len(prefix) > 0 {
+		if pos, ok := bestMatch(runes, prefix, nil, suffix, anchor.StartOffset-len(prefix)); ok {
+			return pos + len(prefix), pos + len(prefix), true
+		}
+	}
+	return 0, 0, false
+}
+
+// ReanchorBookAnnotations 检查用户在某本书中的书签和高亮能否在当前章节内容中找到，
+// 位置发生变化的更新锚点，找不到的标记为孤立，返回更新后的标注
+func ReanchorBookAnnotations(db *gorm.DB, userID, bookID uint) (*models.BookAnnotations, error) {
+	bookmarks, err := models.GetBookmarks(db, userID, bookID)
+	if err != nil {
+		return nil, err
+	}
+	highlights, err := models.GetHighlights(db, userID, bookID)
+	if err != nil {
+		return nil, err
+	}
+	annotations := &models.BookAnnotations{Bookmarks: bookmarks, Highlights: highlights}
+	if len(bookmarks) == 0 && len(highlights) == 0 {
+		return annotations, nil
+	}
+
+	chapters, err := models.GetChaptersByBookID(db, bookID)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := false
+	for i := range bookmarks {
+		if relocateAnchor(&bookmarks[i].TextAnchor, chapters) {
+			if err := models.UpdateBookmarkAnchor(db, bookmarks[i].ID, bookmarks[i].TextAnchor); err != nil {
+				return nil, err
+			}
+			changed = true
+		}
+	}
+	for i := range highlights {
+		if relocateAnchor(&highlights[i].TextAnchor, chapters) {
+			if err := models.UpdateHighlightAnchor(db, highlights[i].ID, highlights[i].TextAnchor); err != nil {
+				return nil, err
+			}
+			changed = true
+		}
+	}
+
+	// 位置变化后重新读取以保持排序
+	if changed {
+		if annotations.Bookmarks, err = models.GetBookmarks(db, userID, bookID); err != nil {
+			return nil, err
+		}
+		if annotations.Highlights, err = models.GetHighlights(db, userID, bookID); err != nil {
+			return nil, err
+		}
+	}
+	return annotations, nil
+}
+
+// relocateAnchor 先在原章节中定位，章节被重新提取（ID变化）后再到其他章节中查找，返回锚点是否有变化
+func relocateAnchor(anchor *models.TextAnchor, chapters []models.BookChapter) bool {
+	ordered := make([]models.BookChapter, 0, len(chapters))
+	for _, chapter := range chapters {
+		if chapter.ID == anchor.ChapterID {
+			ordered = append([]models.BookChapter{chapter}, ordered...)
+		} else {
+			ordered = append(ordered, chapter)
+		}
+	}
+
+	resolved := *anchor
+	resolved.Orphaned = true
+	for _, chapter := range ordered {
+		if start, end, ok := ResolveAnchor(chapter.ChapterContent, *anchor); ok {
+			resolved.ChapterID = chapter.ID
+			resolved.StartOffset = start
+			resolved.EndOffset = end
+			resolved.Orphaned = false
+			break
+		}
+	}
+
+	if resolved == *anchor {
+		return false
+	}
+	*anchor = resolved
+	return true
+}
+
+// matchesAt 判断原文是否仍在原位置；没有原文的书签要求前后文也完全一致
+func matchesAt(runes []rune, start int, quote, prefix, suffix []rune) bool {
+	if start < 0 || start+len(quote) > len(runes) {
+		return false
+	}
+	if string(runes[start:start+len(quote)]) != string(quote) {
+		return false
+	}
+	if len(quote) > 0 {
+		return true
+	}
+	return commonSuffixLen(prefix, runes[:start]) == len(prefix) &&
+		commonPrefixLen(suffix, runes[start:]) == len(suffix)
+}
+
+// bestMatch 查找 needle 的所有出现位置，选出前后文最吻合的一个，相同时取离 hint 最近的
+func bestMatch(runes, needle, prefix, suffix []rune, hint int) (int, bool) {
+	best, bestScore, bestDistance := -1, -1, 0
+	for _, pos := range indexAll(string(runes), string(needle)) {
+		score := commonSuffixLen(prefix, runes[:pos]) + commonPrefixLen(suffix, runes[pos+len(needle):])
+		distance := abs(pos - hint)
+		if score > bestScore || (score == bestScore && distance < bestDistance) {
+			best, bestScore, bestDistance = pos, score, distance
+		}
+	}
+	return best, best >= 0
+}
+
+// betweenContext 在原文被修改时，用完整的前文和后文夹出新的范围，长度变化过大视为失败
+func betweenContext(runes, prefix, suffix []rune, quoteLen, hint int) (int, int, bool) {
+	if len(prefix) == 0 && len(suffix) == 0 {
+		return 0, 0, false
+	}
+
+	starts := []int{0}
+	if len(prefix) > 0 {
+		starts = indexAll(string(runes), string(prefix))
+		for i := range starts {
+			starts[i] += len(prefix)
+		}
+	}
+
+	bestStart, bestEnd, bestDistance := -1, -1, 0
+	for _, start := range starts {
+		end := len(runes)
+		if len(suffix) > 0 {
+			offsets := indexAll(string(runes[start:]), string(suffix))
+			if len(offsets) == 0 {
+				continue
+			}
+			end = start + offsets[0]
+		}
+
+		length := end - start
+		if length == 0 || length < quoteLen/2 || length > quoteLen*2 {
+			continue
+		}
+		if distance := abs(start - hint); bestStart < 0 || distance < bestDistance {
+			bestStart, bestEnd, bestDistance = start, end, distance
+		}
+	}
+	return bestStart, bestEnd, bestStart >= 0
+}
+
+// indexAll 返回 substr 在 s 中所有出现位置的字符偏移，允许重叠
+func indexAll(s, substr string) []int {
+	var offsets []int
+	if substr == "" {
+		return offsets
+	}
+
+	byteOffset, runeOffset := 0, 0
+	for {
+		i := strings.Index(s[byteOffset:], substr)
+		if i < 0 {
+			return offsets
+		}
+		runeOffset += utf8.RuneCountInString(s[byteOffset : byteOffset+i])
+		offsets = append(offsets, runeOffset)
+
+		_, size := utf8.DecodeRuneInString(s[byteOffset+i:])
+		byteOffset += i + size
+		runeOffset++
+	}
+}
+
+// commonSuffixLen 返回 a 与 text 末尾相同部分的长度
+func commonSuffixLen(a, text []rune) int {
+	n := 0
+	for n < len(a) && n < len(text) && a[len(a)-1-n] == text[len(text)-1-n] {
+		n++
+	}
+	return n
+}
+
+// commonPrefixLen 返回 a 与 text 开头相同部分的长度
+func commonPrefixLen(a, text []rune) int {
+	n := 0
+	for n < len(a) && n < len(text) && a[n] == text[n] {
+		n++
+	}
+	return n
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,305 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sd0ric4/book-reader-backend/app/models"
+	"gorm.io/gorm"
+)
+
+// 标注导出格式
+const (
+	ExportFormatMarkdown = "markdown"
+	ExportFormatJSON     = "json"
+	ExportFormatCSV      = "csv" // 与 Readwise 导入格式兼容
+)
+
+// 找不到所在章节的高亮归到这一组
+const unknownChapterTitle = "Unknown chapter"
+
+// Readwise CSV 的列，最后的 Chapter 列为额外信息
+var readwiseCSVHeader = []string{"Highlight", "Title", "Author", "URL", "Note", "Location", "Date", "Chapter"}
+
+// ExportedHighlight 是导出的一条高亮
+type ExportedHighlight struct {
+	ID       uint   `json:"id"`
+	Quote    string `json:"quote"`
+	Note     string `json:"note"`
+	Color    string `json:"color"`
+	Label    string `json:"label"`
+	Location int    `json:"location"` // 章节内的字符偏移
+	// 原文已无法在章节中找到，位置可能不准确
+	Orphaned  bool      `json:"orphaned"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ExportedChapter 是导出时按章节分组的高亮，Index 从1开始，0 表示章节未知
+type ExportedChapter struct {
+	ChapterID  uint                `json:"chapter_id"`
+	Index      int                 `json:"index"`
+	Title      string              `json:"title"`
+	Highlights []ExportedHighlight `json:"highlights"`
+}
+
+// ExportedBook 是导出的一本书的全部高亮
+type ExportedBook struct {
+	BookID   uint              `json:"book_id"`
+	Title    string            `json:"title"`
+	Author   string            `json:"author"`
+	Chapters []ExportedChapter `json:"chapters"`
+}
+
+// IsValidExportFormat 判断导出格式是否合法
+func IsValidExportFormat(format string) bool {
+	return format == ExportFormatMarkdown || format == ExportFormatJSON || format == ExportFormatCSV
+}
+
+// ExportContentType 返回导出格式对应的 Content-Type
+func ExportContentType(format string) string {
+	switch format {
+	case ExportFormatJSON:
+		return "application/json; charset=utf-8"
+	case ExportFormatCSV:
+		return "text/csv; charset=utf-8"
+	default:
+		return "text/markdown; charset=utf-8"
+	}
+}
+
+// ExportFileName 返回导出文件名
+func ExportFileName(name, format string) string {
+	switch format {
+	case ExportFormatJSON:
+		return name + ".json"
+	case ExportFormatCSV:
+		return name + ".csv"
+	default:
+		return name + ".md"
+	}
+}
+
+// BuildExportedBook 加载用户在某本书中的高亮并按章节分组，加载前会先重新定位锚点
+func BuildExportedBook(db *gorm.DB, userID uint, book models.Book) (*ExportedBook, error) {
+	annotations, err := ReanchorBookAnnotations(db, userID, book.ID)
+	if err != nil {
+		return nil, err
+	}
+	exported := &ExportedBook{BookID: book.ID, Title: book.Title, Author: book.Author, Chapters: []ExportedChapter{}}
+	if len(annotations.Highlights) == 0 {
+		return exported, nil
+	}
+
+	chapters, err := models.GetChapterTitles(db, book.ID)
+	if err != nil {
+		return nil, err
+	}
+	chapterIndex := make(map[uint]int, len(chapters))
+	for i, chapter := range chapters {
+		chapterIndex[chapter.ID] = i
+	}
+
+	groups := make([][]ExportedHighlight, len(chapters))
+	var unknown []ExportedHighlight
+	for _, highlight := range annotations.Highlights {
+		item := ExportedHighlight{
+			ID:        highlight.ID,
+			Quote:     highlight.Quote,
+			Note:      highlight.Note,
+			Color:     highlight.Color,
+			Label:     highlight.Label,
+			Location:  highlight.StartOffset,
+			Orphaned:  highlight.Orphaned,
+			CreatedAt: highlight.CreatedAt,
+		}
+		if i, ok := chapterIndex[highlight.ChapterID]; ok {
+			groups[i] = append(groups[i], item)
+		} else {
+			unknown = append(unknown, item)
+		}
+	}
+
+	for i, group := range groups {
+		if len(group) > 0 {
+			exported.Chapters = append(exported.Chapters, ExportedChapter{
+				ChapterID:  chapters[i].ID,
+				Index:      i + 1,
+				Title:      chapters[i].ChapterName,
+				Highlights: group,
+			})
+		}
+	}
+	if len(unknown) > 0 {
+		exported.Chapters = append(exported.Chapters, ExportedChapter{Title: unknownChapterTitle, Highlights: unknown})
+	}
+	return exported, nil
+}
+
+// ExportAnnotations 逐本加载书籍的高亮并以指定格式写入 w，每写完一本书刷新一次输出，
+// 避免一次性把用户的全部标注读入内存
+func ExportAnnotations(db *gorm.DB, w io.Writer, format string, userID uint, books []models.Book) error {
+	writer := newAnnotationWriter(w, format)
+	for _, book := range books {
+		exported, err := BuildExportedBook(db, userID, book)
+		if err != nil {
+			return err
+		}
+		if len(exported.Chapters) == 0 {
+			continue
+		}
+		if err := writer.writeBook(exported); err != nil {
+			return err
+		}
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+	}
+	return writer.close()
+}
+
+// annotationWriter 按某种格式流式写出导出的书籍
+type annotationWriter interface {
+	writeBook(book *ExportedBook) error
+	close() error
+}
+
+func newAnnotationWriter(w io.Writer, format string) annotationWriter {
+	switch format {
+	case ExportFormatJSON:
+		return &jsonAnnotationWriter{w: w}
+	case ExportFormatCSV:
+		return &csvAnnotationWriter{w: csv.NewWriter(w)}
+	default:
+		return &markdownAnnotationWriter{w: w}
+	}
+}
+
+type markdownAnnotationWriter struct {
+	w     io.Writer
+	count int
+}
+
+func (m *markdownAnnotationWriter) writeBook(book *ExportedBook) error {
+	var sb strings.Builder
+	if m.count > 0 {
+		sb.WriteString("\n---\n\n")
+	}
+	m.count++
+
+	fmt.Fprintf(&sb, "# %s\n\n", book.Title)
+	if book.Author != "" {
+		fmt.Fprintf(&sb, "*%s*\n\n", book.Author)
+	}
+	for _, chapter := range book.Chapters {
+		fmt.Fprintf(&sb, "## %s\n\n", chapter.Title)
+		for _, highlight := range chapter.Highlights {
+			for _, line := range strings.Split(highlight.Quote, "\n") {
+				fmt.Fprintf(&sb, "> %s\n", line)
+			}
+			sb.WriteString("\n")
+			if chapter.Index > 0 {
+				fmt.Fprintf(&sb, "- Location: chapter %d, character %d\n", chapter.Index, highlight.Location)
+			}
+			fmt.Fprintf(&sb, "- Created: %s\n", highlight.CreatedAt.Format(time.RFC3339))
+			if highlight.Label != "" {
+				fmt.Fprintf(&sb, "- Label: %s\n", highlight.Label)
+			}
+			if highlight.Note != "" {
+				fmt.Fprintf(&sb, "- Note: %s\n", strings.ReplaceAll(highlight.Note, "\n", "\n  "))
+			}
+			sb.WriteString("\n")
+		}
+	}
+
+	_, err := io.WriteString(m.w, sb.String())
+	return err
+}
+
+func (m *markdownAnnotationWriter) close() error {
+	return nil
+}
+
+// jsonAnnotationWriter 输出书籍的 JSON 数组，逐本写出元素
+type jsonAnnotationWriter struct {
+	w     io.Writer
+	count int
+}
+
+func (j *jsonAnnotationWriter) writeBook(book *ExportedBook) error {
+	separator := ","
+	if j.count == 0 {
+		separator = "["
+	}
+	j.count++
+
+	data, err := json.Marshal(book)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(j.w, separator); err != nil {
+		return err
+	}
+	_, err = j.w.Write(data)
+	return err
+}
+
+func (j *jsonAnnotationWriter) close() error {
+	end := "]"
+	if j.count == 0 {
+		end = "[]"
+	}
+	_, err := io.WriteString(j.w, end)
+	return err
+}
+
+type csvAnnotationWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (c *csvAnnotationWriter) writeHeader() error {
+	if c.headerWritten {
+		return nil
+	}
+	c.headerWritten = true
+	return c.w.Write(readwiseCSVHeader)
+}
+
+func (c *csvAnnotationWriter) writeBook(book *ExportedBook) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	for _, chapter := range book.Chapters {
+		for _, highlight := range chapter.Highlights {
+			record := []string{
+				highlight.Quote,
+				book.Title,
+				book.Author,
+				"",
+				highlight.Note,
+				strconv.Itoa(highlight.Location),
+				highlight.CreatedAt.UTC().Format("2006-01-02 15:04:05"),
+				chapter.Title,
+			}
+			if err := c.w.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvAnnotationWriter) close() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
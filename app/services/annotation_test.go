@@ -0,0 +1,99 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sd0ric4/book-reader-backend/app/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveAnchor(t *testing.T) {
+	original := "春眠不觉晓，处处闻啼鸟。夜来风雨声，花落知多少。春眠不觉晓。"
+	chapter := &models.BookChapter{ID: 1, ChapterContent: original}
+
+	// 高亮第一个“春眠不觉晓”
+	highlight, err := NewTextAnchor(chapter, 0, 5, "")
+	require.NoError(t, err)
+	assert.Equal(t, "春眠不觉晓", highlight.Quote)
+	assert.Equal(t, "", highlight.Prefix)
+
+	// 高亮第二个“春眠不觉晓”，插入文字后离原偏移更近的是第一个
+	repeated, err := NewTextAnchor(chapter, 24, 29, "")
+	require.NoError(t, err)
+
+	// 书签在“夜来”之前
+	bookmark, err := NewTextAnchor(chapter, 12, 12, "")
+	require.NoError(t, err)
+
+	_, err = NewTextAnchor(chapter, 5, 100, "")
+	assert.ErrorIs(t, err, ErrInvalidAnchor)
+
+	testCases := []struct {
+		name        string
+		content     string
+		anchor      models.TextAnchor
+		expectStart int
+		expectEnd   int
+		expectOK    bool
+	}{
+		{
+			name:        "Unchanged content",
+			content:     original,
+			anchor:      highlight,
+			expectStart: 0,
+			expectEnd:   5,
+			expectOK:    true,
+		},
+		{
+			name:        "Text inserted before the quote",
+			content:     "序言。" + original,
+			anchor:      highlight,
+			expectStart: 3,
+			expectEnd:   8,
+			expectOK:    true,
+		},
+		{
+			name:        "Repeated quote is disambiguated by context",
+			content:     strings.Repeat("一", 20) + original,
+			anchor:      repeated,
+			expectStart: 44,
+			expectEnd:   49,
+			expectOK:    true,
+		},
+		{
+			name:        "Quote edited between unchanged context",
+			content:     "序言。春眠不知晓，处处闻啼鸟。",
+			anchor:      models.TextAnchor{StartOffset: 3, EndOffset: 8, Quote: "春眠不觉晓", Prefix: "序言。", Suffix: "，处处闻啼鸟"},
+			expectStart: 3,
+			expectEnd:   8,
+			expectOK:    true,
+		},
+		{
+			name:        "Bookmark follows its context",
+			content:     "前言。" + original,
+			anchor:      bookmark,
+			expectStart: 15,
+			expectEnd:   15,
+			expectOK:    true,
+		},
+		{
+			name:     "Quote removed",
+			content:  "完全不同的内容",
+			anchor:   highlight,
+			expectOK: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			start, end, ok := ResolveAnchor(tc.content, tc.anchor)
+			assert.Equal(t, tc.expectOK, ok)
+			if tc.expectOK {
+				assert.Equal(t, tc.expectStart, start)
+				assert.Equal(t, tc.expectEnd, end)
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+package services
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sd0ric4/book-reader-backend/app/models"
+	"github.com/sd0ric4/book-reader-backend/app/services/llm"
+	"github.com/sd0ric4/book-reader-backend/app/services/prompts"
+	"github.com/sd0ric4/book-reader-backend/app/services/rag"
+	"github.com/sd0ric4/book-reader-backend/app/services/tokenizer"
+	"gorm.io/gorm"
+)
+
+// 回答问题时的预算
+const (
+	answerTokens = 500 // 答案的最大输出
+	askPassages  = 6   // 最多检索的段落数
+)
+
+var ErrNoPassages = errors.New("no relevant passages in the chapters read")
+
+// Citation 是答案引用的段落，Index 对应答案中的 [n]，Start/End 为段落在章节中的字符偏移
+type Citation struct {
+	Index       int    `json:"index"`
+	ChapterID   uint   `json:"chapter_id"`
+	ChapterName string `json:"chapter_name"`
+	Start       int    `json:"start"`
+	End         int    `json:"end"`
+	Text        string `json:"text"`
+}
+
+// BookAnswer 是对读者问题的回答
+type BookAnswer struct {
+	Question  string     `json:"question"`
+	Answer    string     `json:"answer"`
+	Citations []Citation `json:"citations"`
+	Model     string     `json:"model"`
+}
+
+// passageIndex 是一本书的段落索引，positions 为章节ID到章节顺序的映射
+type passageIndex struct {
+	bookID      uint
+	fingerprint string
+	index       *rag.Index
+	positions   map[uint]int
+}
+
+// 最多缓存的书籍段落索引数，超出时淘汰最久未使用的
+const maxPassageIndexes = 64
+
+var (
+	passageIndexes  = newPassageCache(maxPassageIndexes)
+	citationPattern = regexp.MustCompile(`\[(\d+)\]`)
+)
+
+// passageCache 是按最近使用淘汰的段落索引缓存。同一本书同一版本的索引同时只建立一次，
+// 其他请求等待建立完成后共用结果。
+type passageCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // 元素为 *passageIndex，最近使用的在前
+	entries  map[uint]*list.Element
+	building map[string]*passageBuild
+}
+
+// passageBuild 是正在建立的索引
+type passageBuild struct {
+	done  chan struct{}
+	index *passageIndex
+	err   error
+}
+
+func newPassageCache(capacity int) *passageCache {
+	return &passageCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[uint]*list.Element),
+		building: make(map[string]*passageBuild),
+	}
+}
+
+// get 返回书籍指纹为 fingerprint 的索引，没有缓存或已过期时调用 build 建立
+func (c *passageCache) get(bookID uint, fingerprint string, build func() (*passageIndex, error)) (*passageIndex, error) {
+	c.mu.Lock()
+	if e, ok := c.entries[bookID]; ok && e.Value.(*passageIndex).fingerprint == fingerprint {
+		c.order.MoveToFront(e)
+		c.mu.Unlock()
+		return e.Value.(*passageIndex), nil
+	}
+	key := fmt.Sprintf("%d:%s", bookID, fingerprint)
+	if b, ok := c.building[key]; ok {
+		c.mu.Unlock()
+		<-b.done
+		return b.index, b.err
+	}
+	b := &passageBuild{done: make(chan struct{})}
+	c.building[key] = b
+	c.mu.Unlock()
+
+	b.index, b.err = build()
+
+	c.mu.Lock()
+	delete(c.building, key)
+	if b.err == nil {
+		c.put(b.index)
+	}
+	c.mu.Unlock()
+	close(b.done)
+	return b.index, b.err
+}
+
+func (c *passageCache) put(index *passageIndex) {
+	if e, ok := c.entries[index.bookID]; ok {
+		e.Value = index
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[index.bookID] = c.order.PushFront(index)
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*passageIndex).bookID)
+	}
+}
+
+func (c *passageCache) remove(bookID uint) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[bookID]; ok {
+		c.order.Remove(e)
+		delete(c.entries, bookID)
+	}
+}
+
+// AskBook 根据读者已读的内容回答关于书籍的问题：从读者阅读进度之前的章节中检索相关段落，
+// 交给模型回答并返回引用的段落。没有检索到段落时返回 ErrNoPassages，不请求模型。
+func AskBook(ctx context.Context, db *gorm.DB, provider llm.Provider, book *models.Book, userID uint, question string) (*BookAnswer, error) {
+	index, err := getPassageIndex(db, book)
+	if err != nil {
+		return nil, err
+	}
+	bound, err := readingBound(db, userID, book.ID, index.positions)
+	if err != nil {
+		return nil, err
+	}
+
+	budget := max(provider.ContextWindow()-answerTokens-promptOverheadTokens-llm.EstimateTokens(question), minInputTokens)
+	var passages []rag.Chunk
+	used := 0
+	for _, hit := range index.index.Search(question, askPassages, bound) {
+		cost := llm.EstimateTokens(hit.Chunk.Text)
+		if len(passages) > 0 && used+cost > budget {
+			break
+		}
+		passages = append(passages, hit.Chunk)
+		used += cost
+	}
+	if len(passages) == 0 {
+		return nil, ErrNoPassages
+	}
+
+	// 段落按在书中的顺序编号，便于模型理解先后关系
+	sort.Slice(passages, func(i, j int) bool {
+		if passages[i].Position != passages[j].Position {
+			return passages[i].Position < passages[j].Position
+		}
+		return passages[i].Start < passages[j].Start
+	})
+	texts := make([]string, len(passages))
+	for i, passage := range passages {
+		texts[i] = fmt.Sprintf("[%d] %s\n%s", i+1, passage.ChapterName, strings.TrimSpace(passage.Text))
+	}
+
+	language := prompts.Detect(question)
+	if language == "" {
+		language = BookLanguage(db, book)
+	}
+	prompt, err := Prompts.Get(language).Render(prompts.Answer, prompts.Data{
+		Title:    book.Title,
+		Author:   book.Author,
+		Question: question,
+		Content:  strings.Join(texts, "\n\n"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	answer, err := provider.Complete(ctx, llm.Request{
+		Messages:    []llm.Message{{Role: llm.RoleUser, Content: prompt}},
+		MaxTokens:   answerTokens,
+		Temperature: 0.2,
+	})
+	if err != nil {
+		return nil, err
+	}
+	answer = strings.TrimSpace(answer)
+
+	return &BookAnswer{
+		Question:  question,
+		Answer:    answer,
+		Citations: citations(answer, passages),
+		Model:     provider.Name(),
+	}, nil
+}
+
+// citations 返回答案中以 [n] 引用的段落，答案没有引用任何段落时返回全部段落
+func citations(answer string, passages []rag.Chunk) []Citation {
+	cited := make(map[int]bool)
+	for _, match := range citationPattern.FindAllStringSubmatch(answer, -1) {
+		if n, err := strconv.Atoi(match[1]); err == nil && n >= 1 && n <= len(passages) {
+			cited[n] = true
+		}
+	}
+
+	result := make([]Citation, 0, len(passages))
+	for i, passage := range passages {
+		if len(cited) > 0 && !cited[i+1] {
+			continue
+		}
+		result = append(result, Citation{
+			Index:       i + 1,
+			ChapterID:   passage.ChapterID,
+			ChapterName: passage.ChapterName,
+			Start:       passage.Start,
+			End:         passage.End,
+			Text:        passage.Text,
+		})
+	}
+	return result
+}
+
+// readingBound 返回读者已读内容的范围：读完全书时为 nil，没有阅读进度时为空范围
+func readingBound(db *gorm.DB, userID, bookID uint, positions map[uint]int) (*rag.Bound, error) {
+	progress, err := models.GetReadingProgress(db, userID, bookID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &rag.Bound{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if progress.Percentage >= 100 {
+		return nil, nil
+	}
+	if progress.ChapterID == nil {
+		return &rag.Bound{}, nil
+	}
+	position, ok := positions[*progress.ChapterID]
+	if !ok {
+		return &rag.Bound{}, nil
+	}
+	return &rag.Bound{Position: position, Offset: progress.Offset}, nil
+}
+
+// getPassageIndex 返回书籍的段落索引，书籍或章节变化后重新建立
+func getPassageIndex(db *gorm.DB, book *models.Book) (*passageIndex, error) {
+	stats, err := models.GetChapterStats(db.Where("book_id = ?", book.ID))
+	if err != nil {
+		return nil, err
+	}
+	fingerprint := bookFingerprint(*book, stats[book.ID])
+
+	return passageIndexes.get(book.ID, fingerprint, func() (*passageIndex, error) {
+		chapters, err := models.GetChaptersByBookID(db, book.ID)
+		if err != nil {
+			return nil, err
+		}
+		sort.Slice(chapters, func(i, j int) bool { return chapters[i].ID < chapters[j].ID })
+		positions := make(map[uint]int, len(chapters))
+		for i, chapter := range chapters {
+			positions[chapter.ID] = i
+		}
+		return &passageIndex{
+			bookID:      book.ID,
+			fingerprint: fingerprint,
+			index:       rag.NewIndex(tokenizer.NewBigram(), rag.SplitChapters(chapters, rag.DefaultChunkSize, rag.DefaultChunkOverlap)),
+			positions:   positions,
+		}, nil
+	})
+}
+
+// RemovePassageIndex 删除书籍的段落索引
+func RemovePassageIndex(bookID uint) {
+	passageIndexes.remove(bookID)
+}
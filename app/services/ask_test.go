@@ -0,0 +1,65 @@
+package services
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPassageCache(t *testing.T) {
+	cache := newPassageCache(2)
+	var builds atomic.Int32
+	builder := func(bookID uint, fingerprint string) func() (*passageIndex, error) {
+		return func() (*passageIndex, error) {
+			builds.Add(1)
+			return &passageIndex{bookID: bookID, fingerprint: fingerprint}, nil
+		}
+	}
+
+	// 同时请求同一本书时只建立一次
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	results := make([]*passageIndex, 8)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			index, err := cache.get(1, "v1", func() (*passageIndex, error) {
+				<-release
+				return builder(1, "v1")()
+			})
+			require.NoError(t, err)
+			results[i] = index
+		}(i)
+	}
+	close(release)
+	wg.Wait()
+	assert.EqualValues(t, 1, builds.Load())
+	first, _ := cache.get(1, "v1", builder(1, "v1"))
+	for _, index := range results {
+		assert.Same(t, first, index)
+	}
+
+	// 指纹变化后重新建立
+	builds.Store(0)
+	updated, err := cache.get(1, "v2", builder(1, "v2"))
+	require.NoError(t, err)
+	assert.Equal(t, "v2", updated.fingerprint)
+	assert.EqualValues(t, 1, builds.Load())
+
+	// 超出容量时淘汰最久未使用的书
+	cache.get(2, "v1", builder(2, "v1"))
+	cache.get(1, "v2", builder(1, "v2"))
+	cache.get(3, "v1", builder(3, "v1"))
+	assert.EqualValues(t, 3, builds.Load())
+	assert.Len(t, cache.entries, 2)
+	assert.Contains(t, cache.entries, uint(1))
+	assert.NotContains(t, cache.entries, uint(2))
+
+	cache.remove(1)
+	assert.NotContains(t, cache.entries, uint(1))
+	assert.Equal(t, 1, cache.order.Len())
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/sd0ric4/book-reader-backend/app/config"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// 未配置时使用的默认有效期
+const (
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// TokenClaims 是访问令牌中携带的声明
+type TokenClaims struct {
+	UserID    uint   `json:"user_id"`
+	SessionID string `json:"sid"`
+	jwt.RegisteredClaims
+}
+
+// AccessTokenTTL 返回访问令牌有效期，对应 jwt.expire（秒）
+func AccessTokenTTL() time.Duration {
+	if config.Config.JWT.Expire > 0 {
+		return time.Duration(config.Config.JWT.Expire) * time.Second
+	}
+	return defaultAccessTokenTTL
+}
+
+// RefreshTokenTTL 返回刷新令牌有效期，对应 jwt.refresh_expire（秒）
+func RefreshTokenTTL() time.Duration {
+	if config.Config.JWT.RefreshExpire > 0 {
+		return time.Duration(config.Config.JWT.RefreshExpire) * time.Second
+	}
+	return defaultRefreshTokenTTL
+}
+
+// GenerateAccessToken 为指定用户和会话签发短期 JWT
+func GenerateAccessToken(userID uint, sessionID string) (string, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, TokenClaims{
+		UserID:    userID,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL())),
+		},
+	})
+
+	return token.SignedString([]byte(config.Config.JWT.Secret))
+}
+
+// ParseToken 校验 JWT 的签名、算法和过期时间，并返回其中的声明
+func ParseToken(tokenString string) (*TokenClaims, error) {
+	claims := &TokenClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(config.Config.JWT.Secret), nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	if claims.UserID == 0 {
+		return nil, fmt.Errorf("%w: missing user_id claim", ErrInvalidToken)
+	}
+	if claims.SessionID == "" {
+		return nil, fmt.Errorf("%w: missing sid claim", ErrInvalidToken)
+	}
+
+	return claims, nil
+}
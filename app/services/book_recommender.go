@@ -2,70 +2,63 @@ package services
 
 import (
 	"fmt"
-	"sort"
 	"strings"
 
 	"github.com/sd0ric4/book-reader-backend/app/database"
 	"github.com/sd0ric4/book-reader-backend/app/models"
-	"gonum.org/v1/gonum/mat"
 )
 
-// RecommendBooks 推荐书籍的核心逻辑
-func RecommendBooks(req models.RecommendationRequest) ([]models.Book, error) {
-	// 从数据库中获取书籍简要信息
-	bookBriefs, err := models.GetBookBriefs(database.MySQLDB)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get book briefs: %v", err)
-	}
+// 推荐数量的默认值
+const defaultRecommendLimit = 5
 
+// RecommendBooks 推荐书籍的核心逻辑，按 req.Limit 返回推荐（默认5本），书籍不足时全部返回
+func RecommendBooks(req models.RecommendationRequest) ([]Recommendation, error) {
 	// 检查用户书籍是否为空
 	if len(req.UserBooks) == 0 {
 		return nil, fmt.Errorf("no user books provided for recommendation")
 	}
 
-	// 提取用户阅读书籍的标签
-	userTags := make([]string, len(req.UserBooks))
-	for i, book := range req.UserBooks {
-		userTags[i] = book.Tags
+	index, err := loadRecommendIndex(database.MySQLDB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load recommendation index: %v", err)
 	}
-	userTagString := strings.Join(userTags, " ")
 
-	// 准备所有书籍的标签（包括用户标签）
-	allTagDocuments := make([]string, len(bookBriefs)+1)
-	for i, book := range bookBriefs {
-		allTagDocuments[i] = book.Tags
+	// 用户阅读过的书籍合成一个文档
+	userDocuments := make([]string, len(req.UserBooks))
+	for i, book := range req.UserBooks {
+		userDocuments[i] = recommendDocument(book)
 	}
-	allTagDocuments[len(bookBriefs)] = userTagString
-
-	// 计算TF-IDF矩阵
-	tfidfMatrix := ComputeTFIDF(allTagDocuments)
-
-	// 获取用户标签向量（最后一行）
-	userVector := mat.NewVecDense(len(tfidfMatrix.RawRowView(len(bookBriefs))), tfidfMatrix.RawRowView(len(bookBriefs)))
+	userVector := index.TextVector(strings.Join(userDocuments, "\n"))
 
-	// 计算相似度
-	similarities := make([]models.Book, len(bookBriefs))
-	for i, book := range bookBriefs {
-		bookVector := mat.NewVecDense(len(tfidfMatrix.RawRowView(i)), tfidfMatrix.RawRowView(i))
-		similarity := CosineSimilarity(userVector, bookVector)
-		similarities[i] = models.Book{
-			ID:          uint(book.ID),
-			Title:       book.Title,
-			Author:      book.Author,
-			Description: book.Description,
-			Tags:        book.Tags,
-			CoverURL:    book.CoverURL,
-			Score:       similarity,
-			CreatedAt:   book.CreatedAt,
-			UpdatedAt:   book.UpdatedAt,
-		}
+	// 只推荐公开书籍，相关的书不足时用其他公开书籍补足
+	similarities := index.Similar(userVector, nil)
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultRecommendLimit
 	}
+	recommendations, err := rankRecommendations(database.MySQLDB, nil, similarities, limit)
+	if err == nil {
+		recommendations, err = fillRecommendations(database.MySQLDB, nil, recommendations, nil, limit)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get books: %v", err)
+	}
+	for i := range recommendations {
+		terms := explainTerms(index, userVector, recommendations[i].Book.ID)
+		recommendations[i].Explanation = Explanation{Reason: explanationReason(Explanation{Terms: terms}, 0), Terms: terms}
+	}
+	return recommendations, nil
+}
 
-	// 按相似度排序
-	sort.Slice(similarities, func(i, j int) bool {
-		return similarities[i].Score > similarities[j].Score
-	})
+// 标签比简介更能代表书籍的类别，在文档中重复以提高权重
+const recommendTagWeight = 2
 
-	// 返回前5个推荐
-	return similarities[:5], nil
+// recommendDocument 由书籍的标签和简介组成用于计算TF-IDF的文档
+func recommendDocument(book models.Book) string {
+	parts := make([]string, 0, recommendTagWeight+1)
+	for i := 0; i < recommendTagWeight; i++ {
+		parts = append(parts, book.Tags)
+	}
+	parts = append(parts, book.Description)
+	return strings.Join(parts, "\n")
 }
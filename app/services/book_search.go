@@ -0,0 +1,158 @@
+package services
+
+import (
+	"errors"
+	"regexp"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sd0ric4/book-reader-backend/app/models"
+	"github.com/sd0ric4/book-reader-backend/app/services/tokenizer"
+	"gorm.io/gorm"
+)
+
+// 书内查找的匹配模式
+const (
+	FindModePlain = "plain" // 普通文本
+	FindModeWord  = "word"  // 全词匹配
+	FindModeRegex = "regex" // 正则表达式（RE2 语法）
+)
+
+const (
+	// 每批读取的章节数
+	findChapterBatchSize = 20
+	// 匹配前后保留的上下文字符数
+	findContextLen = 30
+	// 查询的最大长度
+	maxFindQueryLen = 256
+)
+
+var ErrInvalidFindQuery = errors.New("invalid search query")
+
+// FindInBookRequest 是书内查找的参数
+type FindInBookRequest struct {
+	Query         string
+	Mode          string
+	CaseSensitive bool
+	Limit         int
+}
+
+// FindMatch 是书中的一处匹配，Offset 和 Length 按字符（rune）计算
+type FindMatch struct {
+	ChapterID   uint   `json:"chapter_id"`
+	ChapterName string `json:"chapter_name"`
+	Offset      int    `json:"offset"`
+	Length      int    `json:"length"`
+	Before      string `json:"before"`
+	Text        string `json:"text"`
+	After       string `json:"after"`
+}
+
+// FindInBookResult 是书内查找的结果，Total 为全部匹配数，超过 Limit 的匹配不返回
+type FindInBookResult struct {
+	Total     int         `json:"total"`
+	Truncated bool        `json:"truncated"`
+	Matches   []FindMatch `json:"matches"`
+}
+
+// IsValidFindMode 判断查找模式是否合法
+func IsValidFindMode(mode string) bool {
+	return mode == FindModePlain || mode == FindModeWord || mode == FindModeRegex
+}
+
+// compileFindQuery 把查询编译为正则表达式
+func compileFindQuery(req FindInBookRequest) (*regexp.Regexp, error) {
+	if req.Query == "" || utf8.RuneCountInString(req.Query) > maxFindQueryLen {
+		return nil, ErrInvalidFindQuery
+	}
+
+	pattern := req.Query
+	if req.Mode != FindModeRegex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if !req.CaseSensitive {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, ErrInvalidFindQuery
+	}
+	return re, nil
+}
+
+// FindInBook 按章节顺序逐批扫描书籍内容，返回所有匹配的位置和上下文
+func FindInBook(db *gorm.DB, bookID uint, req FindInBookRequest) (*FindInBookResult, error) {
+	re, err := compileFindQuery(req)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &FindInBookResult{Matches: []FindMatch{}}
+	err = models.ForEachChapter(db, bookID, findChapterBatchSize, func(chapter *models.BookChapter) error {
+		for _, match := range findInText(chapter.ChapterContent, re, req.Mode == FindModeWord) {
+			result.Total++
+			if req.Limit > 0 && len(result.Matches) >= req.Limit {
+				result.Truncated = true
+				continue
+			}
+			match.ChapterID = chapter.ID
+			match.ChapterName = chapter.ChapterName
+			result.Matches = append(result.Matches, match)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// findInText 返回文本中的所有匹配，跳过空匹配；wholeWord 时要求匹配两端不与字母数字相连
+func findInText(content string, re *regexp.Regexp, wholeWord bool) []FindMatch {
+	var matches []FindMatch
+	locs := re.FindAllStringIndex(content, -1)
+	if len(locs) == 0 {
+		return matches
+	}
+
+	runes := []rune(content)
+	byteOffset, runeOffset := 0, 0
+	for _, loc := range locs {
+		if loc[0] == loc[1] {
+			continue
+		}
+		// 把字节偏移转换为字符偏移
+		runeOffset += utf8.RuneCountInString(content[byteOffset:loc[0]])
+		byteOffset = loc[0]
+		start := runeOffset
+		end := start + utf8.RuneCountInString(content[loc[0]:loc[1]])
+
+		if wholeWord && !isWholeWord(runes, start, end) {
+			continue
+		}
+		matches = append(matches, FindMatch{
+			Offset: start,
+			Length: end - start,
+			Before: string(runes[max(0, start-findContextLen):start]),
+			Text:   string(runes[start:end]),
+			After:  string(runes[end:min(len(runes), end+findContextLen)]),
+		})
+	}
+	return matches
+}
+
+// isWholeWord 判断 [start, end) 是否是一个完整的词。
+// 中日韩文字之间没有词边界，只对字母数字开头或结尾的匹配检查相邻字符。
+func isWholeWord(runes []rune, start, end int) bool {
+	if isWordChar(runes[start]) && start > 0 && isWordChar(runes[start-1]) {
+		return false
+	}
+	if isWordChar(runes[end-1]) && end < len(runes) && isWordChar(runes[end]) {
+		return false
+	}
+	return true
+}
+
+func isWordChar(r rune) bool {
+	return (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_') && !tokenizer.IsCJK(r)
+}
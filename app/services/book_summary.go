@@ -0,0 +1,256 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/sd0ric4/book-reader-backend/app/models"
+	"github.com/sd0ric4/book-reader-backend/app/services/llm"
+	"github.com/sd0ric4/book-reader-backend/app/services/prompts"
+	"gorm.io/gorm"
+)
+
+// 生成摘要时的 token 预算
+const (
+	chapterSummaryTokens = 400 // 章节或片段概要的最大输出
+	bookSummaryTokens    = 800 // 全书摘要的最大输出
+	promptOverheadTokens = 300 // 提示词模板本身占用的 token
+	minInputTokens       = 256 // 上下文窗口过小时仍至少输入这么多内容
+	summaryChapterBatch  = 20
+)
+
+var ErrNoChapterContent = errors.New("book has no chapter content")
+
+// BookSummary 是生成的全书摘要和各章节概要，以及摘要的语言、生成摘要的模型和提示词版本
+type BookSummary struct {
+	Review        models.BookReviewData   `json:"summary"`
+	Chapters      []models.ChapterSummary `json:"chapters,omitempty"`
+	Language      string                  `json:"language,omitempty"`
+	Model         string                  `json:"model,omitempty"`
+	PromptVersion string                  `json:"prompt_version,omitempty"`
+}
+
+// SummaryProgress 报告已处理的章节数和章节总数
+type SummaryProgress func(done, total int)
+
+// bookSummarizer 以 map-reduce 的方式生成摘要：先逐章概括，再把章节概要合并为全书摘要。
+// 任何一步的输入超出模型上下文窗口时，先切分或分组概括，再合并。
+// 章节概要使用书籍语言的模板，全书摘要使用要求输出的语言的模板。
+type bookSummarizer struct {
+	db       *gorm.DB
+	provider llm.Provider
+	book     *models.Book
+	source   *prompts.Set // 书籍语言的模板
+	output   *prompts.Set // 摘要语言的模板
+	progress SummaryProgress
+	emit     SummaryEmitter // 不为空时流式生成全书摘要
+}
+
+// SummarizeBook 根据书籍的章节内容生成摘要并保存章节概要，progress 可以为空。
+// 摘要使用书籍的语言。章节内容未变化时复用已保存的章节概要，书籍没有章节内容时返回 ErrNoChapterContent。
+func SummarizeBook(ctx context.Context, db *gorm.DB, provider llm.Provider, book *models.Book, progress SummaryProgress) (*BookSummary, error) {
+	set := Prompts.Get(BookLanguage(db, book))
+	s := &bookSummarizer{db: db, provider: provider, book: book, source: set, output: set, progress: progress}
+	return s.summarize(ctx)
+}
+
+func (s *bookSummarizer) summarize(ctx context.Context) (*BookSummary, error) {
+	chapters, err := s.summarizeChapters(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(chapters) == 0 {
+		return nil, ErrNoChapterContent
+	}
+
+	// 章节名单独一行，不使用与语言相关的标点，适用于各种语言的提示词
+	texts := make([]string, len(chapters))
+	for i, chapter := range chapters {
+		texts[i] = chapter.ChapterName + "\n" + chapter.Summary
+	}
+	texts, err = s.condense(ctx, texts, s.inputBudget(bookSummaryTokens))
+	if err != nil {
+		return nil, err
+	}
+
+	book := s.book
+	prompt, err := s.output.Render(prompts.BookSummary, prompts.Data{Title: book.Title, Author: book.Author, Content: strings.Join(texts, summarySeparator)})
+	if err != nil {
+		return nil, err
+	}
+	review, err := generateReview(ctx, s.provider, s.output, prompt, book.Title, book.Author, bookSummaryTokens, s.emit)
+	if err != nil {
+		return nil, err
+	}
+	return &BookSummary{
+		Review:        *review,
+		Chapters:      chapters,
+		Language:      s.output.Language,
+		Model:         s.provider.Name(),
+		PromptVersion: promptVersion("book", s.output),
+	}, nil
+}
+
+// summarizeChapters 按章节顺序生成并保存章节概要，跳过没有内容的章节
+func (s *bookSummarizer) summarizeChapters(ctx context.Context) ([]models.ChapterSummary, error) {
+	total, err := models.GetChapterCount(s.db, s.book.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var summaries []models.ChapterSummary
+	done := 0
+	err = models.ForEachChapter(s.db, s.book.ID, summaryChapterBatch, func(chapter *models.BookChapter) error {
+		defer func() {
+			done++
+			if s.progress != nil {
+				s.progress(done, int(total))
+			}
+		}()
+		if strings.TrimSpace(chapter.ChapterContent) == "" {
+			return nil
+		}
+
+		hash := contentHash(chapter.ChapterContent)
+		if saved, err := models.GetChapterSummary(s.db, chapter.ID); err == nil && saved.ContentHash == hash {
+			summaries = append(summaries, *saved)
+			return nil
+		}
+
+		text, err := s.summarizeChapter(ctx, chapter)
+		if err != nil {
+			return err
+		}
+		summary := models.ChapterSummary{
+			BookID:      s.book.ID,
+			ChapterID:   chapter.ID,
+			ChapterName: chapter.ChapterName,
+			Summary:     text,
+			ContentHash: hash,
+			Model:       s.provider.Name(),
+		}
+		if err := models.UpsertChapterSummary(s.db, &summary); err != nil {
+			return err
+		}
+		summaries = append(summaries, summary)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return summaries, nil
+}
+
+// summarizeChapter 概括一章，章节超出上下文窗口时分段概括后再合并
+func (s *bookSummarizer) summarizeChapter(ctx context.Context, chapter *models.BookChapter) (string, error) {
+	parts := llm.SplitByTokens(chapter.ChapterContent, s.inputBudget(chapterSummaryTokens))
+	summaries := make([]string, len(parts))
+	for i, part := range parts {
+		prompt, err := s.source.Render(prompts.ChapterSummary, prompts.Data{
+			Title:   s.book.Title,
+			Author:  s.book.Author,
+			Chapter: chapter.ChapterName,
+			Part:    i + 1,
+			Parts:   len(parts),
+			Content: part,
+		})
+		if err != nil {
+			return "", err
+		}
+		summary, err := s.complete(ctx, prompt)
+		if err != nil {
+			return "", err
+		}
+		summaries[i] = summary
+	}
+	if len(summaries) == 1 {
+		return summaries[0], nil
+	}
+
+	summaries, err := s.condense(ctx, summaries, s.inputBudget(chapterSummaryTokens))
+	if err != nil {
+		return "", err
+	}
+	return s.merge(ctx, summaries)
+}
+
+// 提示词中相邻概要之间的分隔
+const summarySeparator = "\n\n"
+
+// condense 反复把相邻的概要分组合并，直到全部概要的长度不超过 budget
+func (s *bookSummarizer) condense(ctx context.Context, texts []string, budget int) ([]string, error) {
+	for len(texts) > 1 && llm.EstimateTokens(strings.Join(texts, summarySeparator)) > budget {
+		groups := groupByTokens(texts, s.inputBudget(chapterSummaryTokens))
+		merged := make([]string, len(groups))
+		for i, group := range groups {
+			if len(group) == 1 {
+				merged[i] = group[0]
+				continue
+			}
+			summary, err := s.merge(ctx, group)
+			if err != nil {
+				return nil, err
+			}
+			merged[i] = summary
+		}
+		texts = merged
+	}
+	return texts, nil
+}
+
+// merge 把几段概要合并为一段
+func (s *bookSummarizer) merge(ctx context.Context, texts []string) (string, error) {
+	prompt, err := s.source.Render(prompts.MergeSummary, prompts.Data{Title: s.book.Title, Author: s.book.Author, Content: strings.Join(texts, summarySeparator)})
+	if err != nil {
+		return "", err
+	}
+	return s.complete(ctx, prompt)
+}
+
+func (s *bookSummarizer) complete(ctx context.Context, prompt string) (string, error) {
+	content, err := s.provider.Complete(ctx, llm.Request{
+		Messages:    []llm.Message{{Role: llm.RoleUser, Content: prompt}},
+		MaxTokens:   chapterSummaryTokens,
+		Temperature: 0.3,
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(content), nil
+}
+
+// inputBudget 返回在输出 outputTokens 个 token 时，一次请求中可以放入的内容长度
+func (s *bookSummarizer) inputBudget(outputTokens int) int {
+	return max(s.provider.ContextWindow()-outputTokens-promptOverheadTokens, minInputTokens)
+}
+
+// groupByTokens 把相邻的文本分组，每组尽量不超过 budget；每组至少两段，保证每轮合并都能缩短
+func groupByTokens(texts []string, budget int) [][]string {
+	var groups [][]string
+	var current []string
+	tokens := 0
+	for _, text := range texts {
+		cost := llm.EstimateTokens(text)
+		if len(current) >= 2 && tokens+cost > budget {
+			groups = append(groups, current)
+			current, tokens = nil, 0
+		}
+		current = append(current, text)
+		tokens += cost
+	}
+	if len(current) == 1 && len(groups) > 0 {
+		groups[len(groups)-1] = append(groups[len(groups)-1], current[0])
+	} else if len(current) > 0 {
+		groups = append(groups, current)
+	}
+	return groups
+}
+
+// contentHash 返回章节内容的哈希，用于判断章节概要是否过期
+func contentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sd0ric4/book-reader-backend/app/config"
+	"github.com/sd0ric4/book-reader-backend/app/models"
+	"github.com/sd0ric4/book-reader-backend/app/services/recommend"
+	"gorm.io/gorm"
+)
+
+// 协同过滤模型默认的缓存时间
+const defaultCollaborativeTTL = 10 * time.Minute
+
+var (
+	collaborativeMu      sync.Mutex
+	collaborativeModel   *recommend.Collaborative
+	collaborativeBuiltAt time.Time
+)
+
+// RecommendWeights 是推荐信号的混合权重
+type RecommendWeights struct {
+	Content       float64
+	Collaborative float64
+	Popularity    float64
+}
+
+// recommendWeights 返回配置的混合权重，未配置时使用默认值
+func recommendWeights() RecommendWeights {
+	if config.Config != nil {
+		cfg := config.Config.Recommend
+		if cfg.ContentWeight > 0 || cfg.CollaborativeWeight > 0 || cfg.PopularityWeight > 0 {
+			return RecommendWeights{
+				Content:       cfg.ContentWeight,
+				Collaborative: cfg.CollaborativeWeight,
+				Popularity:    cfg.PopularityWeight,
+			}
+		}
+	}
+	return RecommendWeights{Content: 0.5, Collaborative: 0.35, Popularity: 0.15}
+}
+
+func collaborativeTTL() time.Duration {
+	if config.Config != nil && config.Config.Recommend.CollaborativeTTL > 0 {
+		return time.Duration(config.Config.Recommend.CollaborativeTTL) * time.Second
+	}
+	return defaultCollaborativeTTL
+}
+
+// loadCollaborative 返回协同过滤模型，超过缓存时间后从所有用户的行为重新构建
+func loadCollaborative(db *gorm.DB) (*recommend.Collaborative, error) {
+	collaborativeMu.Lock()
+	defer collaborativeMu.Unlock()
+	if collaborativeModel != nil && time.Since(collaborativeBuiltAt) < collaborativeTTL() {
+		return collaborativeModel, nil
+	}
+
+	progresses, err := models.GetAllReadingProgress(db)
+	if err != nil {
+		return nil, err
+	}
+	favorites, err := models.GetAllFavorites(db)
+	if err != nil {
+		return nil, err
+	}
+	ratings, err := models.GetAllRatings(db)
+	if err != nil {
+		return nil, err
+	}
+
+	collaborativeModel = recommend.NewCollaborative(interactionWeights(progresses, favorites, ratings))
+	collaborativeBuiltAt = time.Now()
+	return collaborativeModel, nil
+}
+
+// ResetCollaborative 丢弃缓存的协同过滤模型，下次使用时重新构建
+func ResetCollaborative() {
+	collaborativeMu.Lock()
+	defer collaborativeMu.Unlock()
+	collaborativeModel = nil
+}
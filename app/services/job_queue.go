@@ -0,0 +1,36 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/sd0ric4/book-reader-backend/app/config"
+	"github.com/sd0ric4/book-reader-backend/app/services/jobs"
+)
+
+// 未配置 worker 数量时的默认值
+const defaultJobWorkers = 2
+
+// Jobs 是后台任务队列，未初始化时为空
+var Jobs *jobs.Queue
+
+// InitJobs 创建任务队列并注册任务类型
+func InitJobs(client *redis.Client, cfg config.JobsConfig) *jobs.Queue {
+	queue := jobs.NewQueue(client, jobs.Options{
+		MaxAttempts: cfg.MaxAttempts,
+		Lease:       time.Duration(cfg.Lease) * time.Second,
+	})
+	queue.Register(JobTypeSummary, runSummaryJob)
+	Jobs = queue
+	return queue
+}
+
+// RunJobs 启动 worker 执行后台任务，阻塞到 ctx 结束
+func RunJobs(ctx context.Context, cfg config.JobsConfig) {
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = defaultJobWorkers
+	}
+	Jobs.Run(ctx, workers)
+}
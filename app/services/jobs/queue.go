@@ -0,0 +1,579 @@
+// Package jobs 提供基于 Redis 的后台任务队列。
+// 任务保存在 Redis 中，服务重启后未完成的任务会继续执行：
+// 执行中的任务持有租约并定期续约，租约过期（如进程崩溃）的任务会被重新放回队列；
+// 失败的任务按指数退避自动重试，用尽重试次数后可以手动重试。
+// 同一个幂等键在任务排队或执行期间只会对应一个任务。
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// 任务状态
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+var (
+	ErrJobNotFound     = errors.New("job not found")
+	ErrUnknownJobType  = errors.New("unknown job type")
+	ErrJobNotRetryable = errors.New("only failed jobs can be retried")
+)
+
+// 抢占幂等键时 WATCH 冲突的最大重试次数
+const maxTxRetries = 5
+
+// Job 是一个后台任务
+type Job struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	UserID      uint            `json:"user_id"`
+	Payload     json.RawMessage `json:"-"`
+	Status      string          `json:"status"`
+	Progress    int             `json:"progress"`
+	Message     string          `json:"message,omitempty"`
+	Result      json.RawMessage `json:"result,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+
+	key        string
+	leaseUntil int64
+}
+
+// Active 判断任务是否还在排队或执行
+func (j *Job) Active() bool {
+	return j.Status == StatusQueued || j.Status == StatusRunning
+}
+
+// DecodePayload 解析任务参数
+func (j *Job) DecodePayload(v interface{}) error {
+	return json.Unmarshal(j.Payload, v)
+}
+
+// ProgressFunc 报告任务进度，percent 取值 0-100
+type ProgressFunc func(percent int, message string)
+
+// Handler 执行一个任务，返回值会以 JSON 保存为任务结果。
+// 任务可能因重试或重启被执行多次，Handler 需要保证重复执行是安全的。
+type Handler func(ctx context.Context, job *Job, progress ProgressFunc) (interface{}, error)
+
+// Options 是队列的配置，零值使用默认值
+type Options struct {
+	// Redis 键的前缀，默认 jobs
+	Prefix string
+	// 每个任务最多执行的次数，默认3
+	MaxAttempts int
+	// 执行中任务的租约时长，超过后未续约的任务会被重新放回队列，默认1分钟
+	Lease time.Duration
+	// 第一次重试前的等待时间，之后每次翻倍，默认5秒
+	RetryDelay time.Duration
+	// 已结束任务的保留时间，默认24小时
+	ResultTTL time.Duration
+	// 检查过期租约和待重试任务的间隔，默认1秒
+	PollInterval time.Duration
+}
+
+// Queue 是任务队列
+type Queue struct {
+	client   *redis.Client
+	opts     Options
+	mu       sync.RWMutex
+	handlers map[string]Handler
+}
+
+// NewQueue 创建任务队列
+func NewQueue(client *redis.Client, opts Options) *Queue {
+	if opts.Prefix == "" {
+		opts.Prefix = "jobs"
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 3
+	}
+	if opts.Lease <= 0 {
+		opts.Lease = time.Minute
+	}
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = 5 * time.Second
+	}
+	if opts.ResultTTL <= 0 {
+		opts.ResultTTL = 24 * time.Hour
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+	return &Queue{client: client, opts: opts, handlers: make(map[string]Handler)}
+}
+
+// Register 注册任务类型的处理函数
+func (q *Queue) Register(jobType string, handler Handler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[jobType] = handler
+}
+
+func (q *Queue) handler(jobType string) (Handler, bool) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	handler, ok := q.handlers[jobType]
+	return handler, ok
+}
+
+func (q *Queue) jobKey(id string) string {
+	return q.opts.Prefix + ":job:" + id
+}
+
+func (q *Queue) idempotencyKey(key string) string {
+	return q.opts.Prefix + ":key:" + key
+}
+
+func (q *Queue) queueKey() string {
+	return q.opts.Prefix + ":queue"
+}
+
+func (q *Queue) processingKey() string {
+	return q.opts.Prefix + ":processing"
+}
+
+func (q *Queue) delayedKey() string {
+	return q.opts.Prefix + ":delayed"
+}
+
+// Enqueue 创建任务并放入队列。key 不为空时作为幂等键：
+// 同一个键已有排队或执行中的任务时直接返回该任务，created 为 false。
+func (q *Queue) Enqueue(ctx context.Context, jobType, key string, userID uint, payload interface{}) (job *Job, created bool, err error) {
+	if _, ok := q.handler(jobType); !ok {
+		return nil, false, fmt.Errorf("%w: %s", ErrUnknownJobType, jobType)
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, false, err
+	}
+
+	now := time.Now()
+	job = &Job{
+		ID:          uuid.NewString(),
+		Type:        jobType,
+		UserID:      userID,
+		Payload:     data,
+		Status:      StatusQueued,
+		MaxAttempts: q.opts.MaxAttempts,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		key:         key,
+	}
+	if key == "" {
+		_, err = q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			q.create(ctx, pipe, job)
+			return nil
+		})
+		return job, err == nil, err
+	}
+
+	indexKey := q.idempotencyKey(key)
+	for i := 0; i < maxTxRetries; i++ {
+		var existing *Job
+		err = q.client.Watch(ctx, func(tx *redis.Tx) error {
+			id, err := tx.Get(ctx, indexKey).Result()
+			if err != nil && err != redis.Nil {
+				return err
+			}
+			if err == nil {
+				if current, err := q.get(ctx, tx, id); err == nil && current.Active() {
+					existing = current
+					return nil
+				}
+			}
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				q.create(ctx, pipe, job)
+				pipe.Set(ctx, indexKey, job.ID, 0)
+				return nil
+			})
+			return err
+		}, indexKey)
+		if err == redis.TxFailedErr {
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if existing != nil {
+			return existing, false, nil
+		}
+		return job, true, nil
+	}
+	return nil, false, err
+}
+
+func (q *Queue) create(ctx context.Context, pipe redis.Pipeliner, job *Job) {
+	pipe.HSet(ctx, q.jobKey(job.ID),
+		"id", job.ID,
+		"type", job.Type,
+		"key", job.key,
+		"user_id", job.UserID,
+		"payload", string(job.Payload),
+		"status", job.Status,
+		"progress", 0,
+		"attempts", 0,
+		"max_attempts", job.MaxAttempts,
+		"lease_until", 0,
+		"created_at", job.CreatedAt.Unix(),
+		"updated_at", job.UpdatedAt.Unix(),
+	)
+	pipe.LPush(ctx, q.queueKey(), job.ID)
+}
+
+// Get 返回任务，任务不存在或已过期时返回 ErrJobNotFound
+func (q *Queue) Get(ctx context.Context, id string) (*Job, error) {
+	return q.get(ctx, q.client, id)
+}
+
+func (q *Queue) get(ctx context.Context, client redis.Cmdable, id string) (*Job, error) {
+	fields, err := client.HGetAll(ctx, q.jobKey(id)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, ErrJobNotFound
+	}
+
+	userID, _ := strconv.ParseUint(fields["user_id"], 10, 64)
+	progress, _ := strconv.Atoi(fields["progress"])
+	attempts, _ := strconv.Atoi(fields["attempts"])
+	maxAttempts, _ := strconv.Atoi(fields["max_attempts"])
+	leaseUntil, _ := strconv.ParseInt(fields["lease_until"], 10, 64)
+	createdAt, _ := strconv.ParseInt(fields["created_at"], 10, 64)
+	updatedAt, _ := strconv.ParseInt(fields["updated_at"], 10, 64)
+
+	job := &Job{
+		ID:          fields["id"],
+		Type:        fields["type"],
+		UserID:      uint(userID),
+		Payload:     json.RawMessage(fields["payload"]),
+		Status:      fields["status"],
+		Progress:    progress,
+		Message:     fields["message"],
+		Error:       fields["error"],
+		Attempts:    attempts,
+		MaxAttempts: maxAttempts,
+		CreatedAt:   time.Unix(createdAt, 0),
+		UpdatedAt:   time.Unix(updatedAt, 0),
+		key:         fields["key"],
+		leaseUntil:  leaseUntil,
+	}
+	if result := fields["result"]; result != "" {
+		job.Result = json.RawMessage(result)
+	}
+	return job, nil
+}
+
+// Retry 把失败的任务重新放入队列，重新计算执行次数
+func (q *Queue) Retry(ctx context.Context, id string) (*Job, error) {
+	key := q.jobKey(id)
+	for i := 0; i < maxTxRetries; i++ {
+		err := q.client.Watch(ctx, func(tx *redis.Tx) error {
+			job, err := q.get(ctx, tx, id)
+			if err != nil {
+				return err
+			}
+			if job.Status != StatusFailed {
+				return ErrJobNotRetryable
+			}
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.HSet(ctx, key,
+					"status", StatusQueued,
+					"progress", 0,
+					"message", "",
+					"error", "",
+					"attempts", 0,
+					"lease_until", 0,
+					"updated_at", time.Now().Unix(),
+				)
+				pipe.Persist(ctx, key)
+				if job.key != "" {
+					pipe.Set(ctx, q.idempotencyKey(job.key), id, 0)
+				}
+				pipe.LPush(ctx, q.queueKey(), id)
+				return nil
+			})
+			return err
+		}, key)
+		if err == redis.TxFailedErr {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return q.Get(ctx, id)
+	}
+	return nil, redis.TxFailedErr
+}
+
+// Run 启动 workers 个 worker 执行任务，阻塞到 ctx 结束。
+// 启动时会先把上次运行中断的任务放回队列。
+func (q *Queue) Run(ctx context.Context, workers int) {
+	if workers <= 0 {
+		workers = 1
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		q.reap(ctx)
+	}()
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+// reap 定期把租约过期的任务和到期的待重试任务放回队列
+func (q *Queue) reap(ctx context.Context) {
+	ticker := time.NewTicker(q.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		if err := q.Recover(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Failed to recover jobs: %s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Recover 把到期的待重试任务和租约过期的执行中任务放回队列
+func (q *Queue) Recover(ctx context.Context) error {
+	now := time.Now()
+	due, err := q.client.ZRangeByScore(ctx, q.delayedKey(), &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(now.UnixMilli(), 10),
+	}).Result()
+	if err != nil {
+		return err
+	}
+	for _, id := range due {
+		// 只有成功移出延迟队列的一方负责入队，避免多个实例重复入队
+		if removed, err := q.client.ZRem(ctx, q.delayedKey(), id).Result(); err != nil || removed == 0 {
+			continue
+		}
+		if err := q.client.LPush(ctx, q.queueKey(), id).Err(); err != nil {
+			return err
+		}
+	}
+
+	processing, err := q.client.LRange(ctx, q.processingKey(), 0, -1).Result()
+	if err != nil {
+		return err
+	}
+	for _, id := range processing {
+		job, err := q.Get(ctx, id)
+		if errors.Is(err, ErrJobNotFound) {
+			q.client.LRem(ctx, q.processingKey(), 0, id)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if job.leaseUntil == 0 {
+			// 刚被取出还未开始执行，给 worker 一个租约周期的时间
+			q.client.HSet(ctx, q.jobKey(id), "lease_until", now.Add(q.opts.Lease).UnixMilli())
+			continue
+		}
+		if job.leaseUntil > now.UnixMilli() {
+			continue
+		}
+		if removed, err := q.client.LRem(ctx, q.processingKey(), 0, id).Result(); err != nil || removed == 0 {
+			continue
+		}
+		log.Printf("Job %s lease expired, requeueing", id)
+		_, err = q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, q.jobKey(id), "status", StatusQueued, "lease_until", 0, "updated_at", now.Unix())
+			pipe.LPush(ctx, q.queueKey(), id)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// work 不断从队列中取出任务执行，取出的任务在执行期间保存在 processing 列表中
+func (q *Queue) work(ctx context.Context) {
+	// Redis 阻塞命令的超时时间最小为1秒
+	timeout := max(q.opts.PollInterval, time.Second)
+	for ctx.Err() == nil {
+		id, err := q.client.BRPopLPush(ctx, q.queueKey(), q.processingKey(), timeout).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Failed to fetch job: %s", err)
+				time.Sleep(q.opts.PollInterval)
+			}
+			continue
+		}
+		q.process(ctx, id)
+	}
+}
+
+// process 执行一个任务并记录结果
+func (q *Queue) process(ctx context.Context, id string) {
+	job, err := q.Get(ctx, id)
+	if errors.Is(err, ErrJobNotFound) {
+		q.client.LRem(ctx, q.processingKey(), 0, id)
+		return
+	}
+	if err != nil {
+		// 留在 processing 列表中，租约过期后会被重新放回队列
+		log.Printf("Failed to load job %s: %s", id, err)
+		return
+	}
+
+	key := q.jobKey(id)
+	job.Attempts++
+	job.Status = StatusRunning
+	err = q.client.HSet(ctx, key,
+		"status", StatusRunning,
+		"attempts", job.Attempts,
+		"lease_until", time.Now().Add(q.opts.Lease).UnixMilli(),
+		"updated_at", time.Now().Unix(),
+	).Err()
+	if err != nil {
+		log.Printf("Failed to start job %s: %s", id, err)
+		return
+	}
+
+	handler, ok := q.handler(job.Type)
+	if !ok {
+		q.finish(ctx, job, nil, fmt.Errorf("%w: %s", ErrUnknownJobType, job.Type), false)
+		return
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	renewed := make(chan struct{})
+	go func() {
+		defer close(renewed)
+		q.renewLease(runCtx, key)
+	}()
+	progress := func(percent int, message string) {
+		percent = min(max(percent, 0), 100)
+		q.client.HSet(ctx, key, "progress", percent, "message", message, "updated_at", time.Now().Unix())
+	}
+	result, err := runHandler(runCtx, handler, job, progress)
+	cancel()
+	<-renewed
+
+	if ctx.Err() != nil {
+		// 服务关闭导致的中断不计入执行次数，立即放回队列
+		q.requeue(context.Background(), job)
+		return
+	}
+	q.finish(ctx, job, result, err, err != nil && job.Attempts < job.MaxAttempts)
+}
+
+// runHandler 执行处理函数，处理函数 panic 时视为执行失败
+func runHandler(ctx context.Context, handler Handler, job *Job, progress ProgressFunc) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return handler(ctx, job, progress)
+}
+
+// renewLease 在任务执行期间定期续约
+func (q *Queue) renewLease(ctx context.Context, key string) {
+	ticker := time.NewTicker(q.opts.Lease / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			q.client.HSet(ctx, key, "lease_until", time.Now().Add(q.opts.Lease).UnixMilli())
+		}
+	}
+}
+
+// finish 记录任务的执行结果，retry 为 true 时延迟后重试
+func (q *Queue) finish(ctx context.Context, job *Job, result interface{}, jobErr error, retry bool) {
+	key := q.jobKey(job.ID)
+	now := time.Now()
+	fields := []interface{}{"lease_until", 0, "updated_at", now.Unix()}
+
+	switch {
+	case jobErr == nil:
+		data, err := json.Marshal(result)
+		if err != nil {
+			jobErr = err
+			break
+		}
+		fields = append(fields, "status", StatusSucceeded, "progress", 100, "message", "", "error", "", "result", string(data))
+	case retry:
+		fields = append(fields, "status", StatusQueued, "error", jobErr.Error())
+	}
+	if jobErr != nil && !retry {
+		fields = append(fields, "status", StatusFailed, "error", jobErr.Error())
+	}
+
+	_, err := q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, fields...)
+		pipe.LRem(ctx, q.processingKey(), 0, job.ID)
+		if retry && jobErr != nil {
+			delay := q.opts.RetryDelay << (job.Attempts - 1)
+			pipe.ZAdd(ctx, q.delayedKey(), &redis.Z{Score: float64(now.Add(delay).UnixMilli()), Member: job.ID})
+			return nil
+		}
+		// 已结束的任务保留一段时间供查询，幂等键随之失效
+		pipe.Expire(ctx, key, q.opts.ResultTTL)
+		if job.key != "" {
+			pipe.Expire(ctx, q.idempotencyKey(job.key), q.opts.ResultTTL)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to record result of job %s: %s", job.ID, err)
+	}
+}
+
+// requeue 把被中断的任务放回队列
+func (q *Queue) requeue(ctx context.Context, job *Job) {
+	_, err := q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, q.jobKey(job.ID),
+			"status", StatusQueued,
+			"attempts", job.Attempts-1,
+			"lease_until", 0,
+			"updated_at", time.Now().Unix(),
+		)
+		pipe.LRem(ctx, q.processingKey(), 0, job.ID)
+		pipe.LPush(ctx, q.queueKey(), job.ID)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to requeue job %s: %s", job.ID, err)
+	}
+}
@@ -0,0 +1,209 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testOptions = Options{
+	Lease:        300 * time.Millisecond,
+	RetryDelay:   10 * time.Millisecond,
+	PollInterval: 10 * time.Millisecond,
+}
+
+func newTestQueue(t *testing.T) *Queue {
+	mr := miniredis.RunT(t)
+	return NewQueue(redis.NewClient(&redis.Options{Addr: mr.Addr()}), testOptions)
+}
+
+// startWorkers 启动 worker，测试结束时停止
+func startWorkers(t *testing.T, q *Queue, workers int) context.CancelFunc {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		q.Run(ctx, workers)
+	}()
+	stop := func() {
+		cancel()
+		<-done
+	}
+	t.Cleanup(stop)
+	return stop
+}
+
+// waitForJob 等待任务结束
+func waitForJob(t *testing.T, q *Queue, id string) *Job {
+	var job *Job
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = q.Get(context.Background(), id)
+		require.NoError(t, err)
+		return !job.Active()
+	}, 5*time.Second, 10*time.Millisecond)
+	return job
+}
+
+func TestQueue(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+
+	release := make(chan struct{})
+	q.Register("echo", func(ctx context.Context, job *Job, progress ProgressFunc) (interface{}, error) {
+		var payload map[string]string
+		require.NoError(t, job.DecodePayload(&payload))
+		progress(50, "halfway")
+		<-release
+		return payload, nil
+	})
+
+	_, _, err := q.Enqueue(ctx, "missing", "", 1, nil)
+	assert.ErrorIs(t, err, ErrUnknownJobType)
+
+	job, created, err := q.Enqueue(ctx, "echo", "book:1", 7, map[string]string{"title": "三体"})
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, StatusQueued, job.Status)
+
+	// 同一个幂等键在任务结束前只对应一个任务
+	same, created, err := q.Enqueue(ctx, "echo", "book:1", 7, map[string]string{"title": "三体"})
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, job.ID, same.ID)
+
+	startWorkers(t, q, 2)
+	require.Eventually(t, func() bool {
+		job, err = q.Get(ctx, job.ID)
+		require.NoError(t, err)
+		return job.Progress == 50
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, StatusRunning, job.Status)
+	assert.Equal(t, "halfway", job.Message)
+	assert.Equal(t, uint(7), job.UserID)
+
+	close(release)
+	job = waitForJob(t, q, job.ID)
+	assert.Equal(t, StatusSucceeded, job.Status)
+	assert.Equal(t, 100, job.Progress)
+	assert.Equal(t, 1, job.Attempts)
+	assert.JSONEq(t, `{"title":"三体"}`, string(job.Result))
+
+	// 任务结束后同一个键可以创建新任务
+	next, created, err := q.Enqueue(ctx, "echo", "book:1", 7, map[string]string{"title": "三体"})
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.NotEqual(t, job.ID, next.ID)
+	waitForJob(t, q, next.ID)
+
+	_, err = q.Get(ctx, "missing")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+}
+
+func TestQueueRetry(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+
+	var calls, failures int32
+	atomic.StoreInt32(&failures, 100)
+	q.Register("flaky", func(ctx context.Context, job *Job, progress ProgressFunc) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		if atomic.AddInt32(&failures, -1) >= 0 {
+			return nil, errors.New("model unavailable")
+		}
+		return "ok", nil
+	})
+	startWorkers(t, q, 1)
+
+	// 用尽重试次数后失败
+	job, _, err := q.Enqueue(ctx, "flaky", "", 1, nil)
+	require.NoError(t, err)
+	job = waitForJob(t, q, job.ID)
+	assert.Equal(t, StatusFailed, job.Status)
+	assert.Equal(t, "model unavailable", job.Error)
+	assert.Equal(t, 3, job.Attempts)
+	assert.EqualValues(t, 3, atomic.LoadInt32(&calls))
+
+	// 手动重试后，失败一次再成功
+	atomic.StoreInt32(&failures, 1)
+	_, err = q.Retry(ctx, job.ID)
+	require.NoError(t, err)
+	_, err = q.Retry(ctx, job.ID)
+	assert.ErrorIs(t, err, ErrJobNotRetryable)
+	job = waitForJob(t, q, job.ID)
+	assert.Equal(t, StatusSucceeded, job.Status)
+	assert.Equal(t, 2, job.Attempts)
+	assert.Empty(t, job.Error)
+}
+
+func TestQueuePanic(t *testing.T) {
+	q := newTestQueue(t)
+	q.Register("panic", func(ctx context.Context, job *Job, progress ProgressFunc) (interface{}, error) {
+		panic("boom")
+	})
+	startWorkers(t, q, 1)
+
+	job, _, err := q.Enqueue(context.Background(), "panic", "", 1, nil)
+	require.NoError(t, err)
+	job = waitForJob(t, q, job.ID)
+	assert.Equal(t, StatusFailed, job.Status)
+	assert.Contains(t, job.Error, "boom")
+}
+
+func TestQueueRecover(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+
+	var calls int32
+	q.Register("slow", func(ctx context.Context, job *Job, progress ProgressFunc) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return "done", nil
+	})
+
+	// 模拟进程在执行中崩溃：任务停留在 processing 列表中，租约已过期
+	job, _, err := q.Enqueue(ctx, "slow", "book:2", 1, nil)
+	require.NoError(t, err)
+	require.NoError(t, q.client.RPopLPush(ctx, q.queueKey(), q.processingKey()).Err())
+	expired := time.Now().Add(-time.Second).UnixMilli()
+	require.NoError(t, q.client.HSet(ctx, q.jobKey(job.ID), "status", StatusRunning, "attempts", 1, "lease_until", expired).Err())
+
+	startWorkers(t, q, 1)
+	job = waitForJob(t, q, job.ID)
+	assert.Equal(t, StatusSucceeded, job.Status)
+	assert.Equal(t, 2, job.Attempts)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+}
+
+func TestQueueShutdown(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+
+	started := make(chan struct{})
+	q.Register("block", func(ctx context.Context, job *Job, progress ProgressFunc) (interface{}, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	stop := startWorkers(t, q, 1)
+
+	job, _, err := q.Enqueue(ctx, "block", "", 1, nil)
+	require.NoError(t, err)
+	<-started
+	stop()
+
+	// 关闭服务时执行中的任务放回队列，不计入执行次数
+	job, err = q.Get(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusQueued, job.Status)
+	assert.Equal(t, 0, job.Attempts)
+	queued, err := q.client.LRange(ctx, q.queueKey(), 0, -1).Result()
+	require.NoError(t, err)
+	assert.Equal(t, []string{job.ID}, queued)
+}
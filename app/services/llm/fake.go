@@ -0,0 +1,76 @@
+package llm
+
+import (
+	"context"
+	"sync"
+)
+
+// Fake 是测试用的确定性服务，不访问网络。
+// Reply 为空时回复最后一条消息的内容；Requests 记录收到的所有请求。
+// Window 为0时上下文窗口使用默认值。流式输出时把回复按 ChunkSize 个字符分段，为0时每段4个字符。
+type Fake struct {
+	Reply     func(req Request) (string, error)
+	Window    int
+	ChunkSize int
+
+	mu       sync.Mutex
+	requests []Request
+}
+
+// NewFake 创建假服务
+func NewFake(reply func(req Request) (string, error)) *Fake {
+	return &Fake{Reply: reply}
+}
+
+func (f *Fake) Name() string {
+	return ProviderFake
+}
+
+func (f *Fake) ContextWindow() int {
+	return contextWindow(f.Window)
+}
+
+func (f *Fake) Complete(ctx context.Context, req Request) (string, error) {
+	f.mu.Lock()
+	f.requests = append(f.requests, req)
+	f.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if f.Reply != nil {
+		return f.Reply(req)
+	}
+	if len(req.Messages) == 0 {
+		return "", nil
+	}
+	return req.Messages[len(req.Messages)-1].Content, nil
+}
+
+func (f *Fake) Stream(ctx context.Context, req Request, onDelta DeltaFunc) (string, error) {
+	content, err := f.Complete(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	size := f.ChunkSize
+	if size <= 0 {
+		size = 4
+	}
+	runes := []rune(content)
+	for start := 0; start < len(runes); start += size {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		if err := onDelta(string(runes[start:min(start+size, len(runes))])); err != nil {
+			return "", err
+		}
+	}
+	return content, nil
+}
+
+// Requests 返回收到的请求
+func (f *Fake) Requests() []Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Request(nil), f.requests...)
+}
@@ -0,0 +1,94 @@
+// Package llm 封装生成摘要等功能使用的大语言模型服务。
+// Provider 抽象了不同的服务接口：OpenAI 兼容接口、Ollama 接口，以及测试使用的确定性假实现，
+// 具体使用哪一个由配置决定，更换模型无需重新编译。
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sd0ric4/book-reader-backend/app/config"
+)
+
+// 支持的服务类型
+const (
+	ProviderOpenAI = "openai"
+	ProviderOllama = "ollama"
+	ProviderFake   = "fake"
+)
+
+// 未配置超时时间和上下文窗口时的默认值
+const (
+	defaultTimeout       = 60 * time.Second
+	DefaultContextWindow = 4096
+)
+
+// 消息角色
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+var ErrUnknownProvider = errors.New("unknown llm provider")
+
+// Message 是对话中的一条消息
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Request 是一次补全请求，MaxTokens 为0时不限制
+type Request struct {
+	Messages    []Message
+	MaxTokens   int
+	Temperature float32
+}
+
+// Provider 是大语言模型服务
+type Provider interface {
+	// Name 返回服务类型和模型名，用于日志和记录摘要的来源
+	Name() string
+	// Complete 返回模型对对话的回复
+	Complete(ctx context.Context, req Request) (string, error)
+	// ContextWindow 返回模型的上下文窗口大小（token 数），包括输入和输出
+	ContextWindow() int
+}
+
+// New 按配置创建服务，未配置 provider 时返回 nil
+func New(cfg config.AIConfig) (Provider, error) {
+	switch cfg.Provider {
+	case "":
+		return nil, nil
+	case ProviderOpenAI:
+		apiKey := cfg.OpenAI.APIKey
+		if apiKey == "" {
+			apiKey = os.Getenv("OPENAI_API_KEY")
+		}
+		return NewOpenAI(cfg.OpenAI.BaseURL, cfg.OpenAI.Model, apiKey, timeout(cfg.OpenAI.Timeout), contextWindow(cfg.OpenAI.ContextWindow)), nil
+	case ProviderOllama:
+		return NewOllama(cfg.Ollama.BaseURL, cfg.Ollama.Model, timeout(cfg.Ollama.Timeout), contextWindow(cfg.Ollama.ContextWindow)), nil
+	case ProviderFake:
+		return NewFake(nil), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, cfg.Provider)
+	}
+}
+
+// timeout 把以秒为单位的配置转换为超时时间
+func timeout(seconds int) time.Duration {
+	if seconds <= 0 {
+		return defaultTimeout
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func contextWindow(tokens int) int {
+	if tokens <= 0 {
+		return DefaultContextWindow
+	}
+	return tokens
+}
@@ -0,0 +1,208 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sd0ric4/book-reader-backend/app/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testRequest = Request{
+	Messages:    []Message{{Role: RoleUser, Content: "总结《三体》"}},
+	MaxTokens:   100,
+	Temperature: 0.5,
+}
+
+func TestNew(t *testing.T) {
+	provider, err := New(config.AIConfig{})
+	require.NoError(t, err)
+	assert.Nil(t, provider)
+
+	provider, err = New(config.AIConfig{Provider: ProviderOllama, Ollama: config.OllamaConfig{Model: "qwen"}})
+	require.NoError(t, err)
+	assert.Equal(t, "ollama/qwen", provider.Name())
+	assert.Equal(t, DefaultContextWindow, provider.ContextWindow())
+
+	provider, err = New(config.AIConfig{Provider: ProviderOpenAI, OpenAI: config.OpenAIConfig{Model: "gpt"}})
+	require.NoError(t, err)
+	assert.Equal(t, "openai/gpt", provider.Name())
+
+	provider, err = New(config.AIConfig{Provider: ProviderFake})
+	require.NoError(t, err)
+	assert.Equal(t, ProviderFake, provider.Name())
+
+	_, err = New(config.AIConfig{Provider: "unknown"})
+	assert.ErrorIs(t, err, ErrUnknownProvider)
+}
+
+func TestOpenAI(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/chat/completions", r.URL.Path)
+		assert.Equal(t, "Bearer key", r.Header.Get("Authorization"))
+
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "gpt", body["model"])
+		assert.EqualValues(t, 100, body["max_tokens"])
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"index":0,"message":{"role":"assistant","content":"好书"}}]}`))
+	}))
+	defer server.Close()
+
+	provider := NewOpenAI(server.URL+"/v1", "gpt", "key", time.Second, 0)
+	content, err := provider.Complete(context.Background(), testRequest)
+	require.NoError(t, err)
+	assert.Equal(t, "好书", content)
+}
+
+func TestOllama(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/chat", r.URL.Path)
+
+		var body ollamaChatRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "qwen", body.Model)
+		assert.False(t, body.Stream)
+		assert.Equal(t, 100, body.Options.NumPredict)
+		assert.Equal(t, 8192, body.Options.NumCtx)
+		assert.Equal(t, testRequest.Messages, body.Messages)
+
+		w.Write([]byte(`{"message":{"role":"assistant","content":"好书"},"done":true}`))
+	}))
+	defer server.Close()
+
+	provider := NewOllama(server.URL+"/", "qwen", time.Second, 8192)
+	content, err := provider.Complete(context.Background(), testRequest)
+	require.NoError(t, err)
+	assert.Equal(t, "好书", content)
+}
+
+func TestOllamaError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"model not found"}`))
+	}))
+	defer server.Close()
+
+	_, err := NewOllama(server.URL, "missing", time.Second, 0).Complete(context.Background(), testRequest)
+	assert.ErrorContains(t, err, "model not found")
+}
+
+func TestProviderTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer server.Close()
+
+	providers := []Provider{
+		NewOpenAI(server.URL, "gpt", "key", 50*time.Millisecond, 0),
+		NewOllama(server.URL, "qwen", 50*time.Millisecond, 0),
+	}
+	for _, provider := range providers {
+		start := time.Now()
+		_, err := provider.Complete(context.Background(), testRequest)
+		assert.Error(t, err, provider.Name())
+		assert.Less(t, time.Since(start), 500*time.Millisecond, provider.Name())
+	}
+}
+
+func TestFake(t *testing.T) {
+	fake := NewFake(nil)
+	content, err := fake.Complete(context.Background(), testRequest)
+	require.NoError(t, err)
+	assert.Equal(t, "总结《三体》", content)
+
+	fake.Reply = func(req Request) (string, error) { return "固定回复", nil }
+	content, err = fake.Complete(context.Background(), testRequest)
+	require.NoError(t, err)
+	assert.Equal(t, "固定回复", content)
+	assert.Len(t, fake.Requests(), 2)
+}
+
+// collect 以流式方式请求模型，返回收到的所有片段
+func collect(t *testing.T, provider Provider) (string, []string) {
+	var deltas []string
+	content, err := Stream(context.Background(), provider, testRequest, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	require.NoError(t, err)
+	return content, deltas
+}
+
+func TestOpenAIStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, true, body["stream"])
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, delta := range []string{"好", "书"} {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":%q}}]}\n\n", delta)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	content, deltas := collect(t, NewOpenAI(server.URL+"/v1", "gpt", "key", time.Second, 0))
+	assert.Equal(t, "好书", content)
+	assert.Equal(t, []string{"好", "书"}, deltas)
+}
+
+func TestOllamaStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body ollamaChatRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.True(t, body.Stream)
+
+		w.Write([]byte(`{"message":{"role":"assistant","content":"好"},"done":false}` + "\n"))
+		w.Write([]byte(`{"message":{"role":"assistant","content":"书"},"done":false}` + "\n"))
+		w.Write([]byte(`{"message":{"role":"assistant","content":""},"done":true}` + "\n"))
+	}))
+	defer server.Close()
+
+	content, deltas := collect(t, NewOllama(server.URL, "qwen", time.Second, 0))
+	assert.Equal(t, "好书", content)
+	assert.Equal(t, []string{"好", "书"}, deltas)
+}
+
+func TestFakeStream(t *testing.T) {
+	fake := NewFake(func(req Request) (string, error) { return "人类文明与三体文明", nil })
+	fake.ChunkSize = 4
+	content, deltas := collect(t, fake)
+	assert.Equal(t, "人类文明与三体文明", content)
+	assert.Equal(t, []string{"人类文明", "与三体文", "明"}, deltas)
+
+	// 停止接收后不再输出
+	_, err := fake.Stream(context.Background(), testRequest, func(string) error { return errors.New("closed") })
+	assert.EqualError(t, err, "closed")
+}
+
+func TestSplitByTokens(t *testing.T) {
+	assert.Equal(t, 4, EstimateTokens("三体文明"))
+	assert.Equal(t, 2, EstimateTokens("hello"))
+
+	text := "第一段内容。\n第二段内容。\n" + strings.Repeat("长", 25) + "\n"
+	parts := SplitByTokens(text, 10)
+	for _, part := range parts {
+		assert.LessOrEqual(t, EstimateTokens(part), 10)
+	}
+	assert.Equal(t, text, strings.Join(parts, ""))
+	assert.Equal(t, "第一段内容。\n", parts[0])
+	assert.Equal(t, "第二段内容。\n", parts[1])
+
+	assert.Equal(t, []string{"短"}, SplitByTokens("短", 10))
+}
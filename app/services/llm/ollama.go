@@ -0,0 +1,139 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 未配置地址时使用本机的 Ollama
+const defaultOllamaURL = "http://localhost:11434"
+
+// Ollama 调用 Ollama 的 /api/chat 接口
+type Ollama struct {
+	baseURL string
+	model   string
+	timeout time.Duration
+	window  int
+	client  *http.Client
+}
+
+// NewOllama 创建 Ollama 服务
+func NewOllama(baseURL, model string, timeout time.Duration, contextWindow int) *Ollama {
+	if baseURL == "" {
+		baseURL = defaultOllamaURL
+	}
+	return &Ollama{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		model:   model,
+		timeout: timeout,
+		window:  contextWindow,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+type ollamaChatRequest struct {
+	Model    string        `json:"model"`
+	Messages []Message     `json:"messages"`
+	Stream   bool          `json:"stream"`
+	Options  ollamaOptions `json:"options"`
+}
+
+type ollamaOptions struct {
+	Temperature float32 `json:"temperature"`
+	NumPredict  int     `json:"num_predict,omitempty"`
+	NumCtx      int     `json:"num_ctx,omitempty"`
+}
+
+type ollamaChatResponse struct {
+	Message Message `json:"message"`
+	Done    bool    `json:"done"`
+	Error   string  `json:"error"`
+}
+
+func (o *Ollama) Name() string {
+	return ProviderOllama + "/" + o.model
+}
+
+func (o *Ollama) ContextWindow() int {
+	return o.window
+}
+
+func (o *Ollama) Complete(ctx context.Context, req Request) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, o.timeout)
+	defer cancel()
+
+	resp, err := o.chat(ctx, req, false)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var chat ollamaChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chat); err != nil {
+		return "", fmt.Errorf("ollama: invalid response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || chat.Error != "" {
+		return "", fmt.Errorf("ollama: %s: %s", resp.Status, chat.Error)
+	}
+	return chat.Message.Content, nil
+}
+
+// Stream 请求流式输出，Ollama 逐行返回 JSON 对象，最后一行的 done 为 true
+func (o *Ollama) Stream(ctx context.Context, req Request, onDelta DeltaFunc) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, o.timeout)
+	defer cancel()
+
+	resp, err := o.chat(ctx, req, true)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var content strings.Builder
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var chunk ollamaChatResponse
+		if err := decoder.Decode(&chunk); err != nil {
+			if err == io.EOF && resp.StatusCode == http.StatusOK {
+				return content.String(), nil
+			}
+			return "", fmt.Errorf("ollama: invalid response: %w", err)
+		}
+		if resp.StatusCode != http.StatusOK || chunk.Error != "" {
+			return "", fmt.Errorf("ollama: %s: %s", resp.Status, chunk.Error)
+		}
+		if chunk.Message.Content != "" {
+			content.WriteString(chunk.Message.Content)
+			if err := onDelta(chunk.Message.Content); err != nil {
+				return "", err
+			}
+		}
+		if chunk.Done {
+			return content.String(), nil
+		}
+	}
+}
+
+func (o *Ollama) chat(ctx context.Context, req Request, stream bool) (*http.Response, error) {
+	body, err := json.Marshal(ollamaChatRequest{
+		Model:    o.model,
+		Messages: req.Messages,
+		Stream:   stream,
+		Options:  ollamaOptions{Temperature: req.Temperature, NumPredict: req.MaxTokens, NumCtx: o.window},
+	})
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseURL+"/api/chat", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	return o.client.Do(httpReq)
+}
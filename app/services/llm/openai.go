@@ -0,0 +1,97 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// OpenAI 调用 OpenAI 兼容的 chat completions 接口
+type OpenAI struct {
+	client  *openai.Client
+	model   string
+	timeout time.Duration
+	window  int
+}
+
+// NewOpenAI 创建 OpenAI 兼容服务，baseURL 为空时使用 OpenAI 官方地址
+func NewOpenAI(baseURL, model, apiKey string, timeout time.Duration, contextWindow int) *OpenAI {
+	cfg := openai.DefaultConfig(apiKey)
+	if baseURL != "" {
+		cfg.BaseURL = baseURL
+	}
+	cfg.HTTPClient = &http.Client{Timeout: timeout}
+	return &OpenAI{client: openai.NewClientWithConfig(cfg), model: model, timeout: timeout, window: contextWindow}
+}
+
+func (o *OpenAI) Name() string {
+	return ProviderOpenAI + "/" + o.model
+}
+
+func (o *OpenAI) ContextWindow() int {
+	return o.window
+}
+
+func (o *OpenAI) Complete(ctx context.Context, req Request) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, o.timeout)
+	defer cancel()
+
+	resp, err := o.client.CreateChatCompletion(ctx, o.request(req))
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 {
+		return "", errors.New("empty completion")
+	}
+	return resp.Choices[0].Message.Content, nil
+}
+
+func (o *OpenAI) Stream(ctx context.Context, req Request, onDelta DeltaFunc) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, o.timeout)
+	defer cancel()
+
+	chatReq := o.request(req)
+	chatReq.Stream = true
+	stream, err := o.client.CreateChatCompletionStream(ctx, chatReq)
+	if err != nil {
+		return "", err
+	}
+	defer stream.Close()
+
+	var content strings.Builder
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return content.String(), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if len(resp.Choices) == 0 || resp.Choices[0].Delta.Content == "" {
+			continue
+		}
+		delta := resp.Choices[0].Delta.Content
+		content.WriteString(delta)
+		if err := onDelta(delta); err != nil {
+			return "", err
+		}
+	}
+}
+
+func (o *OpenAI) request(req Request) openai.ChatCompletionRequest {
+	messages := make([]openai.ChatCompletionMessage, len(req.Messages))
+	for i, message := range req.Messages {
+		messages[i] = openai.ChatCompletionMessage{Role: message.Role, Content: message.Content}
+	}
+	return openai.ChatCompletionRequest{
+		Model:       o.model,
+		Messages:    messages,
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+	}
+}
@@ -0,0 +1,29 @@
+package llm
+
+import "context"
+
+// DeltaFunc 接收模型陆续生成的内容片段，返回错误时停止生成
+type DeltaFunc func(delta string) error
+
+// Streamer 是支持流式输出的服务
+type Streamer interface {
+	// Stream 在模型生成内容时逐段调用 onDelta，结束后返回完整的回复
+	Stream(ctx context.Context, req Request, onDelta DeltaFunc) (string, error)
+}
+
+// Stream 以流式方式请求模型。服务不支持流式输出时等待完整回复，再作为一个片段交给 onDelta。
+func Stream(ctx context.Context, provider Provider, req Request, onDelta DeltaFunc) (string, error) {
+	if streamer, ok := provider.(Streamer); ok {
+		return streamer.Stream(ctx, req, onDelta)
+	}
+	content, err := provider.Complete(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	if content != "" {
+		if err := onDelta(content); err != nil {
+			return "", err
+		}
+	}
+	return content, nil
+}
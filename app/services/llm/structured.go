@@ -0,0 +1,215 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	ErrNoJSON           = errors.New("no JSON object found in the output")
+	ErrUnterminatedJSON = errors.New("JSON object is not terminated")
+)
+
+// Schema 是 JSON Schema 的一个子集，用于校验模型输出的结构。
+// 支持 type、properties、required、items、minLength、minItems、enum 和 not，minLength 不计首尾空白。
+type Schema struct {
+	Type       string             `json:"type,omitempty"`
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	Required   []string           `json:"required,omitempty"`
+	Items      *Schema            `json:"items,omitempty"`
+	MinLength  int                `json:"minLength,omitempty"`
+	MinItems   int                `json:"minItems,omitempty"`
+	Enum       []interface{}      `json:"enum,omitempty"`
+	Not        *Schema            `json:"not,omitempty"`
+}
+
+// MustParseSchema 解析 JSON 格式的 Schema，格式错误时 panic，用于初始化包级变量
+func MustParseSchema(text string) *Schema {
+	var schema Schema
+	if err := json.Unmarshal([]byte(text), &schema); err != nil {
+		panic(fmt.Sprintf("llm: invalid schema: %v", err))
+	}
+	return &schema
+}
+
+// ValidationError 是模型输出不符合 Schema 时的错误，Problems 列出每一处问题
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "output does not match schema: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate 校验 JSON 值，返回所有问题，路径形如 $.characters[0].name
+func (s *Schema) Validate(value interface{}) []string {
+	var problems []string
+	s.validate("$", value, &problems)
+	return problems
+}
+
+func (s *Schema) validate(path string, value interface{}, problems *[]string) {
+	if s.Type != "" && jsonType(value, s.Type) != s.Type {
+		*problems = append(*problems, fmt.Sprintf("%s: expected %s, got %s", path, s.Type, jsonType(value, s.Type)))
+		return
+	}
+	if len(s.Enum) > 0 && !containsValue(s.Enum, value) {
+		*problems = append(*problems, fmt.Sprintf("%s: must be one of %v", path, s.Enum))
+	}
+	if s.Not != nil && len(s.Not.Validate(value)) == 0 {
+		*problems = append(*problems, fmt.Sprintf("%s: value %v is not allowed", path, value))
+	}
+
+	switch v := value.(type) {
+	case string:
+		if utf8.RuneCountInString(strings.TrimSpace(v)) < s.MinLength {
+			*problems = append(*problems, fmt.Sprintf("%s: must be at least %d characters", path, s.MinLength))
+		}
+	case []interface{}:
+		if len(v) < s.MinItems {
+			*problems = append(*problems, fmt.Sprintf("%s: must have at least %d items", path, s.MinItems))
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, problems)
+			}
+		}
+	case map[string]interface{}:
+		for _, key := range s.Required {
+			if _, ok := v[key]; !ok {
+				*problems = append(*problems, fmt.Sprintf("%s.%s: is required", path, key))
+			}
+		}
+		keys := make([]string, 0, len(s.Properties))
+		for key := range s.Properties {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if field, ok := v[key]; ok {
+				s.Properties[key].validate(path+"."+key, field, problems)
+			}
+		}
+	}
+}
+
+// jsonType 返回 JSON 值的类型名，expected 为 integer 时整数返回 integer
+func jsonType(value interface{}, expected string) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := v.Int64(); err == nil && expected == "integer" {
+			return "integer"
+		}
+		return "number"
+	case float64:
+		if v == float64(int64(v)) && expected == "integer" {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if fmt.Sprint(v) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// DecodeStructured 从模型输出中提取 JSON 对象，按 Schema 校验后解码到 v。
+// 输出可以包含代码块标记、前后的说明文字和多余的尾逗号；不符合 Schema 时返回 *ValidationError。
+func DecodeStructured(output string, schema *Schema, v interface{}) error {
+	raw, err := ExtractJSON(output)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(raw))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	if problems := schema.Validate(value); len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return json.Unmarshal([]byte(raw), v)
+}
+
+// ExtractJSON 返回输出中第一个完整的 JSON 对象，去掉对象中的尾逗号。
+// 有代码块时只在第一个代码块中查找，字符串中的括号不影响配对。
+func ExtractJSON(output string) (string, error) {
+	if start := strings.Index(output, "```"); start >= 0 {
+		block := output[start+3:]
+		if newline := strings.IndexByte(block, '\n'); newline >= 0 && !strings.Contains(block[:newline], "{") {
+			block = block[newline+1:]
+		}
+		if end := strings.Index(block, "```"); end >= 0 {
+			block = block[:end]
+		}
+		if strings.Contains(block, "{") {
+			output = block
+		}
+	}
+
+	start := strings.IndexByte(output, '{')
+	if start < 0 {
+		return "", ErrNoJSON
+	}
+	var b strings.Builder
+	depth := 0
+	inString, escaped := false, false
+	for i := start; i < len(output); i++ {
+		c := output[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			b.WriteByte(c)
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+		case ',':
+			// 跳过紧跟在 } 或 ] 之前的逗号
+			next := strings.TrimLeft(output[i+1:], " \t\r\n")
+			if next != "" && (next[0] == '}' || next[0] == ']') {
+				continue
+			}
+		}
+		b.WriteByte(c)
+		if depth == 0 {
+			return b.String(), nil
+		}
+	}
+	return "", ErrUnterminatedJSON
+}
@@ -0,0 +1,75 @@
+package llm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractJSON(t *testing.T) {
+	cases := []struct {
+		output string
+		want   string
+	}{
+		{`{"a": 1}`, `{"a": 1}`},
+		// 嵌套对象和字符串中的括号
+		{`结果如下：{"a": {"b": [{"c": "}{"}]}} 以上。`, `{"a": {"b": [{"c": "}{"}]}}`},
+		// 代码块和尾逗号
+		{"```json\n{\"a\": [1, 2,],\n \"b\": \"x\",\n}\n```\n{\"c\": 3}", "{\"a\": [1, 2],\n \"b\": \"x\"\n}"},
+		// 字符串中的逗号和转义引号保持不变
+		{`{"a": "x,}\",]"}`, `{"a": "x,}\",]"}`},
+	}
+	for _, c := range cases {
+		got, err := ExtractJSON(c.output)
+		require.NoError(t, err, c.output)
+		assert.Equal(t, c.want, got)
+	}
+
+	_, err := ExtractJSON("没有 JSON")
+	assert.ErrorIs(t, err, ErrNoJSON)
+	_, err = ExtractJSON(`{"a": {"b": 1}`)
+	assert.ErrorIs(t, err, ErrUnterminatedJSON)
+}
+
+var testSchema = MustParseSchema(`{
+	"type": "object",
+	"required": ["name", "tags", "count"],
+	"properties": {
+		"name": {"type": "string", "minLength": 1, "not": {"enum": ["占位"]}},
+		"count": {"type": "integer"},
+		"tags": {"type": "array", "minItems": 1, "items": {"type": "string"}}
+	}
+}`)
+
+func TestDecodeStructured(t *testing.T) {
+	var v struct {
+		Name  string   `json:"name"`
+		Count int      `json:"count"`
+		Tags  []string `json:"tags"`
+	}
+	require.NoError(t, DecodeStructured("```json\n{\"name\": \"三体\", \"count\": 3, \"tags\": [\"科幻\",],}\n```", testSchema, &v))
+	assert.Equal(t, "三体", v.Name)
+	assert.Equal(t, 3, v.Count)
+	assert.Equal(t, []string{"科幻"}, v.Tags)
+
+	err := DecodeStructured(`{"name": "占位", "count": 1.5, "tags": [1]}`, testSchema, &v)
+	var validation *ValidationError
+	require.ErrorAs(t, err, &validation)
+	assert.Equal(t, []string{
+		"$.count: expected integer, got number",
+		"$.name: value 占位 is not allowed",
+		"$.tags[0]: expected string, got number",
+	}, validation.Problems)
+
+	err = DecodeStructured(`{"name": " ", "tags": []}`, testSchema, &v)
+	require.ErrorAs(t, err, &validation)
+	assert.Equal(t, []string{
+		"$.count: is required",
+		"$.name: must be at least 1 characters",
+		"$.tags: must have at least 1 items",
+	}, validation.Problems)
+
+	err = DecodeStructured(`{"name": 三体}`, testSchema, &v)
+	assert.ErrorContains(t, err, "invalid JSON")
+}
@@ -0,0 +1,74 @@
+package llm
+
+import (
+	"math"
+	"strings"
+
+	"github.com/sd0ric4/book-reader-backend/app/services/tokenizer"
+)
+
+// 按字符粗略估计 token 数：中日韩文字约一字一个 token，其他文字约四个字符一个 token。
+// 估计值只用于切分输入以适应上下文窗口，宁可偏大。
+const (
+	cjkTokenCost   = 1.0
+	otherTokenCost = 0.25
+)
+
+func runeTokenCost(r rune) float64 {
+	if tokenizer.IsCJK(r) {
+		return cjkTokenCost
+	}
+	return otherTokenCost
+}
+
+// EstimateTokens 估计文本的 token 数
+func EstimateTokens(text string) int {
+	var cost float64
+	for _, r := range text {
+		cost += runeTokenCost(r)
+	}
+	return int(math.Ceil(cost))
+}
+
+// SplitByTokens 把文本切分为估计不超过 maxTokens 的若干段，优先在换行处切分
+func SplitByTokens(text string, maxTokens int) []string {
+	if maxTokens <= 0 || EstimateTokens(text) <= maxTokens {
+		return []string{text}
+	}
+
+	var parts []string
+	var current strings.Builder
+	var cost float64
+	flush := func() {
+		if strings.TrimSpace(current.String()) != "" {
+			parts = append(parts, current.String())
+		}
+		current.Reset()
+		cost = 0
+	}
+
+	for _, line := range strings.SplitAfter(text, "\n") {
+		lineCost := float64(EstimateTokens(line))
+		if cost+lineCost <= float64(maxTokens) {
+			current.WriteString(line)
+			cost += lineCost
+			continue
+		}
+		flush()
+		if lineCost <= float64(maxTokens) {
+			current.WriteString(line)
+			cost = lineCost
+			continue
+		}
+		// 单行超出预算时按字符硬切
+		for _, r := range line {
+			if cost+runeTokenCost(r) > float64(maxTokens) {
+				flush()
+			}
+			current.WriteRune(r)
+			cost += runeTokenCost(r)
+		}
+	}
+	flush()
+	return parts
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sd0ric4/book-reader-backend/app/models"
+	"gorm.io/gorm"
+)
+
+// 同步时的合并策略
+const (
+	SyncPolicyFurthest = "furthest" // 保留读得最远的位置
+	SyncPolicyRecent   = "recent"   // 保留设备本地时间最新的位置
+)
+
+// 乐观锁冲突时的最大重试次数
+const maxSyncRetries = 5
+
+var ErrSyncConflict = errors.New("too many concurrent progress updates")
+
+// SyncProgressRequest 是设备上报的本地阅读位置
+type SyncProgressRequest struct {
+	DeviceID   string  `json:"device_id"`
+	ChapterID  *uint   `json:"chapter_id"`
+	Offset     int     `json:"offset"`
+	CFI        string  `json:"cfi"`
+	Percentage float64 `json:"percentage"`
+	// 设备本地记录该位置的时间
+	ClientUpdatedAt time.Time `json:"client_updated_at"`
+	// 设备上次同步时看到的服务端版本，从未同步过为0
+	BaseVersion int64  `json:"base_version"`
+	Policy      string `json:"policy"`
+}
+
+// SyncProgressResult 是同步后的权威进度，Superseded 为 true 时客户端应跳转到服务端位置
+type SyncProgressResult struct {
+	Progress   models.ReadingProgress `json:"progress"`
+	Accepted   bool                   `json:"accepted"`
+	Superseded bool                   `json:"superseded"`
+	Conflict   bool                   `json:"conflict"`
+}
+
+// IsValidSyncPolicy 判断合并策略是否合法
+func IsValidSyncPolicy(policy string) bool {
+	return policy == SyncPolicyFurthest || policy == SyncPolicyRecent
+}
+
+// isFurther 判断 a 的位置是否比 b 更靠后，先比较百分比，再比较章节和章节内偏移
+func isFurther(a, b models.ReadingProgress) bool {
+	if a.Percentage != b.Percentage {
+		return a.Percentage > b.Percentage
+	}
+	if a.ChapterID != nil && b.ChapterID != nil && *a.ChapterID != *b.ChapterID {
+		return *a.ChapterID > *b.ChapterID
+	}
+	return a.Offset > b.Offset
+}
+
+// MergeProgress 判断设备上报的位置是否应覆盖服务端当前位置，并返回是否发生了并发冲突。
+// 设备基于最新版本修改时（BaseVersion 等于当前版本）属于快进，即使往回翻也直接接受；
+// 否则说明期间有其他设备写入过，按策略合并。
+func MergeProgress(current *models.ReadingProgress, incoming models.ReadingProgress, baseVersion int64, policy string) (accept bool, conflict bool) {
+	if current == nil {
+		return true, false
+	}
+	if baseVersion == current.Version {
+		return true, false
+	}
+
+	switch policy {
+	case SyncPolicyRecent:
+		return incoming.DeviceUpdatedAt.After(current.DeviceUpdatedAt), true
+	default:
+		if isFurther(incoming, *current) {
+			return true, true
+		}
+		if isFurther(*current, incoming) {
+			return false, true
+		}
+		// 位置相同，取较新的一次
+		return incoming.DeviceUpdatedAt.After(current.DeviceUpdatedAt), true
+	}
+}
+
+// SyncReadingProgress 合并设备上报的阅读位置，使用版本号做乐观并发控制
+func SyncReadingProgress(db *gorm.DB, userID, bookID uint, req SyncProgressRequest) (*SyncProgressResult, error) {
+	if req.Policy == "" {
+		req.Policy = SyncPolicyFurthest
+	}
+
+	incoming := models.ReadingProgress{
+		UserID:          userID,
+		BookID:          bookID,
+		ChapterID:       req.ChapterID,
+		Offset:          req.Offset,
+		CFI:             req.CFI,
+		Percentage:      req.Percentage,
+		LastReadAt:      time.Now(),
+		DeviceID:        req.DeviceID,
+		DeviceUpdatedAt: req.ClientUpdatedAt,
+	}
+
+	// 同一设备的乱序或重放请求不应覆盖它自己更新的位置
+	stale := false
+	if device, err := models.GetReadingDevice(db, userID, bookID, req.DeviceID); err == nil {
+		stale = req.ClientUpdatedAt.Before(device.ClientUpdatedAt)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	for attempt := 0; attempt < maxSyncRetries; attempt++ {
+		current, err := models.GetReadingProgress(db, userID, bookID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			current = nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		accept, conflict := MergeProgress(current, incoming, req.BaseVersion, req.Policy)
+		if stale && current != nil {
+			accept = false
+		}
+
+		result := &SyncProgressResult{Accepted: accept, Conflict: conflict}
+		if !accept {
+			result.Progress = *current
+			result.Superseded = true
+		} else {
+			candidate := incoming
+			var saved bool
+			if current == nil {
+				candidate.Version = 1
+				saved, err = models.CreateReadingProgressIfAbsent(db, &candidate)
+			} else {
+				candidate.ID = current.ID
+				candidate.CreatedAt = current.CreatedAt
+				saved, err = models.UpdateReadingProgressIfVersion(db, &candidate, current.Version)
+			}
+			if err != nil {
+				return nil, err
+			}
+			// 其他设备抢先写入，重新读取后再合并
+			if !saved {
+				continue
+			}
+			result.Progress = candidate
+		}
+
+		device := &models.ReadingDevice{
+			UserID:          userID,
+			BookID:          bookID,
+			DeviceID:        req.DeviceID,
+			ChapterID:       req.ChapterID,
+			Offset:          req.Offset,
+			CFI:             req.CFI,
+			Percentage:      req.Percentage,
+			ClientUpdatedAt: req.ClientUpdatedAt,
+			SeenVersion:     result.Progress.Version,
+			SyncedAt:        time.Now(),
+		}
+		if stale {
+			// 保留该设备已记录的较新位置，只更新已看到的版本
+			if existing, err := models.GetReadingDevice(db, userID, bookID, req.DeviceID); err == nil {
+				existing.SeenVersion = result.Progress.Version
+				existing.SyncedAt = device.SyncedAt
+				device = existing
+			}
+		}
+		if err := models.UpsertReadingDevice(db, device); err != nil {
+			return nil, err
+		}
+
+		return result, nil
+	}
+
+	return nil, ErrSyncConflict
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sd0ric4/book-reader-backend/app/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeProgress(t *testing.T) {
+	now := time.Now()
+	current := &models.ReadingProgress{Percentage: 50, Version: 3, DeviceUpdatedAt: now}
+
+	testCases := []struct {
+		name           string
+		current        *models.ReadingProgress
+		incoming       models.ReadingProgress
+		baseVersion    int64
+		policy         string
+		expectAccept   bool
+		expectConflict bool
+	}{
+		{
+			name:         "First sync",
+			current:      nil,
+			incoming:     models.ReadingProgress{Percentage: 10},
+			expectAccept: true,
+		},
+		{
+			name:         "Fast-forward may rewind",
+			current:      current,
+			incoming:     models.ReadingProgress{Percentage: 20, DeviceUpdatedAt: now.Add(time.Minute)},
+			baseVersion:  3,
+			policy:       SyncPolicyFurthest,
+			expectAccept: true,
+		},
+		{
+			name:           "Furthest keeps further server position",
+			current:        current,
+			incoming:       models.ReadingProgress{Percentage: 20, DeviceUpdatedAt: now.Add(time.Minute)},
+			baseVersion:    1,
+			policy:         SyncPolicyFurthest,
+			expectAccept:   false,
+			expectConflict: true,
+		},
+		{
+			name:           "Furthest accepts further device position",
+			current:        current,
+			incoming:       models.ReadingProgress{Percentage: 80, DeviceUpdatedAt: now.Add(-time.Hour)},
+			baseVersion:    1,
+			policy:         SyncPolicyFurthest,
+			expectAccept:   true,
+			expectConflict: true,
+		},
+		{
+			name:           "Recent accepts newer device position",
+			current:        current,
+			incoming:       models.ReadingProgress{Percentage: 20, DeviceUpdatedAt: now.Add(time.Minute)},
+			baseVersion:    1,
+			policy:         SyncPolicyRecent,
+			expectAccept:   true,
+			expectConflict: true,
+		},
+		{
+			name:           "Recent rejects older device position",
+			current:        current,
+			incoming:       models.ReadingProgress{Percentage: 80, DeviceUpdatedAt: now.Add(-time.Minute)},
+			baseVersion:    1,
+			policy:         SyncPolicyRecent,
+			expectAccept:   false,
+			expectConflict: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			accept, conflict := MergeProgress(tc.current, tc.incoming, tc.baseVersion, tc.policy)
+			assert.Equal(t, tc.expectAccept, accept)
+			assert.Equal(t, tc.expectConflict, conflict)
+		})
+	}
+}
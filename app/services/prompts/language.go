@@ -0,0 +1,106 @@
+package prompts
+
+import (
+	"strings"
+	"unicode"
+)
+
+// ISO 639-2 语言代码对应的 ISO 639-1 代码
+var languageAliases = map[string]string{
+	"chi": "zh",
+	"zho": "zh",
+	"eng": "en",
+	"jpn": "ja",
+	"kor": "ko",
+	"fre": "fr",
+	"fra": "fr",
+	"ger": "de",
+	"deu": "de",
+}
+
+// Normalize 把 zh-CN、zh_TW、eng 等语言代码统一为 ISO 639-1 的两字母代码，无法识别时返回空
+func Normalize(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if i := strings.IndexAny(code, "-_"); i >= 0 {
+		code = code[:i]
+	}
+	if alias, ok := languageAliases[code]; ok {
+		return alias
+	}
+	if len(code) != 2 {
+		return ""
+	}
+	for _, r := range code {
+		if r < 'a' || r > 'z' {
+			return ""
+		}
+	}
+	return code
+}
+
+// 检测语言时最多查看的字符数
+const detectSampleRunes = 4000
+
+// 英文中常见的词，用于区分英文和其他使用拉丁字母的语言
+var englishWords = map[string]bool{
+	"the": true, "and": true, "of": true, "to": true, "in": true, "is": true,
+	"was": true, "he": true, "she": true, "that": true, "it": true, "with": true,
+}
+
+// Detect 根据文字判断语言，返回 zh、ja、ko、en，无法判断时返回空
+func Detect(text string) string {
+	var han, kana, hangul, latin int
+	var word strings.Builder
+	words, english := 0, 0
+	countWord := func() {
+		if word.Len() == 0 {
+			return
+		}
+		words++
+		if englishWords[strings.ToLower(word.String())] {
+			english++
+		}
+		word.Reset()
+	}
+
+	n := 0
+	for _, r := range text {
+		if n++; n > detectSampleRunes {
+			break
+		}
+		switch {
+		case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r):
+			kana++
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+			word.WriteRune(r)
+			continue
+		}
+		countWord()
+	}
+	countWord()
+
+	// 一个汉字大致相当于一个单词，按5个字母一个单词比较
+	cjk := han + kana + hangul
+	switch {
+	case cjk == 0 && latin == 0:
+		return ""
+	case cjk*5 >= latin:
+		switch {
+		case kana*10 >= cjk:
+			return "ja"
+		case hangul*2 >= cjk:
+			return "ko"
+		default:
+			return "zh"
+		}
+	case words > 0 && english*10 >= words:
+		return "en"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,188 @@
+// Package prompts 管理生成摘要和回答问题使用的提示词模板。
+// 每种语言一个 YAML 文件，包含版本号、少样本示例和各个步骤的模板；内置中文和英文模板，
+// 也可以从目录加载文件覆盖或增加语言。修改模板时需要增加版本号，保存的摘要会记录生成它的模板版本。
+package prompts
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v2"
+)
+
+// 模板名称
+const (
+	TitleSummary   = "title_summary"   // 只根据书名和作者生成摘要
+	BookSummary    = "book_summary"    // 根据章节概要生成全书摘要
+	ChapterSummary = "chapter_summary" // 概括一章或一章的一部分
+	MergeSummary   = "merge_summary"   // 合并几段概要
+	Repair         = "repair"          // 要求模型修正不符合要求的输出
+	Answer         = "answer"          // 根据检索到的段落回答读者的问题
+)
+
+// DefaultLanguage 是无法确定语言时使用的语言
+const DefaultLanguage = "zh"
+
+var requiredTemplates = []string{TitleSummary, BookSummary, ChapterSummary, MergeSummary, Repair, Answer}
+
+var ErrMissingTemplate = errors.New("missing prompt template")
+
+//go:embed templates/*.yaml
+var builtin embed.FS
+
+// Example 是少样本示例，Output 为期望的输出
+type Example struct {
+	Title  string `yaml:"title"`
+	Author string `yaml:"author"`
+	Output string `yaml:"output"`
+}
+
+// Data 是渲染模板的参数，Examples 由 Set 自动填入
+type Data struct {
+	Title    string
+	Author   string
+	Chapter  string    // 章节名
+	Part     int       // 章节分段概括时的第几部分，从1开始
+	Parts    int       // 章节分成的部分数
+	Content  string    // 章节内容、概要或检索到的段落
+	Problems string    // 需要修正的问题
+	Question string    // 读者的问题
+	Examples []Example // 少样本示例
+}
+
+// Set 是一种语言的一组模板
+type Set struct {
+	Language     string            `yaml:"language"`
+	Version      int               `yaml:"version"`
+	Placeholders []string          `yaml:"placeholders"` // 模板中人物名的占位符，模型照抄时视为无效
+	Examples     []Example         `yaml:"examples"`
+	Templates    map[string]string `yaml:"templates"`
+
+	parsed map[string]*template.Template
+}
+
+// VersionTag 返回模板的语言和版本，如 zh-v1
+func (s *Set) VersionTag() string {
+	return fmt.Sprintf("%s-v%d", s.Language, s.Version)
+}
+
+// Render 渲染模板
+func (s *Set) Render(name string, data Data) (string, error) {
+	tmpl, ok := s.parsed[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s/%s", ErrMissingTemplate, s.Language, name)
+	}
+	data.Examples = s.Examples
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func (s *Set) parse() error {
+	if s.Language == "" || s.Version <= 0 {
+		return errors.New("language and a positive version are required")
+	}
+	s.parsed = make(map[string]*template.Template, len(s.Templates))
+	for _, name := range requiredTemplates {
+		text, ok := s.Templates[name]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrMissingTemplate, name)
+		}
+		tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return err
+		}
+		s.parsed[name] = tmpl
+	}
+	return nil
+}
+
+// Registry 按语言保存模板
+type Registry struct {
+	sets map[string]*Set
+}
+
+// Default 返回内置模板
+func Default() *Registry {
+	registry := &Registry{sets: make(map[string]*Set)}
+	if err := registry.load(builtin, "templates"); err != nil {
+		panic(fmt.Sprintf("prompts: invalid builtin templates: %v", err))
+	}
+	return registry
+}
+
+// Load 返回内置模板，dir 不为空时再加载目录中的 *.yaml 文件，同一语言的文件替换内置模板
+func Load(dir string) (*Registry, error) {
+	registry := Default()
+	if dir == "" {
+		return registry, nil
+	}
+	if err := registry.load(os.DirFS(dir), "."); err != nil {
+		return nil, err
+	}
+	return registry, nil
+}
+
+func (r *Registry) load(fsys fs.FS, dir string) error {
+	names, err := fs.Glob(fsys, path.Join(dir, "*.yaml"))
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		var set Set
+		if err := yaml.Unmarshal(data, &set); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if err := set.parse(); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		r.sets[set.Language] = &set
+	}
+	return nil
+}
+
+// Get 返回语言的模板，没有该语言时返回默认语言的模板
+func (r *Registry) Get(language string) *Set {
+	if set, ok := r.sets[Normalize(language)]; ok {
+		return set
+	}
+	return r.sets[DefaultLanguage]
+}
+
+// Supports 判断是否有该语言的模板
+func (r *Registry) Supports(language string) bool {
+	_, ok := r.sets[Normalize(language)]
+	return ok
+}
+
+// Languages 返回支持的语言
+func (r *Registry) Languages() []string {
+	languages := make([]string, 0, len(r.sets))
+	for language := range r.sets {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// Placeholders 返回所有语言模板中的人物名占位符
+func (r *Registry) Placeholders() []string {
+	var placeholders []string
+	for _, language := range r.Languages() {
+		placeholders = append(placeholders, r.sets[language].Placeholders...)
+	}
+	return placeholders
+}
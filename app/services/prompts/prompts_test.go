@@ -0,0 +1,102 @@
+package prompts
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefault(t *testing.T) {
+	registry := Default()
+	assert.Equal(t, []string{"en", "zh"}, registry.Languages())
+	assert.ElementsMatch(t, []string{"Character 1", "Character 2", "人物1", "人物2"}, registry.Placeholders())
+
+	zh := registry.Get("zh-CN")
+	assert.Equal(t, "zh-v1", zh.VersionTag())
+	prompt, err := zh.Render(TitleSummary, Data{Title: "三体", Author: "刘慈欣"})
+	require.NoError(t, err)
+	assert.Contains(t, prompt, `"title": "三体"`)
+	assert.Contains(t, prompt, "贾宝玉")
+	assert.Contains(t, prompt, "现在请总结 刘慈欣 的《三体》：")
+
+	prompt, err = zh.Render(ChapterSummary, Data{Title: "三体", Author: "刘慈欣", Chapter: "第1章", Part: 2, Parts: 3, Content: "内容"})
+	require.NoError(t, err)
+	assert.Contains(t, prompt, "“第1章”一章第2/3部分的内容")
+	prompt, err = zh.Render(ChapterSummary, Data{Chapter: "第1章", Part: 1, Parts: 1})
+	require.NoError(t, err)
+	assert.Contains(t, prompt, "“第1章”一章的内容")
+
+	en := registry.Get("eng")
+	prompt, err = en.Render(TitleSummary, Data{Title: "Dune", Author: "Frank Herbert"})
+	require.NoError(t, err)
+	assert.Contains(t, prompt, `Now summarise "Dune" by Frank Herbert:`)
+	assert.Contains(t, prompt, "Elizabeth Bennet")
+
+	prompt, err = zh.Render(Answer, Data{Title: "三体", Author: "刘慈欣", Question: "叶文洁是谁？", Content: "[1] 第1章：叶文洁在红岸基地工作。"})
+	require.NoError(t, err)
+	assert.Contains(t, prompt, "[1] 第1章：叶文洁在红岸基地工作。")
+	assert.Contains(t, prompt, "问题：叶文洁是谁？")
+
+	// 没有的语言使用默认语言
+	assert.Equal(t, "zh", registry.Get("fr").Language)
+	assert.False(t, registry.Supports("fr"))
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	fr := `language: fr
+version: 2
+placeholders: ["Personnage 1"]
+templates:
+  title_summary: "Résumez « {{.Title}} » de {{.Author}}"
+  book_summary: "{{.Content}}"
+  chapter_summary: "{{.Content}}"
+  merge_summary: "{{.Content}}"
+  repair: "{{.Problems}}"
+  answer: "{{.Question}}"
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "fr.yaml"), []byte(fr), 0644))
+	registry, err := Load(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"en", "fr", "zh"}, registry.Languages())
+	set := registry.Get("fr-FR")
+	assert.Equal(t, "fr-v2", set.VersionTag())
+	prompt, err := set.Render(TitleSummary, Data{Title: "Dune", Author: "Herbert"})
+	require.NoError(t, err)
+	assert.Equal(t, "Résumez « Dune » de Herbert", prompt)
+
+	// 缺少模板的文件无法加载
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "de.yaml"), []byte("language: de\nversion: 1\ntemplates: {}\n"), 0644))
+	_, err = Load(dir)
+	assert.ErrorIs(t, err, ErrMissingTemplate)
+}
+
+func TestNormalize(t *testing.T) {
+	cases := map[string]string{
+		"zh-CN": "zh",
+		"zh_TW": "zh",
+		"EN-us": "en",
+		"eng":   "en",
+		"chi":   "zh",
+		"fr":    "fr",
+		"":      "",
+		"x1":    "",
+		"latin": "",
+	}
+	for code, want := range cases {
+		assert.Equal(t, want, Normalize(code), code)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	assert.Equal(t, "zh", Detect("叶文洁在红岸基地工作。"))
+	assert.Equal(t, "zh", Detect("《三体》Three Body 三部曲"))
+	assert.Equal(t, "ja", Detect("吾輩は猫である。名前はまだ無い。"))
+	assert.Equal(t, "ko", Detect("나는 고양이로소이다"))
+	assert.Equal(t, "en", Detect("It is a truth universally acknowledged, that a single man in possession of a good fortune must be in want of a wife."))
+	assert.Equal(t, "", Detect("Longtemps, je me suis couché de bonne heure."))
+	assert.Equal(t, "", Detect("1984"))
+}
@@ -0,0 +1,111 @@
+// Package rag 为针对书籍内容的问答检索相关段落。
+// 章节内容按字符切分为相互重叠的片段，每本书建立一个片段级的 BM25 索引；
+// 检索时可以限定只使用读者已经读过的内容，避免剧透。
+package rag
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/sd0ric4/book-reader-backend/app/models"
+)
+
+// 默认的片段长度和相邻片段的重叠长度（字符数）
+const (
+	DefaultChunkSize    = 400
+	DefaultChunkOverlap = 80
+)
+
+// Chunk 是章节中的一个片段，Start/End 为它在章节内容中的字符（rune）偏移
+type Chunk struct {
+	ChapterID   uint   `json:"chapter_id"`
+	ChapterName string `json:"chapter_name"`
+	Position    int    `json:"-"` // 章节在书中的顺序，从0开始
+	Start       int    `json:"start"`
+	End         int    `json:"end"`
+	Text        string `json:"text"`
+}
+
+// Clip 返回截断到章节偏移 offset 之前的片段，片段全部在 offset 之后时返回 false
+func (c Chunk) Clip(offset int) (Chunk, bool) {
+	if offset <= c.Start {
+		return Chunk{}, false
+	}
+	if offset >= c.End {
+		return c, true
+	}
+	c.Text = string([]rune(c.Text)[:offset-c.Start])
+	c.End = offset
+	return c, true
+}
+
+// SplitChapters 按顺序切分各章节的内容，跳过没有内容的章节
+func SplitChapters(chapters []models.BookChapter, size, overlap int) []Chunk {
+	var chunks []Chunk
+	for i, chapter := range chapters {
+		runes := []rune(chapter.ChapterContent)
+		for _, span := range splitText(runes, size, overlap) {
+			text := string(runes[span[0]:span[1]])
+			if strings.TrimSpace(text) == "" {
+				continue
+			}
+			chunks = append(chunks, Chunk{
+				ChapterID:   chapter.ID,
+				ChapterName: chapter.ChapterName,
+				Position:    i,
+				Start:       span[0],
+				End:         span[1],
+				Text:        text,
+			})
+		}
+	}
+	return chunks
+}
+
+// splitText 把文本切分为长度不超过 size、相邻重叠约 overlap 个字符的片段，
+// 尽量在段落或句子结束处切分
+func splitText(runes []rune, size, overlap int) [][2]int {
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = 0
+	}
+
+	var spans [][2]int
+	for start := 0; start < len(runes); {
+		end := min(start+size, len(runes))
+		if end < len(runes) {
+			end = boundary(runes, start+size/2, end)
+		}
+		spans = append(spans, [2]int{start, end})
+		if end == len(runes) {
+			break
+		}
+		start = max(end-overlap, start+1)
+	}
+	return spans
+}
+
+// boundary 在 runes[from:to] 中从后往前查找段落或句子的结束处，找不到时返回 to
+func boundary(runes []rune, from, to int) int {
+	sentence := -1
+	for i := to; i > from; i-- {
+		r := runes[i-1]
+		if r == '\n' {
+			return i
+		}
+		// 英文句点后需要是空白，避免在 3.5、Mr.Darcy 这样的位置切分
+		if sentence < 0 && isSentenceEnd(r) && (r != '.' || i == len(runes) || unicode.IsSpace(runes[i])) {
+			sentence = i
+		}
+	}
+	if sentence > 0 {
+		return sentence
+	}
+	return to
+}
+
+func isSentenceEnd(r rune) bool {
+	return strings.ContainsRune("。！？!?.；;…", r)
+}
@@ -0,0 +1,172 @@
+package rag
+
+import (
+	"math"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/sd0ric4/book-reader-backend/app/services/tokenizer"
+)
+
+// BM25 参数
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Bound 是检索范围：只检索第 Position 章（按章节顺序，从0开始）之前的章节，
+// 以及该章前 Offset 个字符的内容
+type Bound struct {
+	Position int
+	Offset   int
+}
+
+// Hit 是一个命中的片段
+type Hit struct {
+	Chunk Chunk
+	Score float64
+}
+
+// Index 是一本书的片段索引，建立后只读，可以并发使用
+type Index struct {
+	tokenizer tokenizer.Tokenizer
+	chunks    []Chunk
+	postings  map[string][]posting
+	lengths   []int
+	avgLength float64
+}
+
+// posting 记录词项在片段中出现的位置（片段内的字符偏移），用于截断片段时重新计算词频
+type posting struct {
+	chunk     int
+	positions []int
+}
+
+// NewIndex 为一本书的片段建立索引
+func NewIndex(t tokenizer.Tokenizer, chunks []Chunk) *Index {
+	index := &Index{
+		tokenizer: t,
+		chunks:    chunks,
+		postings:  make(map[string][]posting),
+		lengths:   make([]int, len(chunks)),
+	}
+	total := 0
+	for i, chunk := range chunks {
+		tokens := t.Tokenize(chunk.Text)
+		positions := make(map[string][]int)
+		var terms []string
+		for _, token := range tokens {
+			if _, ok := positions[token.Term]; !ok {
+				terms = append(terms, token.Term)
+			}
+			positions[token.Term] = append(positions[token.Term], token.Start)
+		}
+		for _, term := range terms {
+			index.postings[term] = append(index.postings[term], posting{chunk: i, positions: positions[term]})
+		}
+		index.lengths[i] = len(tokens)
+		total += len(tokens)
+	}
+	if len(chunks) > 0 {
+		index.avgLength = float64(total) / float64(len(chunks))
+	}
+	return index
+}
+
+// Len 返回片段数
+func (x *Index) Len() int {
+	return len(x.chunks)
+}
+
+// Search 按相关度返回最多 limit 个命中的片段。bound 不为空时只检索范围内的内容，
+// 跨越范围的片段截断后按截断的内容计分，范围之外的内容不影响结果。
+func (x *Index) Search(query string, limit int, bound *Bound) []Hit {
+	terms := make(map[string]bool)
+	for _, term := range tokenizer.Terms(x.tokenizer, query) {
+		terms[term] = true
+	}
+
+	// 截断后的片段以截断后的长度计算
+	visible := func(i int) (Chunk, bool) {
+		chunk := x.chunks[i]
+		if bound == nil || chunk.Position < bound.Position {
+			return chunk, true
+		}
+		if chunk.Position > bound.Position {
+			return Chunk{}, false
+		}
+		return chunk.Clip(bound.Offset)
+	}
+
+	total := 0
+	for i := range x.chunks {
+		if _, ok := visible(i); ok {
+			total++
+		}
+	}
+
+	scores := make(map[int]float64)
+	clipped := make(map[int]Chunk)
+	for term := range terms {
+		type match struct {
+			chunk int
+			freq  int
+		}
+		var matches []match
+		termLen := utf8.RuneCountInString(term)
+		for _, p := range x.postings[term] {
+			chunk, ok := visible(p.chunk)
+			if !ok {
+				continue
+			}
+			freq := 0
+			for _, pos := range p.positions {
+				if x.chunks[p.chunk].Start+pos+termLen <= chunk.End {
+					freq++
+				}
+			}
+			if freq == 0 {
+				continue
+			}
+			if chunk.End != x.chunks[p.chunk].End {
+				clipped[p.chunk] = chunk
+			}
+			matches = append(matches, match{p.chunk, freq})
+		}
+
+		df := float64(len(matches))
+		idf := math.Log(1 + (float64(total)-df+0.5)/(df+0.5))
+		for _, m := range matches {
+			length := float64(x.lengths[m.chunk])
+			if chunk, ok := clipped[m.chunk]; ok {
+				length = float64(len(x.tokenizer.Tokenize(chunk.Text)))
+			}
+			freq := float64(m.freq)
+			norm := freq + bm25K1*(1-bm25B+bm25B*length/max(x.avgLength, 1))
+			scores[m.chunk] += idf * freq * (bm25K1 + 1) / norm
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for i, score := range scores {
+		chunk := x.chunks[i]
+		if c, ok := clipped[i]; ok {
+			chunk = c
+		}
+		hits = append(hits, Hit{Chunk: chunk, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		a, b := hits[i], hits[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Chunk.Position != b.Chunk.Position {
+			return a.Chunk.Position < b.Chunk.Position
+		}
+		return a.Chunk.Start < b.Chunk.Start
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
@@ -0,0 +1,81 @@
+package rag
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sd0ric4/book-reader-backend/app/models"
+	"github.com/sd0ric4/book-reader-backend/app/services/tokenizer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitChapters(t *testing.T) {
+	content := strings.Repeat("叶文洁在红岸基地工作。", 10) + "\n" + strings.Repeat("汪淼看到了倒计时。", 10)
+	chapters := []models.BookChapter{
+		{ID: 7, ChapterName: "第1章", ChapterContent: content},
+		{ID: 8, ChapterName: "空章", ChapterContent: "  \n"},
+		{ID: 9, ChapterName: "第3章", ChapterContent: "短"},
+	}
+	chunks := SplitChapters(chapters, 60, 10)
+	require.Greater(t, len(chunks), 3)
+
+	runes := []rune(content)
+	last := chunks[0]
+	assert.Equal(t, 0, last.Start)
+	for _, chunk := range chunks[1:] {
+		if chunk.ChapterID != 7 {
+			break
+		}
+		// 相邻片段重叠，且每个片段都是原文的一段
+		assert.LessOrEqual(t, chunk.Start, last.End)
+		assert.Greater(t, chunk.Start, last.Start)
+		assert.LessOrEqual(t, chunk.End-chunk.Start, 60)
+		assert.Equal(t, string(runes[chunk.Start:chunk.End]), chunk.Text)
+		last = chunk
+	}
+	assert.Equal(t, len(runes), last.End)
+	// 尽量在句子结束处切分
+	assert.True(t, strings.HasSuffix(chunks[0].Text, "。"), chunks[0].Text)
+
+	end := chunks[len(chunks)-1]
+	assert.Equal(t, uint(9), end.ChapterID)
+	assert.Equal(t, 2, end.Position)
+	assert.Equal(t, "短", end.Text)
+}
+
+func TestIndexSearch(t *testing.T) {
+	chapters := []models.BookChapter{
+		{ID: 1, ChapterName: "第1章", ChapterContent: "叶文洁在红岸基地工作。她是一位天体物理学家。"},
+		{ID: 2, ChapterName: "第2章", ChapterContent: "汪淼参加了科学边界的聚会。"},
+		{ID: 3, ChapterName: "第3章", ChapterContent: "汪淼在游戏中见到了周文王。后来叶文洁向三体文明发出了信号。"},
+	}
+	index := NewIndex(tokenizer.NewBigram(), SplitChapters(chapters, 400, 0))
+	assert.Equal(t, 3, index.Len())
+
+	hits := index.Search("叶文洁是谁？", 5, nil)
+	require.Len(t, hits, 2)
+	assert.Equal(t, uint(1), hits[0].Chunk.ChapterID)
+	assert.Equal(t, uint(3), hits[1].Chunk.ChapterID)
+
+	// 只读到第2章时看不到第3章
+	hits = index.Search("叶文洁", 5, &Bound{Position: 1, Offset: 0})
+	require.Len(t, hits, 1)
+	assert.Equal(t, uint(1), hits[0].Chunk.ChapterID)
+
+	// 读到第3章中间时只检索已读的部分，片段被截断
+	hits = index.Search("汪淼 叶文洁", 5, &Bound{Position: 2, Offset: 12})
+	for _, hit := range hits {
+		assert.NotContains(t, hit.Chunk.Text, "三体")
+		if hit.Chunk.ChapterID == 3 {
+			assert.Equal(t, 12, hit.Chunk.End)
+			assert.Equal(t, "汪淼在游戏中见到了周文王", hit.Chunk.Text)
+		}
+	}
+	hits = index.Search("三体文明", 5, &Bound{Position: 2, Offset: 12})
+	assert.Empty(t, hits)
+
+	hits = index.Search("汪淼", 1, nil)
+	assert.Len(t, hits, 1)
+	assert.Empty(t, index.Search("", 5, nil))
+}
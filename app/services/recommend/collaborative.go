@@ -0,0 +1,119 @@
+package recommend
+
+import "math"
+
+// 相似度收缩系数：共同读者越少，相似度越向0收缩，避免一两个读者造成的偶然相关
+const similarityShrinkage = 2.0
+
+// Collaborative 是基于物品的协同过滤模型（“读过这本书的人也读过”）。
+// 两本书的相似度是它们在用户兴趣权重上的余弦相似度，构建后只读，可并发使用。
+type Collaborative struct {
+	// 用户 -> 书籍 -> 权重
+	users map[uint]map[uint]float64
+	// 书籍 -> 用户 -> 权重
+	items map[uint]map[uint]float64
+	norms map[uint]float64
+}
+
+// NewCollaborative 由 用户 -> 书籍 -> 兴趣权重 构建模型，只使用正权重
+func NewCollaborative(weights map[uint]map[uint]float64) *Collaborative {
+	c := &Collaborative{
+		users: make(map[uint]map[uint]float64, len(weights)),
+		items: make(map[uint]map[uint]float64),
+		norms: make(map[uint]float64),
+	}
+	for userID, books := range weights {
+		for bookID, weight := range books {
+			if weight <= 0 {
+				continue
+			}
+			if c.users[userID] == nil {
+				c.users[userID] = make(map[uint]float64)
+			}
+			c.users[userID][bookID] = weight
+			if c.items[bookID] == nil {
+				c.items[bookID] = make(map[uint]float64)
+			}
+			c.items[bookID][userID] = weight
+			c.norms[bookID] += weight * weight
+		}
+	}
+	for bookID, sum := range c.norms {
+		c.norms[bookID] = math.Sqrt(sum)
+	}
+	return c
+}
+
+// Len 返回有读者的书籍数
+func (c *Collaborative) Len() int {
+	return len(c.items)
+}
+
+// Similar 返回与一本书有共同读者的书籍及其相似度（不含这本书自身），allow 不为空时只考虑允许的书籍
+func (c *Collaborative) Similar(bookID uint, allow func(bookID uint) bool) map[uint]float64 {
+	dots := make(map[uint]float64)
+	common := make(map[uint]int)
+	for userID, weight := range c.items[bookID] {
+		for other, otherWeight := range c.users[userID] {
+			if other == bookID || (allow != nil && !allow(other)) {
+				continue
+			}
+			dots[other] += weight * otherWeight
+			common[other]++
+		}
+	}
+
+	norm := c.norms[bookID]
+	for other, dot := range dots {
+		n := float64(common[other])
+		dots[other] = dot / (norm * c.norms[other]) * n / (n + similarityShrinkage)
+	}
+	return dots
+}
+
+// Similarity 返回两本书的相似度
+func (c *Collaborative) Similarity(a, b uint) float64 {
+	if a == b {
+		return 1
+	}
+	readersA, readersB := c.items[a], c.items[b]
+	if len(readersB) < len(readersA) {
+		readersA, readersB = readersB, readersA
+	}
+	var dot, common float64
+	for userID, weight := range readersA {
+		if other, ok := readersB[userID]; ok {
+			dot += weight * other
+			common++
+		}
+	}
+	if common == 0 {
+		return 0
+	}
+	return dot / (c.norms[a] * c.norms[b]) * common / (common + similarityShrinkage)
+}
+
+// Recommend 按用户画像（书籍 -> 兴趣权重）为其他书籍打分：各书相似度按兴趣权重加权平均，
+// 负权重（不喜欢的书）会降低相似书籍的得分，结果只保留正分
+func (c *Collaborative) Recommend(profile map[uint]float64, allow func(bookID uint) bool) map[uint]float64 {
+	scores := make(map[uint]float64)
+	var total float64
+	for bookID, weight := range profile {
+		if weight == 0 {
+			continue
+		}
+		total += math.Abs(weight)
+		for other, similarity := range c.Similar(bookID, allow) {
+			scores[other] += weight * similarity
+		}
+	}
+
+	for bookID, score := range scores {
+		if _, ok := profile[bookID]; ok || score <= 0 {
+			delete(scores, bookID)
+			continue
+		}
+		scores[bookID] = score / total
+	}
+	return scores
+}
@@ -0,0 +1,300 @@
+// Package recommend 提供基于内容的推荐所用的稀疏TF-IDF索引。
+// 索引常驻内存，书籍增删改时增量更新，相似度查询只遍历查询向量中各词的倒排表，
+// 不必为每次请求重建稠密的 书籍×词表 矩阵。
+package recommend
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/sd0ric4/book-reader-backend/app/services/tokenizer"
+)
+
+// Vector 是稀疏向量，键为词项
+type Vector map[string]float64
+
+// 自上次全量重算以来变化的文档超过这个比例时，重算所有向量的模
+const staleNormRatio = 0.05
+
+// Index 是书籍文档的稀疏TF-IDF索引，可并发使用。
+// 只保存词频，IDF 在查询时按当前文档数计算。IDF 随文档变化而变化，
+// 但单本书的变化对其他书向量的模影响很小，因此只在变化累积到一定比例后才全量重算，
+// 其间其他书的模是近似值，避免每次更新都遍历整个索引。
+type Index struct {
+	mu        sync.RWMutex
+	tokenizer tokenizer.Tokenizer
+	// 每本书的词频（已除以文档长度）
+	docs map[uint]map[string]float64
+	// 倒排表：词项 -> 书籍 -> 词频
+	postings map[string]map[uint]float64
+	norms    map[uint]float64
+	// 自上次全量重算以来变化的文档数
+	changes int
+}
+
+// NewIndex 创建使用指定分词器的空索引
+func NewIndex(t tokenizer.Tokenizer) *Index {
+	return &Index{
+		tokenizer: t,
+		docs:      make(map[uint]map[string]float64),
+		postings:  make(map[string]map[uint]float64),
+		norms:     make(map[uint]float64),
+	}
+}
+
+// Add 索引（或重新索引）一本书的文档
+func (ix *Index) Add(bookID uint, text string) {
+	tf := ix.termFrequencies(text)
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(bookID)
+	ix.docs[bookID] = tf
+	for term, freq := range tf {
+		posting, ok := ix.postings[term]
+		if !ok {
+			posting = make(map[uint]float64)
+			ix.postings[term] = posting
+		}
+		posting[bookID] = freq
+	}
+	ix.norms[bookID] = ix.norm(tf)
+	ix.changes++
+}
+
+// Remove 从索引中删除一本书
+func (ix *Index) Remove(bookID uint) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(bookID)
+}
+
+func (ix *Index) remove(bookID uint) {
+	tf, ok := ix.docs[bookID]
+	if !ok {
+		return
+	}
+	for term := range tf {
+		delete(ix.postings[term], bookID)
+		if len(ix.postings[term]) == 0 {
+			delete(ix.postings, term)
+		}
+	}
+	delete(ix.docs, bookID)
+	delete(ix.norms, bookID)
+	ix.changes++
+}
+
+// Len 返回已索引的书籍数
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.docs)
+}
+
+// BookIDs 返回已索引的书籍
+func (ix *Index) BookIDs() []uint {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	ids := make([]uint, 0, len(ix.docs))
+	for id := range ix.docs {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Vector 返回一本书归一化后的TF-IDF向量
+func (ix *Index) Vector(bookID uint) (Vector, bool) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	tf, ok := ix.docs[bookID]
+	if !ok {
+		return nil, false
+	}
+	norm := ix.norm(tf)
+	vector := make(Vector, len(tf))
+	if norm == 0 {
+		return vector, true
+	}
+	for term, freq := range tf {
+		vector[term] = freq * ix.idf(term) / norm
+	}
+	return vector, true
+}
+
+// TextVector 返回任意文本归一化后的TF-IDF向量，索引中没有的词被忽略
+func (ix *Index) TextVector(text string) Vector {
+	tf := ix.termFrequencies(text)
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	vector := make(Vector, len(tf))
+	for term, freq := range tf {
+		if _, ok := ix.postings[term]; ok {
+			vector[term] = freq * ix.idf(term)
+		}
+	}
+	return vector.Normalize()
+}
+
+// Similar 返回与查询向量余弦相似度大于0的书籍及其相似度，allow 不为空时只考虑允许的书籍。
+// 只遍历查询中各词的倒排表，耗时与这些倒排表的长度成正比。
+func (ix *Index) Similar(query Vector, allow func(bookID uint) bool) map[uint]float64 {
+	ix.ensureNorms()
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	queryNorm := query.Norm()
+	scores := make(map[uint]float64)
+	if queryNorm == 0 {
+		return scores
+	}
+	for term, weight := range query {
+		if weight == 0 {
+			continue
+		}
+		idf := ix.idf(term)
+		for bookID, freq := range ix.postings[term] {
+			if allow != nil && !allow(bookID) {
+				continue
+			}
+			scores[bookID] += weight * freq * idf
+		}
+	}
+	for bookID, score := range scores {
+		if norm := ix.norms[bookID]; norm > 0 && score > 0 {
+			scores[bookID] = score / (norm * queryNorm)
+		} else {
+			delete(scores, bookID)
+		}
+	}
+	return scores
+}
+
+// TermWeight 是一个词对相似度的贡献
+type TermWeight struct {
+	Term   string  `json:"term"`
+	Weight float64 `json:"weight"`
+}
+
+// Explain 返回查询向量与一本书共有的词及其对余弦相似度的贡献，按贡献降序最多返回 n 个
+func (ix *Index) Explain(query Vector, bookID uint, n int) []TermWeight {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	tf, ok := ix.docs[bookID]
+	queryNorm := query.Norm()
+	norm := ix.norm(tf)
+	if !ok || queryNorm == 0 || norm == 0 {
+		return []TermWeight{}
+	}
+
+	terms := make([]TermWeight, 0)
+	for term, weight := range query {
+		if freq, ok := tf[term]; ok && weight > 0 {
+			terms = append(terms, TermWeight{Term: term, Weight: weight * freq * ix.idf(term) / (queryNorm * norm)})
+		}
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if terms[i].Weight != terms[j].Weight {
+			return terms[i].Weight > terms[j].Weight
+		}
+		return terms[i].Term < terms[j].Term
+	})
+	return terms[:min(n, len(terms))]
+}
+
+// idf 与 services.ComputeTFIDF 相同的平滑IDF，调用方需持有锁
+func (ix *Index) idf(term string) float64 {
+	return math.Log(float64(1+len(ix.docs))/float64(1+len(ix.postings[term]))) + 1
+}
+
+// norm 按当前IDF计算词频向量的模，调用方需持有锁
+func (ix *Index) norm(tf map[string]float64) float64 {
+	var sum float64
+	for term, freq := range tf {
+		weight := freq * ix.idf(term)
+		sum += weight * weight
+	}
+	return math.Sqrt(sum)
+}
+
+// ensureNorms 在变化的文档累积超过 staleNormRatio 后按当前IDF重算所有向量的模
+func (ix *Index) ensureNorms() {
+	ix.mu.RLock()
+	stale := ix.stale()
+	ix.mu.RUnlock()
+	if !stale {
+		return
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if !ix.stale() {
+		return
+	}
+	for bookID, tf := range ix.docs {
+		ix.norms[bookID] = ix.norm(tf)
+	}
+	ix.changes = 0
+}
+
+func (ix *Index) stale() bool {
+	return float64(ix.changes) > staleNormRatio*float64(len(ix.docs))
+}
+
+// termFrequencies 切分文本并返回按文档长度归一化的词频
+func (ix *Index) termFrequencies(text string) map[string]float64 {
+	terms := tokenizer.Terms(ix.tokenizer, text)
+	tf := make(map[string]float64)
+	for _, term := range terms {
+		tf[term]++
+	}
+	for term := range tf {
+		tf[term] /= float64(len(terms))
+	}
+	return tf
+}
+
+// Norm 返回向量的模
+func (v Vector) Norm() float64 {
+	var sum float64
+	for _, weight := range v {
+		sum += weight * weight
+	}
+	return math.Sqrt(sum)
+}
+
+// Normalize 返回单位长度的向量，零向量原样返回
+func (v Vector) Normalize() Vector {
+	norm := v.Norm()
+	if norm == 0 {
+		return v
+	}
+	normalized := make(Vector, len(v))
+	for term, weight := range v {
+		normalized[term] = weight / norm
+	}
+	return normalized
+}
+
+// Dot 返回两个向量的点积
+func (v Vector) Dot(other Vector) float64 {
+	if len(other) < len(v) {
+		v, other = other, v
+	}
+	var sum float64
+	for term, weight := range v {
+		sum += weight * other[term]
+	}
+	return sum
+}
+
+// AddScaled 把 scale*other 加到 v 上
+func (v Vector) AddScaled(scale float64, other Vector) {
+	for term, weight := range other {
+		v[term] += scale * weight
+	}
+}
@@ -0,0 +1,113 @@
+package recommend
+
+import (
+	"testing"
+
+	"github.com/sd0ric4/book-reader-backend/app/services/tokenizer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexSimilar(t *testing.T) {
+	index := NewIndex(tokenizer.NewBigramWithStopWords(tokenizer.DefaultStopWords))
+	index.Add(1, "科幻 太空 三体文明")
+	index.Add(2, "科幻 太空 银河帝国")
+	index.Add(3, "言情 都市 爱情故事")
+	require.Equal(t, 3, index.Len())
+
+	query := index.TextVector("科幻 三体")
+	scores := index.Similar(query, nil)
+	assert.Len(t, scores, 2)
+	assert.Greater(t, scores[1], scores[2])
+	assert.LessOrEqual(t, scores[1], 1.0)
+
+	// 书籍自身的向量与自己的相似度为1
+	vector, ok := index.Vector(3)
+	require.True(t, ok)
+	assert.InDelta(t, 1.0, index.Similar(vector, nil)[3], 1e-9)
+
+	// allow 过滤不允许的书籍
+	scores = index.Similar(query, func(bookID uint) bool { return bookID != 1 })
+	assert.NotContains(t, scores, uint(1))
+
+	// 重新索引和删除会更新倒排表
+	index.Add(2, "言情 爱情")
+	scores = index.Similar(query, nil)
+	assert.NotContains(t, scores, uint(2))
+	index.Remove(1)
+	assert.Empty(t, index.Similar(query, nil))
+	_, ok = index.Vector(1)
+	assert.False(t, ok)
+	assert.ElementsMatch(t, []uint{2, 3}, index.BookIDs())
+}
+
+func TestTopK(t *testing.T) {
+	scores := map[uint]float64{1: 0.5, 2: 0.9, 3: 0.5, 4: 0.1, 5: 0.7}
+	assert.Equal(t, []Scored{{2, 0.9}, {5, 0.7}, {1, 0.5}}, TopK(scores, 3))
+	assert.Len(t, TopK(scores, 10), 5)
+	assert.Empty(t, TopK(scores, 0))
+}
+
+func TestCollaborative(t *testing.T) {
+	model := NewCollaborative(map[uint]map[uint]float64{
+		1: {10: 1, 11: 1, 12: 1},
+		2: {10: 1, 11: 1},
+		3: {10: 1, 11: 0.5, 13: 1},
+		4: {13: 1, 14: -1},
+	})
+	assert.Equal(t, 4, model.Len())
+
+	similar := model.Similar(10, nil)
+	assert.NotContains(t, similar, uint(10))
+	assert.NotContains(t, similar, uint(14))
+	assert.Greater(t, similar[11], similar[12])
+	assert.Greater(t, similar[11], similar[13])
+	// 相似度对称
+	assert.InDelta(t, similar[11], model.Similar(11, nil)[10], 1e-9)
+	assert.NotContains(t, model.Similar(10, func(bookID uint) bool { return bookID != 11 }), uint(11))
+
+	// 画像中的书不会被推荐，不喜欢的书降低相似书籍的得分
+	scores := model.Recommend(map[uint]float64{10: 1}, nil)
+	assert.NotContains(t, scores, uint(10))
+	assert.Greater(t, scores[11], scores[13])
+	disliked := model.Recommend(map[uint]float64{10: 1, 12: -1}, nil)
+	assert.Less(t, disliked[11], scores[11])
+	assert.Empty(t, model.Recommend(map[uint]float64{99: 1}, nil))
+	assert.InDelta(t, similar[13], model.Similarity(10, 13), 1e-9)
+	assert.Zero(t, model.Similarity(10, 14))
+}
+
+func TestExplain(t *testing.T) {
+	index := NewIndex(tokenizer.NewBigramWithStopWords(tokenizer.DefaultStopWords))
+	index.Add(1, "科幻 太空 三体")
+	index.Add(2, "科幻 言情")
+	index.Add(3, "历史")
+
+	query := index.TextVector("科幻 太空")
+	terms := index.Explain(query, 1, 5)
+	require.Len(t, terms, 2)
+	// 稀有的词贡献更大，各词贡献之和等于余弦相似度
+	assert.Equal(t, "太空", terms[0].Term)
+	assert.Equal(t, "科幻", terms[1].Term)
+	assert.InDelta(t, index.Similar(query, nil)[1], terms[0].Weight+terms[1].Weight, 1e-9)
+	assert.Len(t, index.Explain(query, 1, 1), 1)
+	assert.Empty(t, index.Explain(query, 3, 5))
+}
+
+func TestMMR(t *testing.T) {
+	authors := map[uint]string{1: "刘慈欣", 2: "刘慈欣", 3: "刘慈欣", 4: "阿西莫夫", 5: "王小波"}
+	similarity := func(a, b uint) float64 {
+		if authors[a] == authors[b] {
+			return 1
+		}
+		return 0
+	}
+	candidates := []Scored{{1, 0.9}, {2, 0.85}, {3, 0.8}, {4, 0.6}, {5, 0.3}}
+
+	// lambda 为1时只看相关度
+	assert.Equal(t, candidates[:3], MMR(candidates, 3, 1, similarity))
+	// 降低 lambda 后其他作者的书提前，相关度差距过大时仍会选同一作者
+	assert.Equal(t, []Scored{{1, 0.9}, {4, 0.6}, {2, 0.85}}, MMR(candidates, 3, 0.7, similarity))
+	assert.Equal(t, []Scored{{1, 0.9}, {4, 0.6}, {5, 0.3}}, MMR(candidates, 3, 0.5, similarity))
+	assert.Len(t, MMR(candidates, 10, 0.7, similarity), 5)
+}
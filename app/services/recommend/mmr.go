@@ -0,0 +1,43 @@
+package recommend
+
+// MMR 按最大边际相关性（maximal marginal relevance）从候选中依次选出 k 个结果：
+// 每次选择 lambda*相关度 - (1-lambda)*与已选结果的最大相似度 最高的候选，
+// 在相关度和多样性之间折中。相关度先除以最高分归一化到 [0, 1]，与 similarity 的取值范围一致。
+// candidates 应按相关度降序排列，返回的结果保留原始得分。
+func MMR(candidates []Scored, k int, lambda float64, similarity func(a, b uint) float64) []Scored {
+	k = min(k, len(candidates))
+	var top float64
+	for _, candidate := range candidates {
+		top = max(top, candidate.Score)
+	}
+	relevance := func(candidate Scored) float64 {
+		if top <= 0 {
+			return 0
+		}
+		return candidate.Score / top
+	}
+	selected := make([]Scored, 0, k)
+	remaining := append([]Scored(nil), candidates...)
+	// 每个候选与已选结果的最大相似度
+	redundancy := make([]float64, len(remaining))
+
+	for len(selected) < k {
+		best := 0
+		bestValue := 0.0
+		for i, candidate := range remaining {
+			value := lambda*relevance(candidate) - (1-lambda)*redundancy[i]
+			if i == 0 || value > bestValue {
+				best, bestValue = i, value
+			}
+		}
+
+		chosen := remaining[best]
+		selected = append(selected, chosen)
+		remaining = append(remaining[:best], remaining[best+1:]...)
+		redundancy = append(redundancy[:best], redundancy[best+1:]...)
+		for i, candidate := range remaining {
+			redundancy[i] = max(redundancy[i], similarity(candidate.BookID, chosen.BookID))
+		}
+	}
+	return selected
+}
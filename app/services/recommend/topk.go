@@ -0,0 +1,55 @@
+package recommend
+
+import "container/heap"
+
+// Scored 是带得分的书籍
+type Scored struct {
+	BookID uint
+	Score  float64
+}
+
+// TopK 返回得分最高的 k 本书，得分相同时ID小的在前。
+// 使用大小为 k 的小顶堆，耗时 O(n log k)，避免对全部书籍排序。
+func TopK(scores map[uint]float64, k int) []Scored {
+	if k <= 0 {
+		return []Scored{}
+	}
+	h := make(scoredHeap, 0, k)
+	for bookID, score := range scores {
+		item := Scored{BookID: bookID, Score: score}
+		if len(h) < k {
+			heap.Push(&h, item)
+		} else if h.less(h[0], item) {
+			h[0] = item
+			heap.Fix(&h, 0)
+		}
+	}
+
+	result := make([]Scored, len(h))
+	for i := len(h) - 1; i >= 0; i-- {
+		result[i] = heap.Pop(&h).(Scored)
+	}
+	return result
+}
+
+// scoredHeap 是堆顶为最差结果的小顶堆
+type scoredHeap []Scored
+
+// less 判断 a 是否排在 b 之后
+func (h scoredHeap) less(a, b Scored) bool {
+	if a.Score != b.Score {
+		return a.Score < b.Score
+	}
+	return a.BookID > b.BookID
+}
+
+func (h scoredHeap) Len() int           { return len(h) }
+func (h scoredHeap) Less(i, j int) bool { return h.less(h[i], h[j]) }
+func (h scoredHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *scoredHeap) Push(x any)        { *h = append(*h, x.(Scored)) }
+func (h *scoredHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
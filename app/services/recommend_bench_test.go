@@ -0,0 +1,81 @@
+package services
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/sd0ric4/book-reader-backend/app/services/recommend"
+	"gonum.org/v1/gonum/mat"
+)
+
+var benchmarkTags = []string{"科幻", "悬疑", "言情", "历史", "武侠", "奇幻", "传记", "哲学", "经济", "心理", "推理", "古典", "fantasy", "history"}
+
+// benchmarkDocuments 生成 n 本书的标签和简介文档
+func benchmarkDocuments(n int) []string {
+	chars := []rune("天地玄黄宇宙洪荒日月盈昃辰宿列张寒来暑往秋收冬藏闰余成岁律吕调阳云腾致雨露结为霜金生丽水玉出昆冈")
+	r := rand.New(rand.NewSource(1))
+	documents := make([]string, n)
+	for i := range documents {
+		tags := make([]string, 3)
+		for j := range tags {
+			tags[j] = benchmarkTags[r.Intn(len(benchmarkTags))]
+		}
+		description := make([]rune, 60)
+		for j := range description {
+			description[j] = chars[r.Intn(len(chars))]
+		}
+		documents[i] = fmt.Sprintf("%s\n%s\n%s", strings.Join(tags, " "), strings.Join(tags, " "), string(description))
+	}
+	return documents
+}
+
+// 原来的做法：每次请求重建稠密TF-IDF矩阵并逐本计算余弦相似度
+func BenchmarkRecommendDense(b *testing.B) {
+	for _, n := range []int{1000, 5000} {
+		documents := append(benchmarkDocuments(n), "科幻 悬疑 宇宙洪荒")
+		b.Run(fmt.Sprintf("books=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				matrix := ComputeTFIDF(documents)
+				_, cols := matrix.Dims()
+				user := mat.NewVecDense(cols, matrix.RawRowView(n))
+				for j := 0; j < n; j++ {
+					CosineSimilarity(user, mat.NewVecDense(cols, matrix.RawRowView(j)))
+				}
+			}
+		})
+	}
+}
+
+// 预先建立的稀疏索引：每次请求只遍历查询词的倒排表
+func BenchmarkRecommendSparse(b *testing.B) {
+	for _, n := range []int{1000, 5000, 100000} {
+		index := recommend.NewIndex(TFIDFTokenizer)
+		for i, document := range benchmarkDocuments(n) {
+			index.Add(uint(i+1), document)
+		}
+		b.Run(fmt.Sprintf("books=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				scores := index.Similar(index.TextVector("科幻 悬疑 宇宙洪荒"), nil)
+				recommend.TopK(scores, 10)
+			}
+		})
+	}
+}
+
+// 书籍修改后增量更新索引并查询，包含更新后重算向量模的开销
+func BenchmarkRecommendIndexUpdate(b *testing.B) {
+	documents := benchmarkDocuments(100000)
+	index := recommend.NewIndex(TFIDFTokenizer)
+	for i, document := range documents {
+		index.Add(uint(i+1), document)
+	}
+	// 首次查询时全量计算向量的模
+	index.Similar(index.TextVector("科幻"), nil)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		index.Add(uint(i%len(documents)+1), documents[(i+1)%len(documents)])
+		index.Similar(index.TextVector("科幻 悬疑 宇宙洪荒"), nil)
+	}
+}
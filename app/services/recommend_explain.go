@@ -0,0 +1,244 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sd0ric4/book-reader-backend/app/config"
+	"github.com/sd0ric4/book-reader-backend/app/models"
+	"github.com/sd0ric4/book-reader-backend/app/services/recommend"
+	"gorm.io/gorm"
+)
+
+const (
+	// 做多样化时从相关度最高的 limit*diversityPoolFactor 本书中挑选
+	diversityPoolFactor    = 5
+	defaultDiversityLambda = 0.7
+	// 解释中最多列出的词和书
+	explainTermCount = 5
+	explainBookCount = 3
+)
+
+// Explanation 说明推荐的原因：与兴趣匹配的词及其对相似度的贡献，以及促成推荐的已读书籍
+type Explanation struct {
+	Reason         string                 `json:"reason"`
+	Terms          []recommend.TermWeight `json:"terms,omitempty"`
+	BecauseYouRead []RelatedBook          `json:"because_you_read,omitempty"`
+}
+
+// RelatedBook 是促成推荐的一本书及其贡献
+type RelatedBook struct {
+	BookID uint    `json:"book_id"`
+	Title  string  `json:"title"`
+	Weight float64 `json:"weight"`
+}
+
+func diversityLambda() float64 {
+	if config.Config != nil && config.Config.Recommend.DiversityLambda > 0 {
+		return min(config.Config.Recommend.DiversityLambda, 1)
+	}
+	return defaultDiversityLambda
+}
+
+// rankRecommendations 取相关度最高且 user 可见的候选，按作者和标签做MMR多样化，返回按选择顺序排列的推荐。
+// 只对得分最高的候选查询可见性，其中不可见的书较多时扩大范围继续查询，直到候选足够或没有更多的书。
+func rankRecommendations(db *gorm.DB, user *models.User, scores map[uint]float64, limit int) ([]Recommendation, error) {
+	want := limit * diversityPoolFactor
+	booksByID := make(map[uint]models.Book, want)
+	var candidates []recommend.Scored
+	for size, checked := want, 0; ; size *= 2 {
+		// 得分相同时按ID排序，较大的 TopK 以较小的 TopK 为前缀，只需查询新增的候选
+		candidates = recommend.TopK(scores, size)
+		ids := make([]uint, 0, len(candidates)-checked)
+		for _, item := range candidates[checked:] {
+			ids = append(ids, item.BookID)
+		}
+		books, err := models.GetBooksByIDs(db.Scopes(models.VisibleTo(user)), ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, book := range books {
+			booksByID[book.ID] = book
+		}
+		checked = len(candidates)
+		if len(booksByID) >= want || len(candidates) < size {
+			break
+		}
+	}
+
+	pool := make([]recommend.Scored, 0, want)
+	tagsByID := make(map[uint][]string, want)
+	for _, item := range candidates {
+		if book, ok := booksByID[item.BookID]; ok && len(pool) < want {
+			pool = append(pool, item)
+			tagsByID[book.ID] = book.TagList()
+		}
+	}
+
+	// 同一作者和相同标签各占一半
+	similarity := func(a, b uint) float64 {
+		var sameAuthor float64
+		if author := booksByID[a].Author; author != "" && author == booksByID[b].Author {
+			sameAuthor = 1
+		}
+		return 0.5*sameAuthor + 0.5*jaccard(tagsByID[a], tagsByID[b])
+	}
+	chosen := recommend.MMR(pool, limit, diversityLambda(), similarity)
+
+	recommendations := make([]Recommendation, 0, len(chosen))
+	for _, item := range chosen {
+		// 查不到的书（刚被删除）跳过
+		if book, ok := booksByID[item.BookID]; ok {
+			recommendations = append(recommendations, Recommendation{Book: book, Score: item.Score})
+		}
+	}
+	return recommendations, nil
+}
+
+// fillRecommendations 推荐不足 limit 本时，用 user 可见、不在 exclude 中的其他书按ID顺序补足，补足的书得分为0
+func fillRecommendations(db *gorm.DB, user *models.User, recommendations []Recommendation, exclude map[uint]float64, limit int) ([]Recommendation, error) {
+	if len(recommendations) >= limit {
+		return recommendations, nil
+	}
+	ids := make([]uint, 0, len(exclude)+len(recommendations))
+	for id := range exclude {
+		ids = append(ids, id)
+	}
+	for _, recommendation := range recommendations {
+		ids = append(ids, recommendation.Book.ID)
+	}
+	books, err := models.GetBooksExcluding(db.Scopes(models.VisibleTo(user)), ids, limit-len(recommendations))
+	if err != nil {
+		return nil, err
+	}
+	for _, book := range books {
+		recommendations = append(recommendations, Recommendation{Book: book})
+	}
+	return recommendations, nil
+}
+
+// jaccard 返回两组标签的 Jaccard 相似度
+func jaccard(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	setA, setB := stringSet(a), stringSet(b)
+	intersection := 0
+	for tag := range setB {
+		if setA[tag] {
+			intersection++
+		}
+	}
+	return float64(intersection) / float64(len(setA)+len(setB)-intersection)
+}
+
+func stringSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
+
+// explainTerms 返回查询向量中与书籍匹配的词
+func explainTerms(index *recommend.Index, query recommend.Vector, bookID uint) []recommend.TermWeight {
+	return index.Explain(query, bookID, explainTermCount)
+}
+
+// becauseYouRead 返回对推荐贡献最大的已读书籍：内容相似度和协同过滤相似度按兴趣权重和混合权重加权
+func becauseYouRead(index *recommend.Index, collaborative *recommend.Collaborative, weights map[uint]float64,
+	vectors map[uint]recommend.Vector, bookID uint) []RelatedBook {
+	blend := recommendWeights()
+	candidate, _ := index.Vector(bookID)
+
+	var related []RelatedBook
+	for readID, weight := range weights {
+		if weight <= 0 {
+			continue
+		}
+		contribution := weight * (blend.Content*vectors[readID].Dot(candidate) + blend.Collaborative*collaborative.Similarity(readID, bookID))
+		if contribution > 0 {
+			related = append(related, RelatedBook{BookID: readID, Weight: contribution})
+		}
+	}
+	sort.Slice(related, func(i, j int) bool {
+		if related[i].Weight != related[j].Weight {
+			return related[i].Weight > related[j].Weight
+		}
+		return related[i].BookID < related[j].BookID
+	})
+	return related[:min(explainBookCount, len(related))]
+}
+
+// fillBookTitles 填入相关书籍的书名，已被删除或用户看不到的书会被去掉
+func fillBookTitles(db *gorm.DB, user *models.User, recommendations []Recommendation) error {
+	var ids []uint
+	for _, recommendation := range recommendations {
+		for _, related := range recommendation.Explanation.BecauseYouRead {
+			ids = append(ids, related.BookID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	books, err := models.GetBooksByIDs(db.Scopes(models.VisibleTo(user)), ids)
+	if err != nil {
+		return err
+	}
+	titles := make(map[uint]string, len(books))
+	for _, book := range books {
+		titles[book.ID] = book.Title
+	}
+
+	for i := range recommendations {
+		explanation := &recommendations[i].Explanation
+		related := explanation.BecauseYouRead[:0]
+		for _, book := range explanation.BecauseYouRead {
+			if title, ok := titles[book.BookID]; ok {
+				book.Title = title
+				related = append(related, book)
+			}
+		}
+		explanation.BecauseYouRead = related
+	}
+	return nil
+}
+
+// explanationReason 由解释生成一句说明，others 为其他读者的行为（协同过滤和流行度）对得分的贡献。
+// 没有共同的词、也没有其他读者行为支持的推荐（如补足的书）使用中性的说明。
+func explanationReason(explanation Explanation, others float64) string {
+	if len(explanation.BecauseYouRead) > 0 {
+		titles := make([]string, len(explanation.BecauseYouRead))
+		for i, book := range explanation.BecauseYouRead {
+			titles[i] = book.Title
+		}
+		return "Because you read " + strings.Join(titles, ", ")
+	}
+	if len(explanation.Terms) > 0 {
+		return "Matches your interests: " + joinTerms(explanation.Terms)
+	}
+	if others > 0 {
+		return "Popular with other readers"
+	}
+	return "You might also like"
+}
+
+func joinTerms(terms []recommend.TermWeight) string {
+	names := make([]string, len(terms))
+	for i, term := range terms {
+		names[i] = term.Term
+	}
+	return strings.Join(names, ", ")
+}
+
+// similarReason 生成相似书籍的说明
+func similarReason(book *models.Book, collaborative float64, terms []recommend.TermWeight) string {
+	if collaborative > 0 {
+		return fmt.Sprintf("Readers of %s also read this", book.Title)
+	}
+	if len(terms) > 0 {
+		return fmt.Sprintf("Similar to %s: %s", book.Title, joinTerms(terms))
+	}
+	return fmt.Sprintf("Similar to %s", book.Title)
+}
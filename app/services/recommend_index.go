@@ -0,0 +1,136 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/sd0ric4/book-reader-backend/app/config"
+	"github.com/sd0ric4/book-reader-backend/app/models"
+	"github.com/sd0ric4/book-reader-backend/app/services/recommend"
+	"github.com/sd0ric4/book-reader-backend/app/services/tokenizer"
+	"gorm.io/gorm"
+)
+
+// 计算TF-IDF可选的分词器
+const (
+	RecommendTokenizerBigram     = "bigram"
+	RecommendTokenizerDictionary = "dictionary"
+)
+
+// RecommendIndex 是全局的推荐索引，包含所有书籍，查询时按可见性过滤
+var RecommendIndex *recommend.Index
+
+// 保护 RecommendIndex 的读写，并保证索引只从数据库构建一次
+var recommendIndexMu sync.Mutex
+
+// 构建索引时每批读取的书籍数
+const recommendIndexBatchSize = 500
+
+// InitRecommendTokenizer 按配置选择计算TF-IDF的分词器，并加载词典和停用词，需要在构建推荐索引之前调用
+func InitRecommendTokenizer(cfg config.RecommendConfig) error {
+	t, err := newRecommendTokenizer(cfg)
+	if err != nil {
+		return err
+	}
+	TFIDFTokenizer = t
+	return nil
+}
+
+func newRecommendTokenizer(cfg config.RecommendConfig) (tokenizer.Tokenizer, error) {
+	stopWords := tokenizer.DefaultStopWords
+	if cfg.StopWordsPath != "" {
+		file, err := os.Open(cfg.StopWordsPath)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		extra, err := tokenizer.LoadStopWords(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load stop words %s: %w", cfg.StopWordsPath, err)
+		}
+		stopWords = stopWords.Merge(extra)
+	}
+
+	switch cfg.Tokenizer {
+	case "", RecommendTokenizerBigram:
+		return tokenizer.NewBigramWithStopWords(stopWords), nil
+	case RecommendTokenizerDictionary:
+		if cfg.DictionaryPath == "" {
+			return nil, fmt.Errorf("dictionary_path is required for the %s tokenizer", RecommendTokenizerDictionary)
+		}
+		file, err := os.Open(cfg.DictionaryPath)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		dictionary, err := tokenizer.LoadDictionary(file, stopWords)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load dictionary %s: %w", cfg.DictionaryPath, err)
+		}
+		return dictionary, nil
+	default:
+		return nil, fmt.Errorf("unknown tokenizer: %s", cfg.Tokenizer)
+	}
+}
+
+// InitRecommendIndex 从数据库重新构建推荐索引
+func InitRecommendIndex(db *gorm.DB) error {
+	index, err := buildRecommendIndex(db)
+	if err != nil {
+		return err
+	}
+
+	recommendIndexMu.Lock()
+	RecommendIndex = index
+	recommendIndexMu.Unlock()
+	log.Printf("Recommendation index ready, %d books indexed", index.Len())
+	return nil
+}
+
+func buildRecommendIndex(db *gorm.DB) (*recommend.Index, error) {
+	index := recommend.NewIndex(TFIDFTokenizer)
+	err := models.ForEachBook(db, recommendIndexBatchSize, func(book *models.Book) error {
+		index.Add(book.ID, recommendDocument(*book))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// loadRecommendIndex 返回推荐索引，尚未初始化时先从数据库构建
+func loadRecommendIndex(db *gorm.DB) (*recommend.Index, error) {
+	recommendIndexMu.Lock()
+	defer recommendIndexMu.Unlock()
+	if RecommendIndex == nil {
+		index, err := buildRecommendIndex(db)
+		if err != nil {
+			return nil, err
+		}
+		RecommendIndex = index
+	}
+	return RecommendIndex, nil
+}
+
+func currentRecommendIndex() *recommend.Index {
+	recommendIndexMu.Lock()
+	defer recommendIndexMu.Unlock()
+	return RecommendIndex
+}
+
+// UpdateRecommendIndex 在书籍创建或修改后更新它在推荐索引中的文档，索引未初始化时忽略
+func UpdateRecommendIndex(book models.Book) {
+	if index := currentRecommendIndex(); index != nil {
+		index.Add(book.ID, recommendDocument(book))
+	}
+}
+
+// RemoveBookFromRecommendIndex 从推荐索引中删除一本书
+func RemoveBookFromRecommendIndex(bookID uint) {
+	if index := currentRecommendIndex(); index != nil {
+		index.Remove(bookID)
+	}
+}
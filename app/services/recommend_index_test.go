@@ -0,0 +1,44 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sd0ric4/book-reader-backend/app/config"
+	"github.com/sd0ric4/book-reader-backend/app/services/tokenizer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRecommendTokenizer(t *testing.T) {
+	dir := t.TempDir()
+	dictionaryPath := filepath.Join(dir, "dict.txt")
+	stopWordsPath := filepath.Join(dir, "stop_words.txt")
+	require.NoError(t, os.WriteFile(dictionaryPath, []byte("三体文明 10 n\n"), 0o644))
+	require.NoError(t, os.WriteFile(stopWordsPath, []byte("# 自定义停用词\n小说\n"), 0o644))
+
+	// 默认二元切分
+	bigram, err := newRecommendTokenizer(config.RecommendConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"三体", "体文", "文明"}, tokenizer.Terms(bigram, "三体文明"))
+
+	// 按词典分词，停用词文件与内置停用词合并
+	dictionary, err := newRecommendTokenizer(config.RecommendConfig{
+		Tokenizer:      RecommendTokenizerDictionary,
+		DictionaryPath: dictionaryPath,
+		StopWordsPath:  stopWordsPath,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"三体文明"}, tokenizer.Terms(dictionary, "三体文明的小说"))
+
+	for _, cfg := range []config.RecommendConfig{
+		{Tokenizer: "jieba"},
+		{Tokenizer: RecommendTokenizerDictionary},
+		{Tokenizer: RecommendTokenizerDictionary, DictionaryPath: filepath.Join(dir, "missing.txt")},
+		{StopWordsPath: filepath.Join(dir, "missing.txt")},
+	} {
+		_, err := newRecommendTokenizer(cfg)
+		assert.Error(t, err, cfg)
+	}
+}
@@ -0,0 +1,60 @@
+// Package search 提供书籍元数据和章节内容的全文检索。
+// Index 抽象了索引的实现，当前使用进程内的倒排索引（MemoryIndex），可持久化到磁盘，
+// 测试中无需 MySQL 即可使用。
+package search
+
+import "github.com/sd0ric4/book-reader-backend/app/models"
+
+// 被索引的字段
+const (
+	FieldTitle       = "title"
+	FieldAuthor      = "author"
+	FieldDescription = "description"
+	FieldTags        = "tags"
+	FieldContent     = "content"
+)
+
+// Index 是书籍全文索引
+type Index interface {
+	// IndexBook 索引（或重新索引）一本书及其章节，fingerprint 用于判断索引是否过期
+	IndexBook(book models.Book, chapters []models.BookChapter, fingerprint string)
+	// RemoveBook 从索引中删除一本书
+	RemoveBook(bookID uint)
+	// Fingerprint 返回书籍建立索引时的指纹
+	Fingerprint(bookID uint) (string, bool)
+	// BookIDs 返回已索引的书籍
+	BookIDs() []uint
+	// Search 按相关度返回匹配的书籍以及命中结果总数
+	Search(query string, opts Options) ([]Result, int)
+}
+
+// Options 是检索选项
+type Options struct {
+	Limit  int
+	Offset int
+	// Allow 不为空时以命中的全部书籍ID调用一次，只保留返回的书籍，用于按用户可见性过滤
+	Allow func(bookIDs []uint) map[uint]bool
+}
+
+// Result 是一本命中的书，Matches 按相关度排序
+type Result struct {
+	BookID  uint    `json:"book_id"`
+	Score   float64 `json:"score"`
+	Matches []Match `json:"matches"`
+}
+
+// Match 是书中的一处命中：元数据字段或某个章节，Snippet 中的命中词用 <mark> 标出
+type Match struct {
+	Field       string  `json:"field"`
+	ChapterID   uint    `json:"chapter_id,omitempty"`
+	ChapterName string  `json:"chapter_name,omitempty"`
+	Snippet     string  `json:"snippet"`
+	Score       float64 `json:"score"`
+}
+
+// BookResult 是附带书籍信息的检索结果
+type BookResult struct {
+	Book    models.Book `json:"book"`
+	Score   float64     `json:"score"`
+	Matches []Match     `json:"matches"`
+}
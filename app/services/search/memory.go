@@ -0,0 +1,519 @@
+package search
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/sd0ric4/book-reader-backend/app/models"
+	"github.com/sd0ric4/book-reader-backend/app/services/tokenizer"
+)
+
+// BM25 参数
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// 每本书最多返回的章节命中数
+const maxChapterMatches = 3
+
+// 索引文件格式版本，格式变化时旧文件需要重建
+const snapshotVersion = 1
+
+var ErrIncompatibleIndex = errors.New("incompatible search index file")
+
+// 各字段的权重，书名命中比简介命中更重要
+var fieldWeights = map[string]float64{
+	FieldTitle:       3,
+	FieldAuthor:      2,
+	FieldTags:        2,
+	FieldDescription: 1,
+	FieldContent:     1,
+}
+
+type field struct {
+	Name string
+	Text string
+}
+
+// document 是一个被索引的文档：书籍元数据（ChapterID 为0）或一个章节
+type document struct {
+	BookID      uint
+	ChapterID   uint
+	ChapterName string
+	Fields      []field
+	Length      float64  // 加权后的词项数
+	Terms       []string // 文档中出现过的不同词项，删除文档时使用
+}
+
+func (d *document) isChapter() bool {
+	return d.ChapterID != 0
+}
+
+// posting 记录词项在某个文档某个字段中出现的位置（起始字符偏移）
+type posting struct {
+	Doc       int
+	Field     int
+	Positions []int
+}
+
+// snapshot 是索引持久化的格式
+type snapshot struct {
+	Version      int
+	Docs         []document
+	Postings     map[string][]posting
+	Fingerprints map[uint]string
+}
+
+// MemoryIndex 是进程内的倒排索引，可以并发使用
+type MemoryIndex struct {
+	mu           sync.RWMutex
+	tokenizer    tokenizer.Tokenizer
+	docs         []*document // 已删除的位置为 nil
+	free         []int
+	postings     map[string][]posting
+	books        map[uint][]int
+	fingerprints map[uint]string
+	// 按文档类型（0 元数据，1 章节）统计的文档数和总长度，用于计算平均长度
+	docCount    [2]int
+	totalLength [2]float64
+}
+
+// NewMemoryIndex 创建使用指定分词器的空索引
+func NewMemoryIndex(t tokenizer.Tokenizer) *MemoryIndex {
+	return &MemoryIndex{
+		tokenizer:    t,
+		postings:     make(map[string][]posting),
+		books:        make(map[uint][]int),
+		fingerprints: make(map[uint]string),
+	}
+}
+
+func (m *MemoryIndex) IndexBook(book models.Book, chapters []models.BookChapter, fingerprint string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.removeBook(book.ID)
+
+	m.addDocument(&document{
+		BookID: book.ID,
+		Fields: []field{
+			{Name: FieldTitle, Text: book.Title},
+			{Name: FieldAuthor, Text: book.Author},
+			{Name: FieldTags, Text: bookTags(book)},
+			{Name: FieldDescription, Text: book.Description},
+		},
+	})
+	for _, chapter := range chapters {
+		m.addDocument(&document{
+			BookID:      book.ID,
+			ChapterID:   chapter.ID,
+			ChapterName: chapter.ChapterName,
+			Fields:      []field{{Name: FieldContent, Text: chapter.ChapterContent}},
+		})
+	}
+	m.fingerprints[book.ID] = fingerprint
+}
+
+func (m *MemoryIndex) RemoveBook(bookID uint) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.removeBook(bookID)
+}
+
+func (m *MemoryIndex) Fingerprint(bookID uint) (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	fingerprint, ok := m.fingerprints[bookID]
+	return fingerprint, ok
+}
+
+func (m *MemoryIndex) BookIDs() []uint {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	ids := make([]uint, 0, len(m.books))
+	for id := range m.books {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// addDocument 切分文档的各字段并写入倒排表，调用方需持有写锁
+func (m *MemoryIndex) addDocument(doc *document) {
+	var id int
+	if n := len(m.free); n > 0 {
+		id = m.free[n-1]
+		m.free = m.free[:n-1]
+		m.docs[id] = doc
+	} else {
+		id = len(m.docs)
+		m.docs = append(m.docs, doc)
+	}
+
+	seen := make(map[string]bool)
+	for fieldIndex, f := range doc.Fields {
+		positions := make(map[string][]int)
+		var order []string
+		for _, token := range m.tokenizer.Tokenize(f.Text) {
+			if _, ok := positions[token.Term]; !ok {
+				order = append(order, token.Term)
+			}
+			positions[token.Term] = append(positions[token.Term], token.Start)
+			doc.Length += fieldWeights[f.Name]
+		}
+		for _, term := range order {
+			m.postings[term] = append(m.postings[term], posting{Doc: id, Field: fieldIndex, Positions: positions[term]})
+			if !seen[term] {
+				seen[term] = true
+				doc.Terms = append(doc.Terms, term)
+			}
+		}
+	}
+
+	kind := docKind(doc)
+	m.docCount[kind]++
+	m.totalLength[kind] += doc.Length
+	m.books[doc.BookID] = append(m.books[doc.BookID], id)
+}
+
+// removeBook 删除一本书的全部文档，调用方需持有写锁
+func (m *MemoryIndex) removeBook(bookID uint) {
+	for _, id := range m.books[bookID] {
+		doc := m.docs[id]
+		for _, term := range doc.Terms {
+			list := m.postings[term][:0]
+			for _, p := range m.postings[term] {
+				if p.Doc != id {
+					list = append(list, p)
+				}
+			}
+			if len(list) == 0 {
+				delete(m.postings, term)
+			} else {
+				m.postings[term] = list
+			}
+		}
+
+		kind := docKind(doc)
+		m.docCount[kind]--
+		m.totalLength[kind] -= doc.Length
+		m.docs[id] = nil
+		m.free = append(m.free, id)
+	}
+	delete(m.books, bookID)
+	delete(m.fingerprints, bookID)
+}
+
+// docHit 是查询在单个文档上的得分和命中位置
+type docHit struct {
+	score   float64
+	matched int
+	spans   map[int][]span // 字段下标 -> 命中范围
+}
+
+func (m *MemoryIndex) Search(query string, opts Options) ([]Result, int) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	queryTerms := m.expandQuery(query)
+	if len(queryTerms) == 0 {
+		return nil, 0
+	}
+
+	totalDocs := float64(m.docCount[0] + m.docCount[1])
+	hits := make(map[int]*docHit)
+	for _, expansions := range queryTerms {
+		matchedDocs := make(map[int]bool)
+		for _, term := range expansions {
+			list := m.postings[term]
+			df := 0
+			for i, p := range list {
+				if i == 0 || list[i-1].Doc != p.Doc {
+					df++
+				}
+			}
+			idf := math.Log(1 + (totalDocs-float64(df)+0.5)/(float64(df)+0.5))
+			termLen := utf8.RuneCountInString(term)
+
+			tf := make(map[int]float64)
+			for _, p := range list {
+				doc := m.docs[p.Doc]
+				hit, ok := hits[p.Doc]
+				if !ok {
+					hit = &docHit{spans: make(map[int][]span)}
+					hits[p.Doc] = hit
+				}
+				tf[p.Doc] += fieldWeights[doc.Fields[p.Field].Name] * float64(len(p.Positions))
+				for _, pos := range p.Positions {
+					hit.spans[p.Field] = append(hit.spans[p.Field], span{pos, pos + termLen})
+				}
+				matchedDocs[p.Doc] = true
+			}
+			for id, freq := range tf {
+				doc := m.docs[id]
+				avgLength := m.averageLength(docKind(doc))
+				norm := freq + bm25K1*(1-bm25B+bm25B*doc.Length/avgLength)
+				hits[id].score += idf * freq * (bm25K1 + 1) / norm
+			}
+		}
+		for id := range matchedDocs {
+			hits[id].matched++
+		}
+	}
+
+	// 先算完相关度再过滤，只需检查命中的书籍
+	if opts.Allow != nil && len(hits) > 0 {
+		seen := make(map[uint]bool)
+		bookIDs := make([]uint, 0, len(hits))
+		for id := range hits {
+			if bookID := m.docs[id].BookID; !seen[bookID] {
+				seen[bookID] = true
+				bookIDs = append(bookIDs, bookID)
+			}
+		}
+		allowed := opts.Allow(bookIDs)
+		for id := range hits {
+			if !allowed[m.docs[id].BookID] {
+				delete(hits, id)
+			}
+		}
+	}
+
+	// 优先要求文档包含全部查询词，没有这样的文档时退化为部分匹配
+	required := len(queryTerms)
+	fullMatch := false
+	for _, hit := range hits {
+		if hit.matched == required {
+			fullMatch = true
+			break
+		}
+	}
+
+	books := make(map[uint]*bookHit)
+	for id, hit := range hits {
+		if fullMatch && hit.matched < required {
+			continue
+		}
+		doc := m.docs[id]
+		book, ok := books[doc.BookID]
+		if !ok {
+			book = &bookHit{bookID: doc.BookID}
+			books[doc.BookID] = book
+		}
+		if doc.isChapter() {
+			book.chapters = append(book.chapters, scoredDoc{id, hit})
+		} else {
+			book.metadata = &scoredDoc{id, hit}
+		}
+	}
+
+	ranked := make([]*bookHit, 0, len(books))
+	for _, book := range books {
+		sort.Slice(book.chapters, func(i, j int) bool {
+			a, b := book.chapters[i], book.chapters[j]
+			if a.hit.score != b.hit.score {
+				return a.hit.score > b.hit.score
+			}
+			return m.docs[a.id].ChapterID < m.docs[b.id].ChapterID
+		})
+		if book.metadata != nil {
+			book.score += book.metadata.hit.score
+		}
+		if len(book.chapters) > 0 {
+			book.score += book.chapters[0].hit.score
+		}
+		ranked = append(ranked, book)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+		return ranked[i].bookID < ranked[j].bookID
+	})
+
+	total := len(ranked)
+	ranked = paginate(ranked, opts.Offset, opts.Limit)
+	results := make([]Result, len(ranked))
+	for i, book := range ranked {
+		results[i] = m.buildResult(book)
+	}
+	return results, total
+}
+
+type scoredDoc struct {
+	id  int
+	hit *docHit
+}
+
+type bookHit struct {
+	bookID   uint
+	score    float64
+	metadata *scoredDoc
+	chapters []scoredDoc
+}
+
+// buildResult 为命中的书生成片段，只对返回的这一页生成
+func (m *MemoryIndex) buildResult(book *bookHit) Result {
+	result := Result{BookID: book.bookID, Score: book.score, Matches: []Match{}}
+
+	if book.metadata != nil {
+		doc := m.docs[book.metadata.id]
+		for fieldIndex, f := range doc.Fields {
+			if spans, ok := book.metadata.hit.spans[fieldIndex]; ok {
+				result.Matches = append(result.Matches, Match{
+					Field:   f.Name,
+					Snippet: Snippet(f.Text, spans, snippetWidth),
+					Score:   book.metadata.hit.score,
+				})
+			}
+		}
+	}
+
+	for i, chapter := range book.chapters {
+		if i == maxChapterMatches {
+			break
+		}
+		doc := m.docs[chapter.id]
+		result.Matches = append(result.Matches, Match{
+			Field:       FieldContent,
+			ChapterID:   doc.ChapterID,
+			ChapterName: doc.ChapterName,
+			Snippet:     Snippet(doc.Fields[0].Text, chapter.hit.spans[0], snippetWidth),
+			Score:       chapter.hit.score,
+		})
+	}
+	return result
+}
+
+// expandQuery 切分查询，返回每个查询词对应的索引词项。
+// 索引中的中文按二元切分，单个汉字的查询扩展为以它开头或结尾的所有二元词项。
+func (m *MemoryIndex) expandQuery(query string) [][]string {
+	seen := make(map[string]bool)
+	var expanded [][]string
+	for _, token := range m.tokenizer.Tokenize(query) {
+		if seen[token.Term] {
+			continue
+		}
+		seen[token.Term] = true
+
+		terms := []string{token.Term}
+		if r, size := utf8.DecodeRuneInString(token.Term); size == len(token.Term) && tokenizer.IsCJK(r) {
+			for term := range m.postings {
+				if term != token.Term && utf8.RuneCountInString(term) == 2 &&
+					(strings.HasPrefix(term, token.Term) || strings.HasSuffix(term, token.Term)) {
+					terms = append(terms, term)
+				}
+			}
+		}
+		expanded = append(expanded, terms)
+	}
+	return expanded
+}
+
+func (m *MemoryIndex) averageLength(kind int) float64 {
+	if m.docCount[kind] == 0 || m.totalLength[kind] == 0 {
+		return 1
+	}
+	return m.totalLength[kind] / float64(m.docCount[kind])
+}
+
+// Save 把索引写入 w
+func (m *MemoryIndex) Save(w io.Writer) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// 去掉已删除的位置，重新编号
+	remap := make(map[int]int, len(m.docs))
+	snap := snapshot{
+		Version:      snapshotVersion,
+		Postings:     make(map[string][]posting, len(m.postings)),
+		Fingerprints: m.fingerprints,
+	}
+	for id, doc := range m.docs {
+		if doc != nil {
+			remap[id] = len(snap.Docs)
+			snap.Docs = append(snap.Docs, *doc)
+		}
+	}
+	for term, list := range m.postings {
+		remapped := make([]posting, len(list))
+		for i, p := range list {
+			remapped[i] = posting{Doc: remap[p.Doc], Field: p.Field, Positions: p.Positions}
+		}
+		snap.Postings[term] = remapped
+	}
+
+	return gob.NewEncoder(w).Encode(&snap)
+}
+
+// Load 从 r 读取索引，替换当前内容
+func (m *MemoryIndex) Load(r io.Reader) error {
+	var snap snapshot
+	if err := gob.NewDecoder(r).Decode(&snap); err != nil {
+		return err
+	}
+	if snap.Version != snapshotVersion {
+		return ErrIncompatibleIndex
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.docs = make([]*document, len(snap.Docs))
+	m.free = nil
+	m.books = make(map[uint][]int)
+	m.docCount = [2]int{}
+	m.totalLength = [2]float64{}
+	for i := range snap.Docs {
+		doc := &snap.Docs[i]
+		m.docs[i] = doc
+		m.books[doc.BookID] = append(m.books[doc.BookID], i)
+		kind := docKind(doc)
+		m.docCount[kind]++
+		m.totalLength[kind] += doc.Length
+	}
+	m.postings = snap.Postings
+	if m.postings == nil {
+		m.postings = make(map[string][]posting)
+	}
+	m.fingerprints = snap.Fingerprints
+	if m.fingerprints == nil {
+		m.fingerprints = make(map[uint]string)
+	}
+	return nil
+}
+
+func docKind(doc *document) int {
+	if doc.isChapter() {
+		return 1
+	}
+	return 0
+}
+
+// bookTags 把 JSON 数组形式的标签转为空格分隔的文本
+func bookTags(book models.Book) string {
+	var tags []string
+	if err := json.Unmarshal([]byte(book.Tags), &tags); err != nil {
+		return book.Tags
+	}
+	return strings.Join(tags, " ")
+}
+
+func paginate[T any](items []T, offset, limit int) []T {
+	if offset > len(items) {
+		offset = len(items)
+	}
+	items = items[offset:]
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
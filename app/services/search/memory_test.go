@@ -0,0 +1,138 @@
+package search
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sd0ric4/book-reader-backend/app/models"
+	"github.com/sd0ric4/book-reader-backend/app/services/tokenizer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestIndex() *MemoryIndex {
+	index := NewMemoryIndex(tokenizer.NewBigram())
+	index.IndexBook(
+		models.Book{ID: 1, Title: "红楼梦", Author: "曹雪芹", Tags: `["古典","小说"]`, Description: "贾宝玉与林黛玉的故事"},
+		[]models.BookChapter{
+			{ID: 11, ChapterName: "第一回", ChapterContent: "甄士隐梦幻识通灵，贾雨村风尘怀闺秀。"},
+			{ID: 12, ChapterName: "第三回", ChapterContent: "托内兄如海荐西宾，接外孙贾母惜孤女。林黛玉抛父进京都。"},
+		},
+		"v1",
+	)
+	index.IndexBook(
+		models.Book{ID: 2, Title: "西游记", Author: "吴承恩", Tags: `["古典"]`, Description: "唐僧师徒西天取经"},
+		[]models.BookChapter{
+			{ID: 21, ChapterName: "第一回", ChapterContent: "灵根育孕源流出，心性修持大道生。"},
+		},
+		"v1",
+	)
+	index.IndexBook(
+		models.Book{ID: 3, Title: "The Go Programming Language", Author: "Donovan", Tags: `[]`},
+		[]models.BookChapter{
+			{ID: 31, ChapterName: "Tutorial", ChapterContent: "Hello, World. Go is a <compiled> language."},
+		},
+		"v1",
+	)
+	return index
+}
+
+func resultIDs(results []Result) []uint {
+	ids := make([]uint, len(results))
+	for i, result := range results {
+		ids[i] = result.BookID
+	}
+	return ids
+}
+
+func TestMemoryIndexSearch(t *testing.T) {
+	index := newTestIndex()
+
+	results, total := index.Search("林黛玉", Options{})
+	require.Equal(t, 1, total)
+	assert.Equal(t, uint(1), results[0].BookID)
+	// 简介和第三回都命中
+	var fields []string
+	for _, match := range results[0].Matches {
+		fields = append(fields, match.Field)
+	}
+	assert.Equal(t, []string{FieldDescription, FieldContent}, fields)
+	assert.Equal(t, uint(12), results[0].Matches[1].ChapterID)
+	assert.Equal(t, "第三回", results[0].Matches[1].ChapterName)
+	assert.Contains(t, results[0].Matches[1].Snippet, "<mark>林黛玉</mark>")
+
+	// 书名命中排在前面
+	results, _ = index.Search("古典 西游", Options{})
+	assert.Equal(t, []uint{2}, resultIDs(results))
+	results, _ = index.Search("古典", Options{})
+	assert.ElementsMatch(t, []uint{1, 2}, resultIDs(results))
+
+	// 单个汉字也能检索
+	results, _ = index.Search("孕", Options{})
+	assert.Equal(t, []uint{2}, resultIDs(results))
+
+	// 英文不区分大小写，片段会转义 HTML
+	results, _ = index.Search("COMPILED", Options{})
+	require.Equal(t, []uint{3}, resultIDs(results))
+	assert.Contains(t, results[0].Matches[0].Snippet, "&lt;<mark>compiled</mark>&gt;")
+
+	results, total = index.Search("不存在的内容", Options{})
+	assert.Empty(t, results)
+	assert.Zero(t, total)
+
+	// 按可见性过滤和分页
+	results, total = index.Search("古典", Options{Allow: func(ids []uint) map[uint]bool {
+		assert.ElementsMatch(t, []uint{1, 2}, ids)
+		return map[uint]bool{2: true}
+	}})
+	assert.Equal(t, 1, total)
+	assert.Equal(t, []uint{2}, resultIDs(results))
+	results, total = index.Search("古典", Options{Limit: 1, Offset: 1})
+	assert.Equal(t, 2, total)
+	assert.Len(t, results, 1)
+}
+
+func TestMemoryIndexUpdateAndPersist(t *testing.T) {
+	index := newTestIndex()
+
+	// 重新索引会替换旧内容
+	index.IndexBook(models.Book{ID: 2, Title: "西游记", Author: "吴承恩"}, []models.BookChapter{
+		{ID: 22, ChapterName: "第二回", ChapterContent: "悟彻菩提真妙理"},
+	}, "v2")
+	results, _ := index.Search("灵根", Options{})
+	assert.Empty(t, results)
+	results, _ = index.Search("菩提", Options{})
+	assert.Equal(t, []uint{2}, resultIDs(results))
+	fingerprint, ok := index.Fingerprint(2)
+	assert.True(t, ok)
+	assert.Equal(t, "v2", fingerprint)
+
+	index.RemoveBook(1)
+	results, _ = index.Search("林黛玉", Options{})
+	assert.Empty(t, results)
+	assert.Equal(t, []uint{2, 3}, index.BookIDs())
+
+	var buf bytes.Buffer
+	require.NoError(t, index.Save(&buf))
+	loaded := NewMemoryIndex(tokenizer.NewBigram())
+	require.NoError(t, loaded.Load(&buf))
+
+	assert.Equal(t, []uint{2, 3}, loaded.BookIDs())
+	results, _ = loaded.Search("菩提", Options{})
+	assert.Equal(t, []uint{2}, resultIDs(results))
+	results, _ = loaded.Search("language", Options{})
+	assert.Equal(t, []uint{3}, resultIDs(results))
+
+	// 加载后仍可以继续更新
+	loaded.RemoveBook(3)
+	results, _ = loaded.Search("language", Options{})
+	assert.Empty(t, results)
+}
+
+func TestSnippet(t *testing.T) {
+	text := "一二三四五六七八九十一二三四五六七八九十目标词一二三四五六七八九十"
+	snippet := Snippet(text, []span{{20, 22}, {21, 23}}, 10)
+	assert.Equal(t, "…九十<mark>目标词</mark>一二三四五…", snippet)
+
+	assert.Equal(t, "<mark>短</mark>文本", Snippet("短文本", []span{{0, 1}}, 10))
+}
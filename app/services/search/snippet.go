@@ -0,0 +1,103 @@
+package search
+
+import (
+	"html"
+	"sort"
+	"strings"
+)
+
+// 片段的长度（字符数）
+const snippetWidth = 80
+
+// span 是原文中的一段字符范围 [Start, End)
+type span struct {
+	Start int
+	End   int
+}
+
+// Snippet 从 text 中截取包含最多命中的一段，命中部分用 <mark> 标出，其余文字做 HTML 转义
+func Snippet(text string, spans []span, width int) string {
+	runes := []rune(text)
+	merged := mergeSpans(spans, len(runes))
+
+	start, end := 0, len(runes)
+	if len(runes) > width {
+		start = bestWindow(merged, width)
+		// 命中前保留一些上下文
+		start = max(0, start-width/4)
+		end = min(len(runes), start+width)
+		start = max(0, end-width)
+	}
+
+	var sb strings.Builder
+	if start > 0 {
+		sb.WriteString("…")
+	}
+	pos := start
+	for _, s := range merged {
+		if s.End <= start || s.Start >= end {
+			continue
+		}
+		s.Start, s.End = max(s.Start, start), min(s.End, end)
+		sb.WriteString(escapeSnippet(runes[pos:s.Start]))
+		sb.WriteString("<mark>")
+		sb.WriteString(escapeSnippet(runes[s.Start:s.End]))
+		sb.WriteString("</mark>")
+		pos = s.End
+	}
+	sb.WriteString(escapeSnippet(runes[pos:end]))
+	if end < len(runes) {
+		sb.WriteString("…")
+	}
+	return sb.String()
+}
+
+// mergeSpans 排序并合并重叠或相邻的范围（二元切分的命中彼此重叠）
+func mergeSpans(spans []span, limit int) []span {
+	sorted := make([]span, 0, len(spans))
+	for _, s := range spans {
+		if s.Start < limit {
+			sorted = append(sorted, span{s.Start, min(s.End, limit)})
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+
+	var merged []span
+	for _, s := range sorted {
+		if n := len(merged); n > 0 && s.Start <= merged[n-1].End {
+			merged[n-1].End = max(merged[n-1].End, s.End)
+		} else {
+			merged = append(merged, s)
+		}
+	}
+	return merged
+}
+
+// bestWindow 返回包含最多命中的长度为 width 的窗口起点
+func bestWindow(spans []span, width int) int {
+	best, bestCount := 0, 0
+	for i, s := range spans {
+		count := 0
+		for _, other := range spans[i:] {
+			if other.End > s.Start+width {
+				break
+			}
+			count++
+		}
+		if count > bestCount {
+			best, bestCount = s.Start, count
+		}
+	}
+	return best
+}
+
+// escapeSnippet 转义片段文字，并把换行等空白替换为空格
+func escapeSnippet(runes []rune) string {
+	text := strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' || r == '\t' {
+			return ' '
+		}
+		return r
+	}, string(runes))
+	return html.EscapeString(text)
+}
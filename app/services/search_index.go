@@ -0,0 +1,162 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/sd0ric4/book-reader-backend/app/models"
+	"github.com/sd0ric4/book-reader-backend/app/services/search"
+	"github.com/sd0ric4/book-reader-backend/app/services/tokenizer"
+	"gorm.io/gorm"
+)
+
+// SearchIndex 是全局的书籍全文索引，为空时不建立索引，检索接口不可用
+var SearchIndex search.Index
+
+var (
+	searchIndexPath string
+	// 保证同一时间只有一个写入者写索引文件
+	searchSaveMu sync.Mutex
+)
+
+// InitSearchIndex 创建全文索引：先从 path 加载已持久化的索引（path 为空时只保存在内存中），
+// 再与数据库同步，只重新索引发生变化的书籍
+func InitSearchIndex(db *gorm.DB, path string) error {
+	index := search.NewMemoryIndex(tokenizer.NewBigram())
+	if path != "" {
+		if file, err := os.Open(path); err == nil {
+			if err := index.Load(file); err != nil {
+				log.Printf("Ignoring search index file %s: %s", path, err)
+				index = search.NewMemoryIndex(tokenizer.NewBigram())
+			}
+			file.Close()
+		}
+	}
+
+	SearchIndex = index
+	searchIndexPath = path
+
+	updated, err := SyncSearchIndex(db, false)
+	if err != nil {
+		return err
+	}
+	log.Printf("Search index ready, %d books reindexed", updated)
+	return nil
+}
+
+// SyncSearchIndex 重新索引指纹发生变化（或 force 为 true 时全部）的书籍，删除已不存在的书籍，
+// 返回重新索引的书籍数
+func SyncSearchIndex(db *gorm.DB, force bool) (int, error) {
+	if SearchIndex == nil {
+		return 0, nil
+	}
+
+	books, err := models.GetBooks(db)
+	if err != nil {
+		return 0, err
+	}
+	stats, err := models.GetChapterStats(db)
+	if err != nil {
+		return 0, err
+	}
+
+	exists := make(map[uint]bool, len(books))
+	updated := 0
+	for _, book := range books {
+		exists[book.ID] = true
+		fingerprint := bookFingerprint(book, stats[book.ID])
+		if current, ok := SearchIndex.Fingerprint(book.ID); ok && current == fingerprint && !force {
+			continue
+		}
+
+		chapters, err := models.GetChaptersByBookID(db, book.ID)
+		if err != nil {
+			return updated, err
+		}
+		SearchIndex.IndexBook(book, chapters, fingerprint)
+		updated++
+	}
+
+	removed := 0
+	for _, id := range SearchIndex.BookIDs() {
+		if !exists[id] {
+			SearchIndex.RemoveBook(id)
+			removed++
+		}
+	}
+
+	if updated > 0 || removed > 0 {
+		return updated, SaveSearchIndex()
+	}
+	return updated, nil
+}
+
+// IndexBook 重新索引一本书并保存索引
+func IndexBook(db *gorm.DB, bookID uint) error {
+	if SearchIndex == nil {
+		return nil
+	}
+
+	book, err := models.GetBookByID(db, bookID)
+	if err != nil {
+		return err
+	}
+	chapters, err := models.GetChaptersByBookID(db, bookID)
+	if err != nil {
+		return err
+	}
+	stats, err := models.GetChapterStats(db.Where("book_id = ?", bookID))
+	if err != nil {
+		return err
+	}
+
+	SearchIndex.IndexBook(*book, chapters, bookFingerprint(*book, stats[bookID]))
+	return SaveSearchIndex()
+}
+
+// RemoveBookFromIndex 从索引中删除一本书并保存索引
+func RemoveBookFromIndex(bookID uint) error {
+	if SearchIndex == nil {
+		return nil
+	}
+	SearchIndex.RemoveBook(bookID)
+	return SaveSearchIndex()
+}
+
+// SaveSearchIndex 把索引写入配置的文件，先写临时文件再重命名，避免写到一半时进程退出损坏索引
+func SaveSearchIndex() error {
+	saver, ok := SearchIndex.(interface{ Save(w io.Writer) error })
+	if searchIndexPath == "" || !ok {
+		return nil
+	}
+
+	searchSaveMu.Lock()
+	defer searchSaveMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(searchIndexPath), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(searchIndexPath), filepath.Base(searchIndexPath)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := saver.Save(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), searchIndexPath)
+}
+
+// bookFingerprint 由书籍和章节的更新时间、章节数组成，任意一项变化都需要重新索引
+func bookFingerprint(book models.Book, stats models.ChapterStats) string {
+	return fmt.Sprintf("%d:%d:%s", book.UpdatedAt.UnixNano(), stats.Count, stats.LastUpdated)
+}
@@ -0,0 +1,280 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/sd0ric4/book-reader-backend/app/database"
+)
+
+var (
+	ErrSessionNotFound     = errors.New("session not found")
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+)
+
+// Session 表示一个登录设备上的会话
+type Session struct {
+	ID        string    `json:"id"`
+	UserID    uint      `json:"user_id"`
+	Device    string    `json:"device"`
+	IP        string    `json:"ip"`
+	CreatedAt time.Time `json:"created_at"`
+	LastSeen  time.Time `json:"last_seen"`
+	Current   bool      `json:"current"`
+}
+
+// TokenPair 是登录或刷新后返回给客户端的令牌
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+func sessionKey(sessionID string) string {
+	return "session:" + sessionID
+}
+
+func userSessionsKey(userID uint) string {
+	return fmt.Sprintf("user_sessions:%d", userID)
+}
+
+// newRefreshSecret 生成随机的刷新令牌密钥
+func newRefreshSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashRefreshSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// splitRefreshToken 将 "<sid>.<secret>" 格式的刷新令牌拆分
+func splitRefreshToken(refreshToken string) (string, string, error) {
+	sessionID, secret, ok := strings.Cut(refreshToken, ".")
+	if !ok || sessionID == "" || secret == "" {
+		return "", "", ErrInvalidRefreshToken
+	}
+	return sessionID, secret, nil
+}
+
+func issueTokenPair(userID uint, sessionID, secret string) (*TokenPair, error) {
+	accessToken, err := GenerateAccessToken(userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: sessionID + "." + secret,
+		ExpiresIn:    int64(AccessTokenTTL().Seconds()),
+	}, nil
+}
+
+// CreateSession 为用户创建新会话并签发访问令牌和刷新令牌
+func CreateSession(ctx context.Context, userID uint, device, ip string) (*TokenPair, error) {
+	sessionID := uuid.NewString()
+	secret, err := newRefreshSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	ttl := RefreshTokenTTL()
+	_, err = database.RedisDB.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, sessionKey(sessionID),
+			"user_id", userID,
+			"device", device,
+			"ip", ip,
+			"created_at", now,
+			"last_seen", now,
+			"refresh_hash", hashRefreshSecret(secret),
+		)
+		pipe.Expire(ctx, sessionKey(sessionID), ttl)
+		pipe.SAdd(ctx, userSessionsKey(userID), sessionID)
+		pipe.Expire(ctx, userSessionsKey(userID), ttl)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to store session: %v", err)
+	}
+
+	return issueTokenPair(userID, sessionID, secret)
+}
+
+// RefreshSession 校验刷新令牌并轮换出新的令牌对。
+// 已轮换掉的旧刷新令牌再次出现时视为被盗用，整个会话会被吊销。
+func RefreshSession(ctx context.Context, refreshToken, ip string) (*TokenPair, error) {
+	sessionID, secret, err := splitRefreshToken(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	newSecret, err := newRefreshSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	key := sessionKey(sessionID)
+	var userID uint
+	var reused bool
+	err = database.RedisDB.Watch(ctx, func(tx *redis.Tx) error {
+		fields, err := tx.HGetAll(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		if len(fields) == 0 {
+			return ErrSessionNotFound
+		}
+
+		hash := hashRefreshSecret(secret)
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(fields["refresh_hash"])) != 1 {
+			if subtle.ConstantTimeCompare([]byte(hash), []byte(fields["prev_refresh_hash"])) == 1 {
+				reused = true
+				return ErrRefreshTokenReused
+			}
+			return ErrInvalidRefreshToken
+		}
+
+		id, err := strconv.ParseUint(fields["user_id"], 10, 64)
+		if err != nil {
+			return err
+		}
+		userID = uint(id)
+
+		ttl := RefreshTokenTTL()
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, key,
+				"refresh_hash", hashRefreshSecret(newSecret),
+				"prev_refresh_hash", hash,
+				"ip", ip,
+				"last_seen", time.Now().Unix(),
+			)
+			pipe.Expire(ctx, key, ttl)
+			pipe.Expire(ctx, userSessionsKey(userID), ttl)
+			return nil
+		})
+		return err
+	}, key)
+
+	if reused {
+		if owner, err := database.RedisDB.HGet(ctx, key, "user_id").Result(); err == nil {
+			if id, err := strconv.ParseUint(owner, 10, 64); err == nil {
+				_ = RevokeSession(ctx, uint(id), sessionID)
+			}
+		}
+		return nil, ErrRefreshTokenReused
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return issueTokenPair(userID, sessionID, newSecret)
+}
+
+// 最后活跃时间的更新间隔，避免每个请求都写入 Redis
+const sessionTouchInterval = time.Minute
+
+// ValidateSession 检查会话是否仍然有效，并更新最后活跃时间和IP。
+// 最后活跃时间超过 sessionTouchInterval 或IP变化时才写入。
+func ValidateSession(ctx context.Context, userID uint, sessionID, ip string) error {
+	key := sessionKey(sessionID)
+	fields, err := database.RedisDB.HMGet(ctx, key, "user_id", "last_seen", "ip").Result()
+	if err != nil {
+		return err
+	}
+	owner, _ := fields[0].(string)
+	if owner == "" || owner != strconv.FormatUint(uint64(userID), 10) {
+		return ErrSessionNotFound
+	}
+
+	now := time.Now()
+	lastSeenValue, _ := fields[1].(string)
+	lastSeen, _ := strconv.ParseInt(lastSeenValue, 10, 64)
+	lastIP, _ := fields[2].(string)
+	if now.Sub(time.Unix(lastSeen, 0)) < sessionTouchInterval && lastIP == ip {
+		return nil
+	}
+	return database.RedisDB.HSet(ctx, key, "last_seen", now.Unix(), "ip", ip).Err()
+}
+
+// ListSessions 列出用户所有仍然有效的会话，按最后活跃时间倒序
+func ListSessions(ctx context.Context, userID uint) ([]Session, error) {
+	sessionIDs, err := database.RedisDB.SMembers(ctx, userSessionsKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]Session, 0, len(sessionIDs))
+	for _, sessionID := range sessionIDs {
+		fields, err := database.RedisDB.HGetAll(ctx, sessionKey(sessionID)).Result()
+		if err != nil {
+			return nil, err
+		}
+		// 会话已过期，顺便清理索引
+		if len(fields) == 0 {
+			database.RedisDB.SRem(ctx, userSessionsKey(userID), sessionID)
+			continue
+		}
+
+		createdAt, _ := strconv.ParseInt(fields["created_at"], 10, 64)
+		lastSeen, _ := strconv.ParseInt(fields["last_seen"], 10, 64)
+		sessions = append(sessions, Session{
+			ID:        sessionID,
+			UserID:    userID,
+			Device:    fields["device"],
+			IP:        fields["ip"],
+			CreatedAt: time.Unix(createdAt, 0),
+			LastSeen:  time.Unix(lastSeen, 0),
+		})
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeen.After(sessions[j].LastSeen)
+	})
+
+	return sessions, nil
+}
+
+// RevokeSession 吊销用户的某个会话，其访问令牌随即失效
+func RevokeSession(ctx context.Context, userID uint, sessionID string) error {
+	removed, err := database.RedisDB.SRem(ctx, userSessionsKey(userID), sessionID).Result()
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		return ErrSessionNotFound
+	}
+	return database.RedisDB.Del(ctx, sessionKey(sessionID)).Err()
+}
+
+// RevokeAllSessions 吊销用户在所有设备上的会话
+func RevokeAllSessions(ctx context.Context, userID uint) error {
+	sessionIDs, err := database.RedisDB.SMembers(ctx, userSessionsKey(userID)).Result()
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(sessionIDs)+1)
+	for _, sessionID := range sessionIDs {
+		keys = append(keys, sessionKey(sessionID))
+	}
+	keys = append(keys, userSessionsKey(userID))
+
+	return database.RedisDB.Del(ctx, keys...).Err()
+}
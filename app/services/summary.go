@@ -3,251 +3,299 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
-	"regexp"
 	"strings"
 
-	"github.com/gin-gonic/gin"
-	openai "github.com/sashabaranov/go-openai"
-	"github.com/sd0ric4/book-reader-backend/app/database"
+	"github.com/sd0ric4/book-reader-backend/app/config"
 	"github.com/sd0ric4/book-reader-backend/app/models"
+	"github.com/sd0ric4/book-reader-backend/app/services/llm"
+	"github.com/sd0ric4/book-reader-backend/app/services/prompts"
+	"gorm.io/gorm"
 )
 
-type AiConfig struct {
-	BaseURL   string
-	ModelName string
-	APIKey    string
-}
+// LLM 是生成摘要使用的大语言模型服务，未配置时为空
+var LLM llm.Provider
 
-var aiconfig = AiConfig{
-	BaseURL:   "http://172.16.26.101:13300/v1",
-	ModelName: "SparkDesk-v3.5",
-	APIKey:    "sk-cf3Qx1Rm3KnWq0kffEdPhbyNrF3xwYLXtQCycLPGgnzVgpZg", // 请替换为实际的 API Key
+// InitLLM 按配置选择大语言模型服务
+func InitLLM(cfg config.AIConfig) error {
+	provider, err := llm.New(cfg)
+	if err != nil {
+		return err
+	}
+	LLM = provider
+	return nil
 }
 
-var PROMPT_TEMPLATE = `
-请总结以下书籍的主要人物和主要内容概述，并以JSON格式输出：
-
-{
-    "title": "%s",
-    "author": "%s",
-    "characters": [
-        {
-            "name": "人物1",
-            "role": "描述其在书中的角色或作用。"
-        },
-        {
-            "name": "人物2",
-            "role": "描述其在书中的角色或作用。"
-        }
-        （根据书籍内容添加更多主要人物。）
-    ],
-    "synopsis": "请简要概述书籍的主要故事情节、主题和核心思想，控制在150字以内。"
-}
+// Prompts 是生成摘要使用的提示词模板
+var Prompts = prompts.Default()
 
-示例：
-{
-    "title": "红楼梦",
-    "author": "曹雪芹",
-    "characters": [
-        {
-            "name": "贾宝玉",
-            "role": "贾府的公子，具有叛逆性格，是书中主要叙事线的中心。"
-        },
-        {
-            "name": "林黛玉",
-            "role": "一位聪明且感性的女性，与贾宝玉有深厚感情。"
-        },
-        {
-            "name": "薛宝钗",
-            "role": "性格温和，与贾宝玉的关系构成三角恋。"
-        }
-    ],
-    "synopsis": "《红楼梦》以贾宝玉、林黛玉的爱情悲剧为线索，以贾、史、王、薛四大家族的兴衰为背景，着重叙述了贾家荣、宁两府逐渐衰败的过程。广泛地反映了当时的社会现象和各种矛盾，揭露了封建官僚地主家庭的荒淫腐败、虚伪欺诈及其各种罪恶活动，歌颂了贾宝玉、林黛玉的封建叛逆精神，描绘了一些纯洁少女的悲惨遭遇和反抗性格，对封建末期社会进行了剖析和批判，揭示了封建社会必然灭亡的历史趋势。"
+// InitPrompts 加载提示词模板，dir 中的模板覆盖同一语言的内置模板
+func InitPrompts(dir string) error {
+	registry, err := prompts.Load(dir)
+	if err != nil {
+		return err
+	}
+	Prompts = registry
+	return nil
 }
 
-现在请总结 %s 的《%s》：
-`
-
+// RequestData 是生成摘要的请求，BookID 不为0时按书籍生成，否则按书名和作者查找书籍。
+// Language 是摘要的语言，为空时使用书籍的语言。
 type RequestData struct {
+	BookID    uint   `json:"book_id"`
 	BookTitle string `json:"book_title"`
 	Author    string `json:"author"`
+	Language  string `json:"language"`
 }
 
-type ClientOption func(*openai.ClientConfig)
+// 判断书籍语言时最多查看的章节数
+const languageSampleChapters = 3
 
-func WithBaseURL(url string) ClientOption {
-	return func(config *openai.ClientConfig) {
-		config.BaseURL = url
+// SummaryLanguage 返回摘要使用的语言：优先使用请求的语言，其次是书籍的语言，
+// 都没有时根据书名和作者判断。没有对应语言的模板时使用默认语言。
+func SummaryLanguage(db *gorm.DB, book *models.Book, title, author, requested string) string {
+	language := prompts.Normalize(requested)
+	if language == "" && book != nil {
+		language = BookLanguage(db, book)
 	}
-}
-
-func NewOpenAIClient(apiKey string, options ...ClientOption) *openai.Client {
-	config := openai.DefaultConfig(apiKey)
-
-	for _, option := range options {
-		option(&config)
+	if language == "" {
+		language = prompts.Detect(title + " " + author)
 	}
-
-	return openai.NewClientWithConfig(config)
+	return Prompts.Get(language).Language
 }
 
-// 添加新的验证函数
-func isValidBookReview(data map[string]interface{}) bool {
-	// 检查必要字段是否存在且不为空
-	if title, ok := data["title"].(string); !ok || title == "" {
-		return false
-	}
-	if author, ok := data["author"].(string); !ok || author == "" {
-		return false
+// BookLanguage 返回书籍内容的语言：优先使用书籍信息中的语言，否则根据前几章的内容判断，无法判断时返回空
+func BookLanguage(db *gorm.DB, book *models.Book) string {
+	if language := prompts.Normalize(book.Language); language != "" {
+		return language
 	}
-	if synopsis, ok := data["synopsis"].(string); !ok || synopsis == "" {
-		return false
+	samples, err := models.GetChapterSamples(db, book.ID, languageSampleChapters)
+	if err == nil && len(samples) > 0 {
+		if language := prompts.Detect(strings.Join(samples, "\n")); language != "" {
+			return language
+		}
 	}
+	return prompts.Detect(book.Title + " " + book.Author)
+}
 
-	// 检查characters数组
-	characters, ok := data["characters"].([]interface{})
-	if !ok || len(characters) == 0 {
-		return false
-	}
+// promptVersion 返回保存在摘要中的提示词版本，如 book/zh-v1
+func promptVersion(kind string, set *prompts.Set) string {
+	return kind + "/" + set.VersionTag()
+}
 
-	// 检查每个character的完整性
-	for _, char := range characters {
-		character, ok := char.(map[string]interface{})
-		if !ok {
-			return false
+// reviewSchema 返回模型输出的摘要需要满足的结构，人物名不能是提示词中的占位符
+func reviewSchema() *llm.Schema {
+	placeholders, _ := json.Marshal(Prompts.Placeholders())
+	return llm.MustParseSchema(fmt.Sprintf(`{
+	"type": "object",
+	"required": ["title", "author", "characters", "synopsis"],
+	"properties": {
+		"title": {"type": "string", "minLength": 1},
+		"author": {"type": "string", "minLength": 1},
+		"synopsis": {"type": "string", "minLength": 1},
+		"characters": {
+			"type": "array",
+			"minItems": 1,
+			"items": {
+				"type": "object",
+				"required": ["name", "role"],
+				"properties": {
+					"name": {"type": "string", "minLength": 1, "not": {"enum": %s}},
+					"role": {"type": "string", "minLength": 1}
+				}
+			}
 		}
-		if name, ok := character["name"].(string); !ok || name == "" || name == "人物1" || name == "人物2" {
-			return false
+	}
+}`, placeholders))
+}
+
+// 每次生成摘要时最多向模型请求的次数
+const summaryMaxRetries = 3
+
+var ErrInvalidSummary = errors.New("unable to get a valid summary")
+
+// generateSummary 生成 language 语言的摘要但不保存，progress 和 emit 可以为空。
+// book 为空或没有章节内容时只根据书名和作者生成。
+func generateSummary(ctx context.Context, db *gorm.DB, provider llm.Provider, book *models.Book, title, author, language string, progress SummaryProgress, emit SummaryEmitter) (*BookSummary, error) {
+	output := Prompts.Get(language)
+	if book != nil {
+		s := &bookSummarizer{
+			db:       db,
+			provider: provider,
+			book:     book,
+			source:   Prompts.Get(BookLanguage(db, book)),
+			output:   output,
+			progress: progress,
+			emit:     emit,
 		}
-		if role, ok := character["role"].(string); !ok || role == "" {
-			return false
+		summary, err := s.summarize(ctx)
+		if !errors.Is(err, ErrNoChapterContent) {
+			return summary, err
 		}
+		title, author = book.Title, book.Author
 	}
 
-	return true
+	prompt, err := output.Render(prompts.TitleSummary, prompts.Data{Title: title, Author: author})
+	if err != nil {
+		return nil, err
+	}
+	review, err := generateReview(ctx, provider, output, prompt, title, author, 500, emit)
+	if err != nil {
+		return nil, err
+	}
+	return &BookSummary{
+		Review:        *review,
+		Language:      output.Language,
+		Model:         provider.Name(),
+		PromptVersion: promptVersion("title", output),
+	}, nil
 }
 
-// 修改 GetBookSummary 函数
-func GetBookSummary(c *gin.Context) {
-	var requestData RequestData
-	if err := c.ShouldBindJSON(&requestData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	// 先从数据库查询
-	db := database.MySQLDB
-	review, err := models.GetReviewByTitle(db, requestData.BookTitle)
-
-	// 如果数据库中存在且数据有效，直接返回
-	if err == nil && review != nil && len(review.ReviewData.Items) > 0 {
-		c.JSON(http.StatusOK, gin.H{"summary": review.ReviewData.Items[0]})
-		return
-	}
-
-	// 最大重试次数
-	maxRetries := 3
-	var jsonData map[string]interface{}
-
-	for i := 0; i < maxRetries; i++ {
-		// AI 调用逻辑
-		client := NewOpenAIClient(aiconfig.APIKey, WithBaseURL(aiconfig.BaseURL))
-		resp, err := client.CreateChatCompletion(
-			context.Background(),
-			openai.ChatCompletionRequest{
-				Model: aiconfig.ModelName,
-				Messages: []openai.ChatCompletionMessage{
-					{
-						Role:    openai.ChatMessageRoleUser,
-						Content: fmt.Sprintf(PROMPT_TEMPLATE, requestData.BookTitle, requestData.Author, requestData.Author, requestData.BookTitle),
-					},
-				},
-				MaxTokens:   500,
-				Temperature: 0.7,
-			},
-		)
-
+// generateReview 请求模型按提示词生成 JSON 格式的摘要。输出不符合要求时用 set 中的模板把具体问题反馈给模型，
+// 让它在下一轮对话中修正。emit 不为空时流式请求模型并发送生成过程中的事件。
+func generateReview(ctx context.Context, provider llm.Provider, set *prompts.Set, prompt, title, author string, maxTokens int, emit SummaryEmitter) (*models.BookReviewData, error) {
+	schema := reviewSchema()
+	req := llm.Request{
+		Messages:    []llm.Message{{Role: llm.RoleUser, Content: prompt}},
+		MaxTokens:   maxTokens,
+		Temperature: 0.7,
+	}
+	var lastErr error
+	problems := ""
+	for i := 0; i < summaryMaxRetries; i++ {
+		var content string
+		var err error
+		if emit == nil {
+			content, err = provider.Complete(ctx, req)
+		} else {
+			if i > 0 {
+				emit(SummaryEventRetry, map[string]interface{}{"attempt": i + 1, "problems": problems})
+			}
+			content, err = streamReview(ctx, provider, req, emit)
+		}
 		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			lastErr, problems = err, ""
 			continue
 		}
 
-		if len(resp.Choices) > 0 {
-			jsonData, err = extractJSON(resp.Choices[0].Message.Content)
-			if err != nil {
-				continue
-			}
-
-			// 验证数据完整性
-			if isValidBookReview(jsonData) {
-				characters := make([]models.Character, 0)
-				// 从 jsonData["characters"] 中提取数据并填充 characters 切片
-
-				for _, char := range jsonData["characters"].([]interface{}) {
-					character := char.(map[string]interface{})
-					characters = append(characters, models.Character{
-						Name: character["name"].(string),
-						Role: character["role"].(string),
-					})
-				}
-				reviewData := []models.BookReviewData{{
-					Title:      requestData.BookTitle,
-					Author:     requestData.Author,
-					Characters: characters,
-					Synopsis:   jsonData["synopsis"].(string),
-				}}
-
-				newReview := &models.Review{
-					BookID: 41,
-					UserID: 999,
-					ReviewData: models.BookReviewDataList{
-						Items: reviewData,
-					},
-				}
-
-				if err := models.CreateReview(db, newReview); err != nil {
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "保存到数据库失败"})
-					return
-				}
-
-				c.JSON(http.StatusOK, gin.H{"summary": jsonData})
-				return
-			}
+		review, err := parseReview(content, schema, title, author)
+		if err == nil {
+			return review, nil
 		}
+		lastErr, problems = err, describeProblems(err)
+		repair, err := set.Render(prompts.Repair, prompts.Data{Problems: problems})
+		if err != nil {
+			return nil, err
+		}
+		// 只保留最近一次的输出和问题，避免对话越来越长
+		req.Messages = append(req.Messages[:1],
+			llm.Message{Role: llm.RoleAssistant, Content: content},
+			llm.Message{Role: llm.RoleUser, Content: repair},
+		)
 	}
+	return nil, fmt.Errorf("%w: %v", ErrInvalidSummary, lastErr)
+}
 
-	c.JSON(http.StatusInternalServerError, gin.H{"error": "无法获取有效的书籍摘要"})
+// parseReview 按 schema 解析模型输出的摘要，书名和作者以书籍信息为准
+func parseReview(content string, schema *llm.Schema, title, author string) (*models.BookReviewData, error) {
+	var review models.BookReviewData
+	if err := llm.DecodeStructured(content, schema, &review); err != nil {
+		return nil, err
+	}
+	review.Title = title
+	review.Author = author
+	return &review, nil
 }
 
-func extractJSON(summary string) (map[string]interface{}, error) {
-	// First try to find JSON within code fences
-	re := regexp.MustCompile("(?s)```(?:json)?\\s*({[\\s\\S]*?})\\s*```")
-	matches := re.FindStringSubmatch(summary)
+// describeProblems 把解析错误逐条列出
+func describeProblems(err error) string {
+	var validation *llm.ValidationError
+	if !errors.As(err, &validation) {
+		return "- " + err.Error()
+	}
+	lines := make([]string, len(validation.Problems))
+	for i, problem := range validation.Problems {
+		lines[i] = "- " + problem
+	}
+	return strings.Join(lines, "\n")
+}
 
-	var jsonStr string
-	if len(matches) > 1 {
-		// Found JSON within code fences
-		jsonStr = matches[1]
+// GetSavedSummary 返回已保存的 language 语言的摘要：book 不为空时查找该书的摘要和章节概要，
+// 否则查找只根据书名和作者生成的摘要。没有摘要时返回 gorm.ErrRecordNotFound。
+func GetSavedSummary(db *gorm.DB, book *models.Book, title, author, language string) (*BookSummary, error) {
+	var review *models.Review
+	var err error
+	if book != nil {
+		review, err = models.GetBookReview(db, book.ID, language)
 	} else {
-		// Try to find raw JSON without code fences
-		re = regexp.MustCompile("(?s){[\\s\\S]*?}")
-		matches = re.FindStringSubmatch(summary)
-		if len(matches) > 0 {
-			jsonStr = matches[0]
-		} else {
-			return nil, fmt.Errorf("no JSON found in the response")
+		review, err = models.GetTitleReview(db, title, author, language)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(review.ReviewData.Items) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	summary := &BookSummary{
+		Review:        review.ReviewData.Items[0],
+		Language:      review.Language,
+		Model:         review.Model,
+		PromptVersion: review.PromptVersion,
+	}
+	if book != nil {
+		summary.Chapters, err = models.GetChapterSummaries(db, book.ID)
+		if err != nil {
+			return nil, err
 		}
 	}
+	return summary, nil
+}
 
-	// Clean up the JSON string
-	jsonStr = strings.TrimSpace(jsonStr)
+// SaveReview 保存生成的摘要，替换同一本书或同一书名和作者已保存的同一语言的摘要
+func SaveReview(db *gorm.DB, userID uint, book *models.Book, summary *BookSummary) error {
+	review := &models.Review{
+		UserID:        uint64(userID),
+		Title:         summary.Review.Title,
+		Author:        summary.Review.Author,
+		Language:      summary.Language,
+		Model:         summary.Model,
+		PromptVersion: summary.PromptVersion,
+		ReviewData: models.BookReviewDataList{
+			Items: []models.BookReviewData{summary.Review},
+		},
+	}
 
-	// Parse the JSON
-	var result map[string]interface{}
-	if err := json.Unmarshal([]byte(jsonStr), &result); err != nil {
-		return nil, fmt.Errorf("failed to parse JSON: %v", err)
+	var existing *models.Review
+	var err error
+	if book != nil {
+		bookID := uint64(book.ID)
+		review.BookID = &bookID
+		existing, err = models.GetBookReview(db, book.ID, review.Language)
+	} else {
+		existing, err = models.GetTitleReview(db, review.Title, review.Author, review.Language)
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.CreateReview(db, review)
 	}
+	if err != nil {
+		return err
+	}
+	review.ID = existing.ID
+	review.CreatedAt = existing.CreatedAt
+	return models.UpdateReview(db, review)
+}
 
-	return result, nil
+// InvalidateSummary 删除书籍已保存的摘要和章节概要，下次请求时重新生成
+func InvalidateSummary(db *gorm.DB, bookID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := models.DeleteBookReviews(tx, bookID); err != nil {
+			return err
+		}
+		return models.DeleteChapterSummaries(tx, bookID)
+	})
 }
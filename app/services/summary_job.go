@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/sd0ric4/book-reader-backend/app/database"
+	"github.com/sd0ric4/book-reader-backend/app/models"
+	"github.com/sd0ric4/book-reader-backend/app/services/jobs"
+	"gorm.io/gorm"
+)
+
+// JobTypeSummary 是生成书籍摘要的任务
+const JobTypeSummary = "summary"
+
+var ErrSummaryUnavailable = errors.New("summary service is not configured")
+
+// ErrSummaryBookUnavailable 表示任务执行时提交者已经看不到这本书（被删除或设为私有）
+var ErrSummaryBookUnavailable = errors.New("book is not available")
+
+// SummaryJobPayload 是摘要任务的参数，BookID 为0表示只根据书名和作者生成。
+// Language 是摘要的语言，为空时按 SummaryLanguage 确定。Regenerate 为 true 时忽略已保存的摘要，重新生成并替换。
+type SummaryJobPayload struct {
+	BookID     uint   `json:"book_id"`
+	BookTitle  string `json:"book_title"`
+	Author     string `json:"author"`
+	Language   string `json:"language,omitempty"`
+	Regenerate bool   `json:"regenerate,omitempty"`
+}
+
+// EnqueueSummary 提交摘要任务，同一用户对同一本书同一语言已有排队或执行中的任务时返回该任务。
+// 任务只能由提交者查看，因此不同用户的请求各自提交任务。
+func EnqueueSummary(ctx context.Context, userID uint, payload SummaryJobPayload) (*jobs.Job, error) {
+	if Jobs == nil {
+		return nil, ErrSummaryUnavailable
+	}
+	key := fmt.Sprintf("summary:user:%d:book:%d", userID, payload.BookID)
+	if payload.BookID == 0 {
+		key = fmt.Sprintf("summary:user:%d:title:%s\x00%s", userID, payload.BookTitle, payload.Author)
+	}
+	if payload.Language != "" {
+		key += ":" + payload.Language
+	}
+	if payload.Regenerate {
+		key += ":regenerate"
+	}
+	job, _, err := Jobs.Enqueue(ctx, JobTypeSummary, key, userID, payload)
+	return job, err
+}
+
+// runSummaryJob 生成并保存摘要。摘要已保存时直接返回，重复执行不会重复生成。
+// 书籍在提交后可能被设为私有，执行（包括重试）时按提交者重新检查可见性。
+func runSummaryJob(ctx context.Context, job *jobs.Job, progress jobs.ProgressFunc) (interface{}, error) {
+	var payload SummaryJobPayload
+	if err := job.DecodePayload(&payload); err != nil {
+		return nil, err
+	}
+
+	db := database.MySQLDB
+	var book *models.Book
+	if payload.BookID != 0 {
+		user, err := models.GetUserByID(db, job.UserID)
+		if err != nil {
+			return nil, err
+		}
+		book, err = models.GetBookByID(db.Scopes(models.VisibleTo(user)), payload.BookID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSummaryBookUnavailable
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	language := SummaryLanguage(db, book, payload.BookTitle, payload.Author, payload.Language)
+	if !payload.Regenerate {
+		if summary, err := GetSavedSummary(db, book, payload.BookTitle, payload.Author, language); err == nil {
+			return summary, nil
+		}
+	}
+
+	provider := LLM
+	if provider == nil {
+		return nil, ErrSummaryUnavailable
+	}
+
+	summary, err := generateSummary(ctx, db, provider, book, payload.BookTitle, payload.Author, language, func(done, total int) {
+		progress(done*90/total, fmt.Sprintf("Summarized %d of %d chapters", done, total))
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	progress(95, "Saving summary")
+	if err := SaveReview(db, job.UserID, book, summary); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
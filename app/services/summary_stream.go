@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/sd0ric4/book-reader-backend/app/models"
+	"github.com/sd0ric4/book-reader-backend/app/services/llm"
+	"gorm.io/gorm"
+)
+
+// 流式生成摘要时发送的事件
+const (
+	SummaryEventProgress  = "progress"  // 章节概括进度 {"done", "total"}
+	SummaryEventToken     = "token"     // 模型输出的片段 {"text"}
+	SummaryEventSynopsis  = "synopsis"  // 目前为止生成的内容概述 {"synopsis"}
+	SummaryEventCharacter = "character" // 解析出的一个人物 models.Character
+	SummaryEventRetry     = "retry"     // 输出无效，重新生成 {"attempt", "problems"}，之前的事件作废
+	SummaryEventSummary   = "summary"   // 校验通过的最终摘要 BookSummary
+	SummaryEventError     = "error"     // 生成失败 {"error"}
+)
+
+// SummaryEmitter 接收流式生成摘要时的事件
+type SummaryEmitter func(event string, data interface{})
+
+// StreamSummary 生成 language 语言的摘要并在生成过程中发送事件，不保存摘要。
+// book 为空或没有章节内容时只根据书名和作者生成。
+func StreamSummary(ctx context.Context, db *gorm.DB, provider llm.Provider, book *models.Book, title, author, language string, emit SummaryEmitter) (*BookSummary, error) {
+	progress := func(done, total int) {
+		emit(SummaryEventProgress, map[string]interface{}{"done": done, "total": total})
+	}
+	return generateSummary(ctx, db, provider, book, title, author, language, progress, emit)
+}
+
+// streamReview 流式请求模型，把输出片段以及从中解析出的内容概述和人物作为事件发送
+func streamReview(ctx context.Context, provider llm.Provider, req llm.Request, emit SummaryEmitter) (string, error) {
+	var text strings.Builder
+	synopsis := ""
+	characters := 0
+	return llm.Stream(ctx, provider, req, func(delta string) error {
+		text.WriteString(delta)
+		emit(SummaryEventToken, map[string]interface{}{"text": delta})
+
+		partial := scanPartialReview(text.String())
+		if partial.Synopsis != synopsis {
+			synopsis = partial.Synopsis
+			emit(SummaryEventSynopsis, map[string]interface{}{"synopsis": synopsis})
+		}
+		for ; characters < len(partial.Characters); characters++ {
+			emit(SummaryEventCharacter, partial.Characters[characters])
+		}
+		return ctx.Err()
+	})
+}
+
+// partialReview 是从尚未生成完的 JSON 中解析出的内容
+type partialReview struct {
+	Synopsis   string
+	Characters []models.Character
+}
+
+// jsonFrame 是解析时所在的一层对象或数组
+type jsonFrame struct {
+	object    bool
+	key       string // 对象中当前的键
+	expectKey bool   // 对象中下一个字符串是否为键
+	parentKey string // 这一层在上一层中的键
+	start     int
+}
+
+// scanPartialReview 解析模型目前为止的输出：返回顶层 synopsis 已生成的部分，
+// 以及 characters 数组中已经完整生成的人物。输出不完整或格式有误时尽量解析。
+func scanPartialReview(text string) partialReview {
+	var result partialReview
+	begin := strings.IndexByte(text, '{')
+	if begin < 0 {
+		return result
+	}
+
+	var stack []*jsonFrame
+	for i := begin; i < len(text); i++ {
+		var top *jsonFrame
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+		switch c := text[i]; c {
+		case '"':
+			end, closed := scanJSONString(text, i)
+			if top != nil && top.object {
+				value := decodePartialString(text[i+1 : end])
+				if top.expectKey {
+					if closed {
+						top.key = value
+					}
+				} else if len(stack) == 1 && top.key == "synopsis" {
+					result.Synopsis = value
+				}
+			}
+			if !closed {
+				return result
+			}
+			i = end
+		case '{', '[':
+			frame := &jsonFrame{object: c == '{', expectKey: c == '{', start: i}
+			if top != nil && top.object {
+				frame.parentKey = top.key
+			} else if top != nil {
+				frame.parentKey = top.parentKey
+			}
+			stack = append(stack, frame)
+		case '}', ']':
+			if top == nil {
+				return result
+			}
+			stack = stack[:len(stack)-1]
+			// 顶层对象 > characters 数组 > 人物对象
+			if c == '}' && len(stack) == 2 && !stack[1].object && stack[1].parentKey == "characters" {
+				var character models.Character
+				if err := json.Unmarshal([]byte(text[top.start:i+1]), &character); err == nil && character.Name != "" {
+					result.Characters = append(result.Characters, character)
+				}
+			}
+			if len(stack) == 0 {
+				return result
+			}
+		case ':':
+			if top != nil && top.object {
+				top.expectKey = false
+			}
+		case ',':
+			if top != nil && top.object {
+				top.expectKey = true
+			}
+		}
+	}
+	return result
+}
+
+// scanJSONString 返回从 start 处的引号开始的字符串的结束位置，字符串未结束时返回文本末尾
+func scanJSONString(text string, start int) (end int, closed bool) {
+	for i := start + 1; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '"':
+			return i, true
+		}
+	}
+	return len(text), false
+}
+
+// decodePartialString 解码 JSON 字符串的内容，去掉末尾不完整的转义序列
+func decodePartialString(raw string) string {
+	var value string
+	if err := json.Unmarshal([]byte(`"`+raw+`"`), &value); err == nil {
+		return value
+	}
+	if i := strings.LastIndexByte(raw, '\\'); i >= 0 {
+		if err := json.Unmarshal([]byte(`"`+raw[:i]+`"`), &value); err == nil {
+			return value
+		}
+	}
+	return raw
+}
@@ -0,0 +1,34 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/sd0ric4/book-reader-backend/app/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanPartialReview(t *testing.T) {
+	text := "```json\n" + `{
+    "title": "三体",
+    "characters": [
+        {"name": "叶文洁", "role": "天体物理学家，{向三体文明}发出信号。"},
+        {"name": "汪淼", "role": "纳米`
+
+	partial := scanPartialReview(text)
+	assert.Equal(t, []models.Character{{Name: "叶文洁", Role: "天体物理学家，{向三体文明}发出信号。"}}, partial.Characters)
+	assert.Empty(t, partial.Synopsis)
+
+	text += `材料专家。"}
+    ],
+    "synopsis": "人类文明与\"三体\`
+	partial = scanPartialReview(text)
+	assert.Len(t, partial.Characters, 2)
+	assert.Equal(t, "人类文明与\"三体", partial.Synopsis)
+
+	text += `"文明的第一次接触。", "extra": {"synopsis": "嵌套"}}`
+	partial = scanPartialReview(text)
+	assert.Equal(t, "人类文明与\"三体\"文明的第一次接触。", partial.Synopsis)
+	assert.Equal(t, "汪淼", partial.Characters[1].Name)
+
+	assert.Empty(t, scanPartialReview("还没有开始输出 JSON").Characters)
+}
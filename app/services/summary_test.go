@@ -0,0 +1,46 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sd0ric4/book-reader-backend/app/services/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateReviewRepair(t *testing.T) {
+	replies := []string{
+		// 嵌套对象、尾逗号，但人物名是占位符且缺少 synopsis
+		"```json\n" + `{"title": "三体", "author": "刘慈欣", "characters": [{"name": "人物1", "role": "主角",},],}` + "\n```",
+		`好的，修正后的结果：{"title": "三体", "author": "刘慈欣", "characters": [{"name": "叶文洁", "role": "天体物理学家"}], "synopsis": "人类与三体文明的接触。"}`,
+	}
+	fake := llm.NewFake(func(req llm.Request) (string, error) {
+		reply := replies[0]
+		replies = replies[1:]
+		return reply, nil
+	})
+
+	review, err := generateReview(context.Background(), fake, Prompts.Get("zh"), "总结《三体》", "三体", "Author", 500, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Author", review.Author)
+	assert.Equal(t, "叶文洁", review.Characters[0].Name)
+
+	// 第二次请求带上上一次的输出和具体问题，而不是重复原来的提示词
+	requests := fake.Requests()
+	require.Len(t, requests, 2)
+	messages := requests[1].Messages
+	require.Len(t, messages, 3)
+	assert.Equal(t, "总结《三体》", messages[0].Content)
+	assert.Equal(t, llm.RoleAssistant, messages[1].Role)
+	assert.Contains(t, messages[2].Content, "你的输出不符合要求")
+	assert.Contains(t, messages[2].Content, "- $.synopsis: is required")
+	assert.Contains(t, messages[2].Content, "- $.characters[0].name: value 人物1 is not allowed")
+
+	// 多次修正仍然无效时返回最后一次的问题
+	fake.Reply = func(req llm.Request) (string, error) { return "无法总结", nil }
+	_, err = generateReview(context.Background(), fake, Prompts.Get("zh"), "总结《三体》", "三体", "Author", 500, nil)
+	assert.ErrorIs(t, err, ErrInvalidSummary)
+	assert.ErrorContains(t, err, "no JSON object found")
+	assert.Len(t, fake.Requests(), 2+summaryMaxRetries)
+}
@@ -2,20 +2,31 @@ package services
 
 import (
 	"math"
-	"strings"
 
+	"github.com/sd0ric4/book-reader-backend/app/services/tokenizer"
 	"gonum.org/v1/gonum/mat"
 )
 
-// ComputeTFIDF 计算TF-IDF矩阵
+// TFIDFTokenizer 是计算TF-IDF时使用的分词器，默认二元切分并过滤中英文停用词，
+// 可通过配置 recommend.tokenizer 改为按词典分词，见 InitRecommendTokenizer
+var TFIDFTokenizer tokenizer.Tokenizer = tokenizer.NewBigramWithStopWords(tokenizer.DefaultStopWords)
+
+// ComputeTFIDF 使用 TFIDFTokenizer 计算TF-IDF矩阵
 func ComputeTFIDF(documents []string) *mat.Dense {
+	return ComputeTFIDFWith(TFIDFTokenizer, documents)
+}
+
+// ComputeTFIDFWith 使用指定的分词器计算TF-IDF矩阵
+func ComputeTFIDFWith(t tokenizer.Tokenizer, documents []string) *mat.Dense {
 	// 词频计算
 	wordFreq := make(map[string][]int)
 	totalDocs := len(documents)
 
 	// 统计单词在文档中出现的频率
+	docLengths := make([]int, totalDocs)
 	for docIndex, doc := range documents {
-		words := strings.Fields(doc)
+		words := tokenizer.Terms(t, doc)
+		docLengths[docIndex] = len(words)
 		for _, word := range words {
 			if wordFreq[word] == nil {
 				wordFreq[word] = make([]int, totalDocs)
@@ -26,7 +37,8 @@ func ComputeTFIDF(documents []string) *mat.Dense {
 
 	// 创建TF-IDF矩阵
 	rows := len(documents)
-	cols := len(wordFreq)
+	// 没有任何词时 gonum 无法创建零列矩阵，保留一列全零
+	cols := max(len(wordFreq), 1)
 	tfidfMatrix := mat.NewDense(rows, cols, nil)
 
 	wordList := make([]string, 0, len(wordFreq))
@@ -42,11 +54,15 @@ func ComputeTFIDF(documents []string) *mat.Dense {
 				docsWithWord++
 			}
 		}
-		idf := math.Log(float64(totalDocs) / (1 + float64(docsWithWord)))
+		// 平滑的IDF，出现在所有文档中的词（如常见标签）权重为1而不是0或负数
+		idf := math.Log(float64(1+totalDocs)/float64(1+docsWithWord)) + 1
 
 		for i := 0; i < rows; i++ {
+			if docLengths[i] == 0 {
+				continue
+			}
 			// 计算词频
-			tf := float64(wordFreq[word][i]) / float64(len(strings.Fields(documents[i])))
+			tf := float64(wordFreq[word][i]) / float64(docLengths[i])
 			tfidfMatrix.Set(i, j, tf*idf)
 		}
 	}
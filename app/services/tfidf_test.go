@@ -0,0 +1,34 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestComputeTFIDFChinese(t *testing.T) {
+	documents := []string{
+		"科幻 太空 三体文明的宇宙社会学",
+		"科幻 太空 银河帝国的兴衰",
+		"言情 都市 一个关于爱情的故事",
+		"科幻 三体 宇宙",
+	}
+	matrix := ComputeTFIDF(documents)
+
+	row := func(i int) *mat.VecDense {
+		return mat.NewVecDense(matrix.RawMatrix().Cols, matrix.RawRowView(i))
+	}
+	query := row(3)
+	// 没有空格的中文也能切出共同的词，相近题材的相似度更高
+	assert.Greater(t, CosineSimilarity(query, row(0)), CosineSimilarity(query, row(1)))
+	assert.Greater(t, CosineSimilarity(query, row(1)), CosineSimilarity(query, row(2)))
+}
+
+func TestComputeTFIDFEmptyDocument(t *testing.T) {
+	matrix := ComputeTFIDF([]string{"的 了 the", ""})
+	rows, cols := matrix.Dims()
+	assert.Equal(t, 2, rows)
+	assert.Equal(t, 1, cols)
+	assert.Zero(t, matrix.At(0, 0))
+}
@@ -0,0 +1,83 @@
+package tokenizer
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Dictionary 按词典对中日韩文字做正向最大匹配分词，
+// 词典中找不到的片段退回二元切分，其他文字的处理与 Bigram 相同
+type Dictionary struct {
+	StopWords StopWords
+	words     map[string]struct{}
+	maxLen    int
+}
+
+// NewDictionary 由词列表创建词典分词器
+func NewDictionary(words []string, stopWords StopWords) *Dictionary {
+	d := &Dictionary{StopWords: stopWords, words: make(map[string]struct{}, len(words))}
+	for _, word := range words {
+		d.add(word)
+	}
+	return d
+}
+
+// LoadDictionary 读取词典文件，每行第一列为词（兼容 jieba 的 “词 词频 词性” 格式）
+func LoadDictionary(r io.Reader, stopWords StopWords) (*Dictionary, error) {
+	d := &Dictionary{StopWords: stopWords, words: map[string]struct{}{}}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) > 0 {
+			d.add(fields[0])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *Dictionary) add(word string) {
+	n := utf8.RuneCountInString(word)
+	if n < 2 {
+		return
+	}
+	d.words[word] = struct{}{}
+	d.maxLen = max(d.maxLen, n)
+}
+
+func (d *Dictionary) Tokenize(text string) []Token {
+	return segment(text, d.StopWords, d.match)
+}
+
+// match 对 runes[start:end] 做正向最大匹配，未登录的连续字做二元切分
+func (d *Dictionary) match(runes []rune, start, end int) []Token {
+	var tokens []Token
+	unknown := start
+	flush := func(k int) {
+		if k > unknown {
+			tokens = append(tokens, bigrams(runes, unknown, k)...)
+		}
+	}
+
+	for k := start; k < end; {
+		n := min(d.maxLen, end-k)
+		for ; n >= 2; n-- {
+			if _, ok := d.words[string(runes[k:k+n])]; ok {
+				break
+			}
+		}
+		if n < 2 {
+			k++
+			continue
+		}
+		flush(k)
+		tokens = append(tokens, Token{Term: string(runes[k : k+n]), Start: k, End: k + n})
+		k += n
+		unknown = k
+	}
+	flush(end)
+	return tokens
+}
@@ -0,0 +1,69 @@
+package tokenizer
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// StopWords 是停用词表，词项统一为小写
+type StopWords map[string]struct{}
+
+// NewStopWords 由词列表创建停用词表
+func NewStopWords(words ...string) StopWords {
+	stopWords := make(StopWords, len(words))
+	for _, word := range words {
+		stopWords[strings.ToLower(word)] = struct{}{}
+	}
+	return stopWords
+}
+
+// LoadStopWords 读取每行一个词的停用词文件，忽略空行和 # 开头的注释
+func LoadStopWords(r io.Reader) (StopWords, error) {
+	stopWords := StopWords{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		word := strings.TrimSpace(scanner.Text())
+		if word == "" || strings.HasPrefix(word, "#") {
+			continue
+		}
+		stopWords[strings.ToLower(word)] = struct{}{}
+	}
+	return stopWords, scanner.Err()
+}
+
+// Contains 判断词项是否为停用词，停用词表为空时总是返回 false
+func (s StopWords) Contains(term string) bool {
+	_, ok := s[term]
+	return ok
+}
+
+// Merge 返回合并后的停用词表
+func (s StopWords) Merge(others ...StopWords) StopWords {
+	merged := make(StopWords, len(s))
+	for _, stopWords := range append([]StopWords{s}, others...) {
+		for word := range stopWords {
+			merged[word] = struct{}{}
+		}
+	}
+	return merged
+}
+
+// ChineseStopWords 是常用的中文停用词，单字停用词在二元切分时作为分隔符
+var ChineseStopWords = NewStopWords(
+	"的", "了", "和", "与", "及", "或", "是", "在", "也", "都", "就", "而", "又", "被", "把", "让",
+	"之", "其", "这", "那", "着", "过", "吗", "呢", "吧", "啊", "一个", "一种", "我们", "你们",
+	"他们", "她们", "它们", "这个", "那个", "这些", "那些", "什么", "怎么", "因为", "所以", "但是",
+	"如果", "以及", "并且", "或者", "还是", "就是", "可以", "没有", "自己", "本书", "作者",
+)
+
+// EnglishStopWords 是常用的英文停用词
+var EnglishStopWords = NewStopWords(
+	"a", "an", "the", "and", "or", "but", "of", "to", "in", "on", "at", "for", "with", "by",
+	"from", "as", "is", "are", "was", "were", "be", "been", "it", "its", "this", "that",
+	"these", "those", "he", "she", "they", "we", "you", "his", "her", "their", "our", "not",
+	"no", "so", "if", "then", "than", "into", "about", "book",
+)
+
+// DefaultStopWords 是中英文停用词的合集
+var DefaultStopWords = ChineseStopWords.Merge(EnglishStopWords)
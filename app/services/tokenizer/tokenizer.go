@@ -0,0 +1,112 @@
+// Package tokenizer 提供检索和推荐共用的分词器。
+// 目录中的书籍以中文为主，按空白切词没有意义，因此对中日韩文字使用二元切分（bigram）。
+package tokenizer
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Token 是一个词项，Start/End 为它在原文中的字符（rune）偏移
+type Token struct {
+	Term  string
+	Start int
+	End   int
+}
+
+// Tokenizer 把文本切分为词项
+type Tokenizer interface {
+	Tokenize(text string) []Token
+}
+
+// Bigram 对连续的中日韩文字做重叠的二元切分（单个字保留为一元），
+// 其他字母和数字按连续片段切词并转为小写，标点和空白作为分隔符。
+// StopWords 不为空时过滤停用词，单字停用词同时作为中文片段的分隔符。
+type Bigram struct {
+	StopWords StopWords
+}
+
+// NewBigram 创建二元分词器
+func NewBigram() *Bigram {
+	return &Bigram{}
+}
+
+// NewBigramWithStopWords 创建过滤停用词的二元分词器
+func NewBigramWithStopWords(stopWords StopWords) *Bigram {
+	return &Bigram{StopWords: stopWords}
+}
+
+func (b *Bigram) Tokenize(text string) []Token {
+	return segment(text, b.StopWords, bigrams)
+}
+
+// segment 把文本切分为中日韩文字片段和字母数字片段，中文片段交给 cjk 切分
+func segment(text string, stopWords StopWords, cjk func(runes []rune, start, end int) []Token) []Token {
+	var tokens []Token
+	runes := []rune(text)
+
+	for i := 0; i < len(runes); {
+		switch {
+		case IsCJK(runes[i]):
+			j := i
+			for j < len(runes) && IsCJK(runes[j]) && !stopWords.Contains(string(runes[j])) {
+				j++
+			}
+			if j == i {
+				// 单字停用词
+				i++
+				continue
+			}
+			for _, token := range cjk(runes, i, j) {
+				if !stopWords.Contains(token.Term) {
+					tokens = append(tokens, token)
+				}
+			}
+			i = j
+		case isWordRune(runes[i]):
+			j := i
+			for j < len(runes) && isWordRune(runes[j]) && !IsCJK(runes[j]) {
+				j++
+			}
+			term := strings.ToLower(string(runes[i:j]))
+			if !stopWords.Contains(term) {
+				tokens = append(tokens, Token{Term: term, Start: i, End: j})
+			}
+			i = j
+		default:
+			i++
+		}
+	}
+	return tokens
+}
+
+// bigrams 对 runes[start:end] 做重叠的二元切分，只有一个字时保留为一元
+func bigrams(runes []rune, start, end int) []Token {
+	if end-start == 1 {
+		return []Token{{Term: string(runes[start]), Start: start, End: end}}
+	}
+	tokens := make([]Token, 0, end-start-1)
+	for k := start; k+1 < end; k++ {
+		tokens = append(tokens, Token{Term: string(runes[k : k+2]), Start: k, End: k + 2})
+	}
+	return tokens
+}
+
+// Terms 返回文本切分后的词项
+func Terms(t Tokenizer, text string) []string {
+	tokens := t.Tokenize(text)
+	terms := make([]string, len(tokens))
+	for i, token := range tokens {
+		terms[i] = token.Term
+	}
+	return terms
+}
+
+// IsCJK 判断字符是否为中日韩文字
+func IsCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,79 @@
+package tokenizer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBigramTokenize(t *testing.T) {
+	testCases := []struct {
+		name   string
+		text   string
+		expect []Token
+	}{
+		{
+			name: "Chinese bigrams",
+			text: "红楼梦",
+			expect: []Token{
+				{Term: "红楼", Start: 0, End: 2},
+				{Term: "楼梦", Start: 1, End: 3},
+			},
+		},
+		{
+			name: "Single character",
+			text: "书",
+			expect: []Token{
+				{Term: "书", Start: 0, End: 1},
+			},
+		},
+		{
+			name: "Mixed text",
+			text: "Go语言, 第2版",
+			expect: []Token{
+				{Term: "go", Start: 0, End: 2},
+				{Term: "语言", Start: 2, End: 4},
+				{Term: "第", Start: 6, End: 7},
+				{Term: "2", Start: 7, End: 8},
+				{Term: "版", Start: 8, End: 9},
+			},
+		},
+		{
+			name:   "Punctuation only",
+			text:   "，。 ！",
+			expect: nil,
+		},
+	}
+
+	tokenizer := NewBigram()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expect, tokenizer.Tokenize(tc.text))
+		})
+	}
+}
+
+func TestStopWords(t *testing.T) {
+	tokenizer := NewBigramWithStopWords(DefaultStopWords)
+	// 单字停用词把中文片段切开，多字停用词整体过滤
+	assert.Equal(t, []string{"三体", "科幻", "幻小", "小说"}, Terms(tokenizer, "三体是我们的科幻小说"))
+	assert.Equal(t, []string{"history", "rome"}, Terms(tokenizer, "The History of Rome"))
+
+	stopWords, err := LoadStopWords(strings.NewReader("# 注释\n小说\n\nRome\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"科幻", "幻小", "the", "history", "of"}, Terms(NewBigramWithStopWords(stopWords), "科幻小说 The History of Rome"))
+}
+
+func TestDictionaryTokenize(t *testing.T) {
+	dictionary, err := LoadDictionary(strings.NewReader("科幻小说 100 n\n小说 80 n\n银河帝国 10 nz\n"), DefaultStopWords)
+	assert.NoError(t, err)
+
+	// 词典中的词优先取最长匹配，未登录的片段退回二元切分
+	assert.Equal(t, []Token{
+		{Term: "科幻小说", Start: 0, End: 4},
+		{Term: "银河帝国", Start: 5, End: 9},
+		{Term: "兴衰", Start: 10, End: 12},
+	}, dictionary.Tokenize("科幻小说《银河帝国的兴衰》"))
+	assert.Equal(t, []string{"小说", "三体", "体文", "文明"}, Terms(dictionary, "小说三体文明"))
+}
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"math"
+
+	"github.com/sd0ric4/book-reader-backend/app/models"
+	"github.com/sd0ric4/book-reader-backend/app/services/recommend"
+	"gorm.io/gorm"
+)
+
+// 用户行为对兴趣画像的权重
+const (
+	// 开始阅读的书籍权重，读完时再加上 progressCompleteWeight
+	progressStartWeight    = 0.5
+	progressCompleteWeight = 0.5
+	favoriteWeight         = 1.0
+	// 评分按 (rating-3)/2 映射到 [-1, 1]，低分书籍会降低相似书籍的得分
+	ratingWeight = 1.0
+)
+
+// Recommendation 是一条推荐结果
+type Recommendation struct {
+	Book        models.Book `json:"book"`
+	Score       float64     `json:"score"`
+	Explanation Explanation `json:"explanation"`
+}
+
+// RecommendForUser 根据用户的阅读进度、收藏和评分推荐书籍。
+// 内容信号是用户画像与书籍标签和简介的TF-IDF余弦相似度，协同过滤信号来自其他读者的行为，
+// 流行度信号来自收藏和评分，三者按配置的权重混合；没有任何行为的新用户只按流行度推荐。
+// 用户读过、收藏过或评过分的书不会被推荐，有得分的书不足时用其他可见的书补足。
+func RecommendForUser(db *gorm.DB, user *models.User, limit int) ([]Recommendation, error) {
+	index, err := loadRecommendIndex(db)
+	if err != nil {
+		return nil, err
+	}
+	weights, err := userBookWeights(db, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	popularity, err := bookPopularity(db)
+	if err != nil {
+		return nil, err
+	}
+	collaborative, err := loadCollaborative(db)
+	if err != nil {
+		return nil, err
+	}
+
+	// 用户画像是交互过的书籍（归一化后）向量的加权和
+	profile := recommend.Vector{}
+	vectors := make(map[uint]recommend.Vector, len(weights))
+	for bookID, weight := range weights {
+		if vector, ok := index.Vector(bookID); ok && weight != 0 {
+			profile.AddScaled(weight, vector)
+			vectors[bookID] = vector
+		}
+	}
+
+	// 可见性只在排序时对得分最高的候选检查
+	allow := func(bookID uint) bool {
+		_, ok := weights[bookID]
+		return !ok
+	}
+	content := index.Similar(profile, allow)
+	neighbors := collaborative.Recommend(weights, allow)
+
+	blend := recommendWeights()
+	scores := make(map[uint]float64, len(content)+len(neighbors)+len(popularity))
+	for id, similarity := range content {
+		scores[id] += blend.Content * similarity
+	}
+	for id, similarity := range neighbors {
+		scores[id] += blend.Collaborative * similarity
+	}
+	for id, value := range popularity {
+		if allow(id) {
+			scores[id] += blend.Popularity * value
+		}
+	}
+
+	recommendations, err := rankRecommendations(db, user, scores, limit)
+	if err != nil {
+		return nil, err
+	}
+	recommendations, err = fillRecommendations(db, user, recommendations, weights, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range recommendations {
+		bookID := recommendations[i].Book.ID
+		recommendations[i].Explanation = Explanation{
+			Terms:          explainTerms(index, profile, bookID),
+			BecauseYouRead: becauseYouRead(index, collaborative, weights, vectors, bookID),
+		}
+	}
+	if err := fillBookTitles(db, user, recommendations); err != nil {
+		return nil, err
+	}
+	for i := range recommendations {
+		bookID := recommendations[i].Book.ID
+		others := blend.Collaborative*neighbors[bookID] + blend.Popularity*popularity[bookID]
+		recommendations[i].Explanation.Reason = explanationReason(recommendations[i].Explanation, others)
+	}
+	return recommendations, nil
+}
+
+// userBookWeights 汇总用户对每本书的兴趣权重，包含所有交互过的书籍（权重可能为0或负数）
+func userBookWeights(db *gorm.DB, userID uint) (map[uint]float64, error) {
+	progresses, err := models.GetUserReadingProgress(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	favoriteIDs, err := models.GetFavoriteBookIDs(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	favorites := make([]models.FavoriteBook, len(favoriteIDs))
+	for i, bookID := range favoriteIDs {
+		favorites[i] = models.FavoriteBook{UserID: userID, BookID: bookID}
+	}
+	ratings, err := models.GetUserRatings(db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	weights := interactionWeights(progresses, favorites, ratings)[userID]
+	if weights == nil {
+		weights = make(map[uint]float64)
+	}
+	return weights, nil
+}
+
+// interactionWeights 把阅读进度、收藏和评分汇总为 用户 -> 书籍 -> 兴趣权重
+func interactionWeights(progresses []models.ReadingProgress, favorites []models.FavoriteBook, ratings []models.BookRating) map[uint]map[uint]float64 {
+	weights := make(map[uint]map[uint]float64)
+	add := func(userID, bookID uint, weight float64) {
+		if weights[userID] == nil {
+			weights[userID] = make(map[uint]float64)
+		}
+		weights[userID][bookID] += weight
+	}
+
+	for _, progress := range progresses {
+		add(progress.UserID, progress.BookID, progressStartWeight+progressCompleteWeight*math.Min(progress.Percentage, 100)/100)
+	}
+	for _, favorite := range favorites {
+		add(favorite.UserID, favorite.BookID, favoriteWeight)
+	}
+	for _, rating := range ratings {
+		add(rating.UserID, rating.BookID, ratingWeight*float64(rating.Rating-3)/2)
+	}
+	return weights
+}
+
+// SimilarBooks 返回与一本书相似的书籍：“读过这本书的人也读过”的协同过滤相似度，
+// 与标签和简介的内容相似度按配置的权重混合，没有共同读者时只按内容相似度
+func SimilarBooks(db *gorm.DB, user *models.User, book *models.Book, limit int) ([]Recommendation, error) {
+	index, err := loadRecommendIndex(db)
+	if err != nil {
+		return nil, err
+	}
+	collaborative, err := loadCollaborative(db)
+	if err != nil {
+		return nil, err
+	}
+
+	allow := func(bookID uint) bool { return bookID != book.ID }
+
+	vector, _ := index.Vector(book.ID)
+	content := index.Similar(vector, allow)
+	neighbors := collaborative.Similar(book.ID, allow)
+
+	blend := recommendWeights()
+	scores := make(map[uint]float64, len(content)+len(neighbors))
+	for id, similarity := range content {
+		scores[id] += blend.Content * similarity
+	}
+	for id, similarity := range neighbors {
+		scores[id] += blend.Collaborative * similarity
+	}
+
+	recommendations, err := rankRecommendations(db, user, scores, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range recommendations {
+		bookID := recommendations[i].Book.ID
+		terms := explainTerms(index, vector, bookID)
+		recommendations[i].Explanation = Explanation{
+			Reason: similarReason(book, neighbors[bookID], terms),
+			Terms:  terms,
+		}
+	}
+	return recommendations, nil
+}
+
+// bookPopularity 返回每本书归一化到 [0, 1] 的流行度：收藏数加上按平均分折算的评分数，取对数压缩
+func bookPopularity(db *gorm.DB) (map[uint]float64, error) {
+	raw := make(map[uint]float64)
+	favorites, err := models.GetFavoriteCounts(db)
+	if err != nil {
+		return nil, err
+	}
+	for _, favorite := range favorites {
+		raw[favorite.BookID] += float64(favorite.Count)
+	}
+	ratings, err := models.GetAllBookRatingStats(db)
+	if err != nil {
+		return nil, err
+	}
+	for _, stats := range ratings {
+		raw[stats.BookID] += float64(stats.Count) * stats.Average / models.MaxBookRating
+	}
+
+	var maxRaw float64
+	for _, value := range raw {
+		maxRaw = math.Max(maxRaw, value)
+	}
+	popularity := make(map[uint]float64, len(raw))
+	if maxRaw == 0 {
+		return popularity, nil
+	}
+	for bookID, value := range raw {
+		popularity[bookID] = math.Log1p(value) / math.Log1p(maxRaw)
+	}
+	return popularity, nil
+}
@@ -0,0 +1,207 @@
+package middlewares_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sd0ric4/book-reader-backend/app/config"
+	"github.com/sd0ric4/book-reader-backend/app/database"
+	middlewares "github.com/sd0ric4/book-reader-backend/app/middleewares"
+	"github.com/sd0ric4/book-reader-backend/app/models"
+	"github.com/sd0ric4/book-reader-backend/app/services"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestDB(t *testing.T) *models.User {
+	var err error
+	database.MySQLDB, err = gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect database: %v", err)
+	}
+	database.MySQLDB.AutoMigrate(&models.User{})
+
+	config.Config = &config.ConfigStruct{
+		JWT: config.JWTConfig{Secret: "test-secret", Expire: 3600},
+	}
+
+	mr := miniredis.RunT(t)
+	database.RedisDB = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+
+	user := &models.User{Username: "reader", Email: "reader@example.com", Password: "hashed"}
+	database.MySQLDB.Create(user)
+	return user
+}
+
+func setupRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/me", middlewares.AuthMiddleware(), func(c *gin.Context) {
+		user, ok := middlewares.CurrentUser(c)
+		if !ok {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "no user in context"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"id": user.ID, "username": user.Username})
+	})
+	return router
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, key interface{}, claims jwt.MapClaims) string {
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return token
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	user := setupTestDB(t)
+	router := setupRouter()
+
+	tokens, err := services.CreateSession(context.Background(), user.ID, "test", "127.0.0.1")
+	assert.NoError(t, err)
+	validToken := tokens.AccessToken
+
+	revoked, err := services.CreateSession(context.Background(), user.ID, "test", "127.0.0.1")
+	assert.NoError(t, err)
+	sessions, err := services.ListSessions(context.Background(), user.ID)
+	assert.NoError(t, err)
+	for _, session := range sessions {
+		if strings.HasPrefix(revoked.RefreshToken, session.ID+".") {
+			assert.NoError(t, services.RevokeSession(context.Background(), user.ID, session.ID))
+		}
+	}
+
+	testCases := []struct {
+		name       string
+		header     string
+		expectCode int
+	}{
+		{
+			name:       "Missing header",
+			header:     "",
+			expectCode: http.StatusUnauthorized,
+		},
+		{
+			name:       "Missing bearer prefix",
+			header:     validToken,
+			expectCode: http.StatusUnauthorized,
+		},
+		{
+			name:       "Valid token",
+			header:     "Bearer " + validToken,
+			expectCode: http.StatusOK,
+		},
+		{
+			name:       "Revoked session",
+			header:     "Bearer " + revoked.AccessToken,
+			expectCode: http.StatusUnauthorized,
+		},
+		{
+			name: "Wrong secret",
+			header: "Bearer " + signToken(t, jwt.SigningMethodHS256, []byte("other-secret"), jwt.MapClaims{
+				"user_id": user.ID,
+				"sid":     "unknown",
+				"exp":     time.Now().Add(time.Hour).Unix(),
+			}),
+			expectCode: http.StatusUnauthorized,
+		},
+		{
+			name: "Expired token",
+			header: "Bearer " + signToken(t, jwt.SigningMethodHS256, []byte("test-secret"), jwt.MapClaims{
+				"user_id": user.ID,
+				"sid":     "unknown",
+				"exp":     time.Now().Add(-time.Hour).Unix(),
+			}),
+			expectCode: http.StatusUnauthorized,
+		},
+		{
+			name: "Missing expiry",
+			header: "Bearer " + signToken(t, jwt.SigningMethodHS256, []byte("test-secret"), jwt.MapClaims{
+				"user_id": user.ID,
+				"sid":     "unknown",
+			}),
+			expectCode: http.StatusUnauthorized,
+		},
+		{
+			name: "Unexpected algorithm",
+			header: "Bearer " + signToken(t, jwt.SigningMethodHS512, []byte("test-secret"), jwt.MapClaims{
+				"user_id": user.ID,
+				"sid":     "unknown",
+				"exp":     time.Now().Add(time.Hour).Unix(),
+			}),
+			expectCode: http.StatusUnauthorized,
+		},
+		{
+			name: "Unknown user",
+			header: "Bearer " + signToken(t, jwt.SigningMethodHS256, []byte("test-secret"), jwt.MapClaims{
+				"user_id": 9999,
+				"sid":     "unknown",
+				"exp":     time.Now().Add(time.Hour).Unix(),
+			}),
+			expectCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/me", nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectCode, w.Code)
+		})
+	}
+}
+
+func TestValidateSessionThrottlesLastSeen(t *testing.T) {
+	user := setupTestDB(t)
+	ctx := context.Background()
+	_, err := services.CreateSession(ctx, user.ID, "test", "127.0.0.1")
+	assert.NoError(t, err)
+	sessions, err := services.ListSessions(ctx, user.ID)
+	assert.NoError(t, err)
+	sessionID := sessions[0].ID
+	key := "session:" + sessionID
+
+	lastSeen := func() int64 {
+		value, err := database.RedisDB.HGet(ctx, key, "last_seen").Int64()
+		assert.NoError(t, err)
+		return value
+	}
+
+	// 一分钟内的请求不更新最后活跃时间
+	recent := time.Now().Add(-30 * time.Second).Unix()
+	database.RedisDB.HSet(ctx, key, "last_seen", recent)
+	assert.NoError(t, services.ValidateSession(ctx, user.ID, sessionID, "127.0.0.1"))
+	assert.Equal(t, recent, lastSeen())
+
+	// IP 变化时立即更新
+	assert.NoError(t, services.ValidateSession(ctx, user.ID, sessionID, "10.0.0.1"))
+	assert.Greater(t, lastSeen(), recent)
+	ip, _ := database.RedisDB.HGet(ctx, key, "ip").Result()
+	assert.Equal(t, "10.0.0.1", ip)
+
+	// 超过一分钟后更新
+	stale := time.Now().Add(-2 * time.Minute).Unix()
+	database.RedisDB.HSet(ctx, key, "last_seen", stale)
+	assert.NoError(t, services.ValidateSession(ctx, user.ID, sessionID, "10.0.0.1"))
+	assert.Greater(t, lastSeen(), stale)
+
+	assert.ErrorIs(t, services.ValidateSession(ctx, user.ID+1, sessionID, "10.0.0.1"), services.ErrSessionNotFound)
+	assert.ErrorIs(t, services.ValidateSession(ctx, user.ID, "unknown", "10.0.0.1"), services.ErrSessionNotFound)
+}
@@ -0,0 +1,168 @@
+package routes_test
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sd0ric4/book-reader-backend/app/database"
+	"github.com/sd0ric4/book-reader-backend/app/models"
+	"github.com/sd0ric4/book-reader-backend/app/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnnotations(t *testing.T) {
+	router := setupTestEnv(t)
+	owner := createUserWithRole(t, "owner", models.RoleUploader)
+	reader := createUserWithRole(t, "reader", models.RoleReader)
+	other := createUserWithRole(t, "other", models.RoleReader)
+	token := tokenFor(t, reader)
+
+	book, chapters := createBookWithChapters(t, "poem", owner, "床前明月光，疑是地上霜。", "举头望明月，低头思故乡。")
+	_, otherChapters := createBookWithChapters(t, "other", owner, "其他内容")
+
+	w := doRequest(router, "POST", fmt.Sprintf("/books/%d/highlights", book.ID), token, gin.H{
+		"chapter_id": otherChapters[0].ID, "start_offset": 0, "end_offset": 2,
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = doRequest(router, "POST", fmt.Sprintf("/books/%d/highlights", book.ID), token, gin.H{
+		"chapter_id": chapters[1].ID, "start_offset": 0, "end_offset": 50,
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = doRequest(router, "POST", fmt.Sprintf("/books/%d/highlights", book.ID), token, gin.H{
+		"chapter_id": chapters[1].ID, "start_offset": 0, "end_offset": 5, "color": "black",
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doRequest(router, "POST", fmt.Sprintf("/books/%d/highlights", book.ID), token, gin.H{
+		"chapter_id": chapters[1].ID, "start_offset": 6, "end_offset": 11, "color": "green", "note": "思乡",
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var highlight models.Highlight
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &highlight))
+	assert.Equal(t, "低头思故乡", highlight.Quote)
+	assert.Equal(t, "举头望明月，", highlight.Prefix)
+
+	w = doRequest(router, "POST", fmt.Sprintf("/books/%d/bookmarks", book.ID), token, gin.H{
+		"chapter_id": chapters[0].ID, "offset": 6, "label": "第二句",
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var bookmark models.Bookmark
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &bookmark))
+
+	// 其他用户看不到也改不了
+	w = doRequest(router, "GET", fmt.Sprintf("/highlights/%d", highlight.ID), tokenFor(t, other), nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = doRequest(router, "DELETE", fmt.Sprintf("/bookmarks/%d", bookmark.ID), tokenFor(t, other), nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = doRequest(router, "PUT", fmt.Sprintf("/highlights/%d", highlight.ID), token, gin.H{"color": "pink", "label": "名句"})
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &highlight))
+	assert.Equal(t, "pink", highlight.Color)
+	assert.Equal(t, "思乡", highlight.Note)
+
+	// 重新提取章节：旧章节被删除，新章节开头多了一段文字
+	require.NoError(t, models.DeleteBookChapters(database.MySQLDB, book.ID))
+	reextracted := []models.BookChapter{
+		{BookID: book.ID, ChapterName: "第1章", ChapterContent: "静夜思\n床前明月光，疑是地上霜。", ChapterStructure: `{}`},
+		{BookID: book.ID, ChapterName: "第2章", ChapterContent: "举头望明月，低头思故乡。", ChapterStructure: `{}`},
+	}
+	require.NoError(t, models.CreateChapters(database.MySQLDB, reextracted))
+
+	w = doRequest(router, "GET", fmt.Sprintf("/books/%d/annotations", book.ID), token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var annotations models.BookAnnotations
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &annotations))
+	require.Len(t, annotations.Highlights, 1)
+	require.Len(t, annotations.Bookmarks, 1)
+	assert.Equal(t, reextracted[1].ID, annotations.Highlights[0].ChapterID)
+	assert.Equal(t, 6, annotations.Highlights[0].StartOffset)
+	assert.False(t, annotations.Highlights[0].Orphaned)
+	assert.Equal(t, reextracted[0].ID, annotations.Bookmarks[0].ChapterID)
+	assert.Equal(t, 10, annotations.Bookmarks[0].StartOffset)
+
+	// 原文被删除后标记为孤立，但不会丢失
+	require.NoError(t, models.UpdateChapterContent(database.MySQLDB, reextracted[1].ID, "全新的内容"))
+	w = doRequest(router, "GET", fmt.Sprintf("/books/%d/annotations", book.ID), token, nil)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &annotations))
+	assert.True(t, annotations.Highlights[0].Orphaned)
+	assert.Equal(t, "低头思故乡", annotations.Highlights[0].Quote)
+
+	w = doRequest(router, "DELETE", fmt.Sprintf("/highlights/%d", highlight.ID), token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	w = doRequest(router, "GET", fmt.Sprintf("/highlights/%d", highlight.ID), token, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAnnotationExport(t *testing.T) {
+	router := setupTestEnv(t)
+	owner := createUserWithRole(t, "owner", models.RoleUploader)
+	reader := createUserWithRole(t, "reader", models.RoleReader)
+	token := tokenFor(t, reader)
+
+	book, chapters := createBookWithChapters(t, "poem", owner, "床前明月光，疑是地上霜。", "举头望明月，低头思故乡。")
+	second, secondChapters := createBookWithChapters(t, "second", owner, "白日依山尽，黄河入海流。")
+	highlights := []struct {
+		book    *models.Book
+		chapter models.BookChapter
+		start   int
+		note    string
+	}{
+		{book, chapters[1], 6, "思乡, \"名句\""},
+		{book, chapters[0], 0, ""},
+		{second, secondChapters[0], 6, ""},
+	}
+	for _, h := range highlights {
+		w := doRequest(router, "POST", fmt.Sprintf("/books/%d/highlights", h.book.ID), token, gin.H{
+			"chapter_id": h.chapter.ID, "start_offset": h.start, "end_offset": h.start + 5, "note": h.note,
+		})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+
+	path := fmt.Sprintf("/books/%d/annotations/export", book.ID)
+	w := doRequest(router, "GET", path+"?format=pdf", token, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doRequest(router, "GET", path, token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Disposition"), ".md")
+	markdown := w.Body.String()
+	assert.Contains(t, markdown, "# poem")
+	assert.Contains(t, markdown, "## 第1章")
+	assert.Contains(t, markdown, "> 低头思故乡")
+	assert.Contains(t, markdown, "- Location: chapter 2, character 6")
+	assert.Contains(t, markdown, "- Note: 思乡")
+	// 按章节顺序分组
+	assert.Less(t, strings.Index(markdown, "第1章"), strings.Index(markdown, "第2章"))
+
+	w = doRequest(router, "GET", path+"?format=json", token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var exported []services.ExportedBook
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &exported))
+	require.Len(t, exported, 1)
+	require.Len(t, exported[0].Chapters, 2)
+	assert.Equal(t, "第2章", exported[0].Chapters[1].Title)
+	assert.Equal(t, "低头思故乡", exported[0].Chapters[1].Highlights[0].Quote)
+
+	// 按用户导出包含所有书
+	w = doRequest(router, "GET", "/users/me/annotations/export?format=csv", token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	records, err := csv.NewReader(w.Body).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+	assert.Equal(t, []string{"Highlight", "Title", "Author", "URL", "Note", "Location", "Date", "Chapter"}, records[0])
+	assert.Equal(t, "低头思故乡", records[2][0])
+	assert.Equal(t, "思乡, \"名句\"", records[2][4])
+	assert.Equal(t, "second", records[3][1])
+
+	// 没有高亮时返回空数组
+	w = doRequest(router, "GET", "/users/me/annotations/export?format=json", tokenFor(t, owner), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "[]", w.Body.String())
+}
@@ -0,0 +1,112 @@
+package routes_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sd0ric4/book-reader-backend/app/database"
+	"github.com/sd0ric4/book-reader-backend/app/models"
+	"github.com/sd0ric4/book-reader-backend/app/services"
+	"github.com/sd0ric4/book-reader-backend/app/services/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAskBook(t *testing.T) {
+	router := setupTestEnv(t)
+	owner := createUserWithRole(t, "owner", models.RoleUploader)
+	reader := createUserWithRole(t, "reader", models.RoleReader)
+	token := tokenFor(t, reader)
+
+	book, chapters := createBookWithChapters(t, "三体", owner,
+		"叶文洁在红岸基地工作。她是一位天体物理学家。",
+		"汪淼参加了科学边界的聚会。汪淼看到了倒计时。",
+		"叶文洁向三体文明发出了信号。",
+	)
+	path := fmt.Sprintf("/books/%d/ask", book.ID)
+	question := gin.H{"question": "叶文洁是谁？"}
+
+	services.LLM = nil
+	defer func() { services.LLM = nil }()
+	w := doRequest(router, "POST", path, token, question)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	fake := llm.NewFake(func(req llm.Request) (string, error) {
+		return "叶文洁是一位天体物理学家 [1]。", nil
+	})
+	services.LLM = fake
+
+	w = doRequest(router, "POST", path, token, gin.H{"question": "  "})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = doRequest(router, "POST", fmt.Sprintf("/books/%d/ask", 9999), token, question)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// 还没有开始阅读时没有可以使用的内容
+	w = doRequest(router, "POST", path, token, question)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Empty(t, fake.Requests())
+
+	// 读到第2章中间：只能检索第1章和第2章已读的部分
+	progress := fmt.Sprintf("/books/%d/progress", book.ID)
+	w = doRequest(router, "PUT", progress, token, gin.H{"chapter_id": chapters[1].ID, "offset": 13, "percentage": 50})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = doRequest(router, "POST", path, token, question)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var answer services.BookAnswer
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &answer))
+	assert.Equal(t, "叶文洁是一位天体物理学家 [1]。", answer.Answer)
+	require.Len(t, answer.Citations, 1)
+	assert.Equal(t, 1, answer.Citations[0].Index)
+	assert.Equal(t, chapters[0].ID, answer.Citations[0].ChapterID)
+	assert.Equal(t, "第1章", answer.Citations[0].ChapterName)
+	assert.Equal(t, 0, answer.Citations[0].Start)
+	assert.Equal(t, 22, answer.Citations[0].End)
+
+	prompt := fake.Requests()[0].Messages[0].Content
+	assert.Contains(t, prompt, "[1] 第1章\n叶文洁在红岸基地工作。")
+	assert.Contains(t, prompt, "问题：叶文洁是谁？")
+	assert.NotContains(t, prompt, "三体文明")
+
+	// 当前章节只使用进度之前的内容
+	w = doRequest(router, "POST", path, token, gin.H{"question": "汪淼看到了什么？"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	prompt = fake.Requests()[1].Messages[0].Content
+	assert.Contains(t, prompt, "汪淼参加了科学边界的聚会。")
+	assert.NotContains(t, prompt, "倒计时")
+
+	w = doRequest(router, "POST", path, token, gin.H{"question": "三体文明"})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// 读完全书后可以检索所有章节，答案没有标注引用时返回全部段落
+	w = doRequest(router, "PUT", progress, token, gin.H{"chapter_id": chapters[2].ID, "percentage": 100})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	fake.Reply = func(req llm.Request) (string, error) {
+		return "叶文洁向三体文明发出了信号。", nil
+	}
+	w = doRequest(router, "POST", path, token, gin.H{"question": "谁向三体文明发出了信号？"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &answer))
+	require.NotEmpty(t, answer.Citations)
+	chapterIDs := make([]uint, len(answer.Citations))
+	for i, citation := range answer.Citations {
+		chapterIDs[i] = citation.ChapterID
+	}
+	assert.Contains(t, chapterIDs, chapters[2].ID)
+
+	// 章节内容变化后重新建立索引
+	require.NoError(t, models.UpdateChapterContent(database.MySQLDB, chapters[0].ID, "罗辑成为了面壁者。"))
+	w = doRequest(router, "POST", path, token, gin.H{"question": "罗辑"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &answer))
+	assert.Equal(t, chapters[0].ID, answer.Citations[0].ChapterID)
+
+	// 看不到的书不能提问
+	private, _ := createBookWithChapters(t, "私密", owner, "叶文洁")
+	require.NoError(t, database.MySQLDB.Model(private).Update("visibility", models.VisibilityPrivate).Error)
+	w = doRequest(router, "POST", fmt.Sprintf("/books/%d/ask", private.ID), token, question)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
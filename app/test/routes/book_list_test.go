@@ -0,0 +1,137 @@
+package routes_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sd0ric4/book-reader-backend/app/database"
+	"github.com/sd0ric4/book-reader-backend/app/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func listBooks(t *testing.T, router *gin.Engine, query string) models.BookPage {
+	w := doRequest(router, "GET", "/books/list?"+query, "", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var page models.BookPage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	return page
+}
+
+func pageTitles(page models.BookPage) []string {
+	titles := make([]string, len(page.Items))
+	for i, book := range page.Items {
+		titles[i] = book.Title
+	}
+	return titles
+}
+
+func TestBookListPagination(t *testing.T) {
+	router := setupTestEnv(t)
+	owner := createUserWithRole(t, "owner", models.RoleUploader)
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 7; i++ {
+		book := &models.Book{
+			Title:      fmt.Sprintf("book-%d", i),
+			Author:     []string{"鲁迅", "老舍"}[i%2],
+			BookURL:    "http://example.com",
+			Format:     []string{"epub", "pdf"}[i%2],
+			Tags:       []string{`["小说"]`, `["散文","小说集"]`}[i%2],
+			Score:      float64(i % 3),
+			OwnerID:    &owner.ID,
+			Visibility: models.VisibilityPublic,
+			CreatedAt:  base.AddDate(0, 0, i),
+		}
+		require.NoError(t, models.CreateBook(database.MySQLDB, book))
+	}
+
+	// 默认按创建时间倒序
+	page := listBooks(t, router, "page_size=3")
+	assert.Equal(t, int64(7), page.Total)
+	assert.Equal(t, []string{"book-6", "book-5", "book-4"}, pageTitles(page))
+	require.NotEmpty(t, page.NextCursor)
+
+	// 游标翻页直到结束
+	var titles []string
+	cursor := ""
+	for {
+		page = listBooks(t, router, "sort=title&page_size=3&cursor="+url.QueryEscape(cursor))
+		titles = append(titles, pageTitles(page)...)
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	assert.Equal(t, []string{"book-0", "book-1", "book-2", "book-3", "book-4", "book-5", "book-6"}, titles)
+
+	// 分数有重复时按ID区分
+	titles = nil
+	cursor = ""
+	for {
+		page = listBooks(t, router, "sort=score&order=desc&page_size=2&cursor="+url.QueryEscape(cursor))
+		titles = append(titles, pageTitles(page)...)
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	assert.Equal(t, []string{"book-5", "book-2", "book-4", "book-1", "book-6", "book-3", "book-0"}, titles)
+
+	// 偏移分页
+	page = listBooks(t, router, "sort=title&page=3&page_size=3")
+	assert.Equal(t, 3, page.Page)
+	assert.Equal(t, []string{"book-6"}, pageTitles(page))
+	assert.Empty(t, page.NextCursor)
+
+	// 排序方式与游标不一致
+	first := listBooks(t, router, "sort=title&page_size=3")
+	w := doRequest(router, "GET", "/books/list?sort=author&cursor="+url.QueryEscape(first.NextCursor), "", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	for _, query := range []string{"sort=rating", "order=up", "page=0", "page_size=1000", "created_after=yesterday", "page=1&cursor=abc", "cursor=bm90LWpzb24"} {
+		w = doRequest(router, "GET", "/books/list?"+query, "", nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestBookListFilters(t *testing.T) {
+	router := setupTestEnv(t)
+	owner := createUserWithRole(t, "owner", models.RoleUploader)
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	books := []models.Book{
+		{Title: "呐喊", Author: "鲁迅", Format: "epub", Tags: `["小说"]`, CreatedAt: base},
+		{Title: "朝花夕拾", Author: "鲁迅", Format: "pdf", Tags: `["散文"]`, CreatedAt: base.AddDate(0, 1, 0)},
+		{Title: "骆驼祥子", Author: "老舍", Format: "epub", Tags: `["小说集"]`, CreatedAt: base.AddDate(0, 2, 0)},
+		{Title: "100%_", Author: "无名", Format: "txt", Tags: `["100%_"]`, CreatedAt: base.AddDate(0, 3, 0)},
+	}
+	for i := range books {
+		books[i].BookURL = "http://example.com"
+		books[i].OwnerID = &owner.ID
+		books[i].Visibility = models.VisibilityPublic
+		require.NoError(t, models.CreateBook(database.MySQLDB, &books[i]))
+	}
+
+	testCases := []struct {
+		query  string
+		expect []string
+	}{
+		{"author=" + url.QueryEscape("鲁迅"), []string{"呐喊", "朝花夕拾"}},
+		{"format=epub", []string{"呐喊", "骆驼祥子"}},
+		{"tag=" + url.QueryEscape("小说"), []string{"呐喊"}},
+		{"tag=" + url.QueryEscape("%"), []string{}},
+		{"tag=" + url.QueryEscape("100%_"), []string{"100%_"}},
+		{"created_after=2024-02-01&created_before=2024-03-15", []string{"朝花夕拾", "骆驼祥子"}},
+		{"author=" + url.QueryEscape("鲁迅") + "&format=pdf", []string{"朝花夕拾"}},
+	}
+	for _, tc := range testCases {
+		page := listBooks(t, router, tc.query+"&sort=created&order=asc")
+		assert.Equal(t, tc.expect, pageTitles(page), tc.query)
+		assert.Equal(t, int64(len(tc.expect)), page.Total, tc.query)
+	}
+}
@@ -0,0 +1,123 @@
+package routes_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sd0ric4/book-reader-backend/app/models"
+	"github.com/sd0ric4/book-reader-backend/app/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// device 模拟一台记录本地位置并定期同步的阅读设备
+type device struct {
+	id          string
+	seenVersion int64
+}
+
+func (d *device) sync(t *testing.T, router *gin.Engine, path, token string, percentage float64, at time.Time, policy string) services.SyncProgressResult {
+	w := doRequest(router, "POST", path, token, gin.H{
+		"device_id":         d.id,
+		"percentage":        percentage,
+		"client_updated_at": at,
+		"base_version":      d.seenVersion,
+		"policy":            policy,
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var result services.SyncProgressResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	d.seenVersion = result.Progress.Version
+	return result
+}
+
+func TestProgressSync(t *testing.T) {
+	router := setupTestEnv(t)
+	owner := createUserWithRole(t, "owner", models.RoleUploader)
+	reader := createUserWithRole(t, "reader", models.RoleReader)
+	token := tokenFor(t, reader)
+	book, _ := createBookWithChapters(t, "book", owner, "内容")
+	path := fmt.Sprintf("/books/%d/progress/sync", book.ID)
+
+	phone := &device{id: "phone"}
+	ereader := &device{id: "e-ink"}
+	start := time.Now()
+
+	// 两台设备从同一起点开始
+	result := phone.sync(t, router, path, token, 10, start, "")
+	assert.True(t, result.Accepted)
+	ereader.sync(t, router, path, token, 10, start, "")
+
+	// 手机读到 60%
+	result = phone.sync(t, router, path, token, 60, start.Add(time.Minute), services.SyncPolicyFurthest)
+	assert.True(t, result.Accepted)
+	assert.False(t, result.Superseded)
+
+	// 墨水屏离线读到 30% 后才同步：不会倒退，客户端被告知位置已被覆盖
+	result = ereader.sync(t, router, path, token, 30, start.Add(2*time.Minute), services.SyncPolicyFurthest)
+	assert.False(t, result.Accepted)
+	assert.True(t, result.Superseded)
+	assert.True(t, result.Conflict)
+	assert.InDelta(t, 60, result.Progress.Percentage, 0.001)
+
+	// 墨水屏已跳转到最新位置，此后主动往回翻是允许的
+	result = ereader.sync(t, router, path, token, 40, start.Add(3*time.Minute), services.SyncPolicyFurthest)
+	assert.True(t, result.Accepted)
+	assert.False(t, result.Conflict)
+
+	// 按最近策略，手机上更新的位置胜出
+	result = phone.sync(t, router, path, token, 20, start.Add(4*time.Minute), services.SyncPolicyRecent)
+	assert.True(t, result.Accepted)
+	assert.True(t, result.Conflict)
+
+	// 同一设备的过期请求被忽略
+	result = phone.sync(t, router, path, token, 99, start.Add(time.Minute), services.SyncPolicyFurthest)
+	assert.False(t, result.Accepted)
+	assert.InDelta(t, 20, result.Progress.Percentage, 0.001)
+
+	w := doRequest(router, "GET", fmt.Sprintf("/books/%d/progress/devices", book.ID), token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var devices []models.ReadingDevice
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &devices))
+	assert.Len(t, devices, 2)
+}
+
+func TestProgressSyncConcurrentDevices(t *testing.T) {
+	router := setupTestEnv(t)
+	owner := createUserWithRole(t, "owner", models.RoleUploader)
+	reader := createUserWithRole(t, "reader", models.RoleReader)
+	token := tokenFor(t, reader)
+	book, _ := createBookWithChapters(t, "book", owner, "内容")
+	path := fmt.Sprintf("/books/%d/progress/sync", book.ID)
+
+	// 多台设备在同一基准版本上同时上报，按最远策略最终保留最大的位置
+	const devices = 8
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < devices; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			doRequest(router, "POST", path, token, gin.H{
+				"device_id":         fmt.Sprintf("device-%d", i),
+				"percentage":        float64(10 * (i + 1)),
+				"client_updated_at": start.Add(time.Duration(i) * time.Second),
+				"policy":            services.SyncPolicyFurthest,
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	w := doRequest(router, "GET", fmt.Sprintf("/books/%d/progress", book.ID), token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var progress models.ReadingProgress
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &progress))
+	assert.InDelta(t, 80, progress.Percentage, 0.001)
+	assert.Equal(t, "device-7", progress.DeviceID)
+}
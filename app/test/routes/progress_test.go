@@ -0,0 +1,97 @@
+package routes_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sd0ric4/book-reader-backend/app/database"
+	"github.com/sd0ric4/book-reader-backend/app/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createBookWithChapters(t *testing.T, title string, owner *models.User, chapters ...string) (*models.Book, []models.BookChapter) {
+	book := &models.Book{Title: title, Author: "Author", BookURL: "http://example.com/" + title, Tags: `[]`, OwnerID: &owner.ID}
+	require.NoError(t, book.SetVisibility(models.VisibilityPublic))
+	require.NoError(t, models.CreateBook(database.MySQLDB, book))
+
+	bookChapters := make([]models.BookChapter, len(chapters))
+	for i, content := range chapters {
+		bookChapters[i] = models.BookChapter{
+			BookID:           book.ID,
+			ChapterName:      fmt.Sprintf("第%d章", i+1),
+			ChapterContent:   content,
+			ChapterStructure: `{}`,
+		}
+	}
+	if len(bookChapters) > 0 {
+		require.NoError(t, models.CreateChapters(database.MySQLDB, bookChapters))
+	}
+	return book, bookChapters
+}
+
+func TestReadingProgress(t *testing.T) {
+	router := setupTestEnv(t)
+	owner := createUserWithRole(t, "owner", models.RoleUploader)
+	reader := createUserWithRole(t, "reader", models.RoleReader)
+	token := tokenFor(t, reader)
+
+	book, chapters := createBookWithChapters(t, "book", owner, "第一章内容", "第二章内容")
+	other, otherChapters := createBookWithChapters(t, "other", owner, "其他内容")
+	path := fmt.Sprintf("/books/%d/progress", book.ID)
+
+	w := doRequest(router, "GET", path, token, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// 章节必须属于同一本书
+	w = doRequest(router, "PUT", path, token, gin.H{"chapter_id": otherChapters[0].ID, "percentage": 10})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = doRequest(router, "PUT", path, token, gin.H{"percentage": 120})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doRequest(router, "PUT", path, token, gin.H{"chapter_id": chapters[0].ID, "offset": 3, "percentage": 10})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// 再次保存会更新同一条记录
+	w = doRequest(router, "PUT", path, token, gin.H{
+		"chapter_id": chapters[1].ID,
+		"offset":     2,
+		"cfi":        "epubcfi(/6/4!/4/2/1:2)",
+		"percentage": 55.5,
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = doRequest(router, "GET", path, token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var progress models.ReadingProgress
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &progress))
+	assert.Equal(t, chapters[1].ID, *progress.ChapterID)
+	assert.Equal(t, 2, progress.Offset)
+	assert.Equal(t, "epubcfi(/6/4!/4/2/1:2)", progress.CFI)
+	assert.InDelta(t, 55.5, progress.Percentage, 0.001)
+
+	var count int64
+	database.MySQLDB.Model(&models.ReadingProgress{}).Where("user_id = ?", reader.ID).Count(&count)
+	assert.Equal(t, int64(1), count)
+
+	// 读完的书不出现在继续阅读列表中
+	finished, _ := createBookWithChapters(t, "finished", owner)
+	require.NoError(t, models.UpsertReadingProgress(database.MySQLDB, &models.ReadingProgress{
+		UserID: reader.ID, BookID: finished.ID, Percentage: 100,
+	}))
+	require.NoError(t, models.UpsertReadingProgress(database.MySQLDB, &models.ReadingProgress{
+		UserID: reader.ID, BookID: other.ID, Percentage: 5, LastReadAt: time.Now().Add(-time.Hour),
+	}))
+
+	w = doRequest(router, "GET", "/users/me/continue-reading", token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var items []models.ContinueReadingItem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &items))
+	require.Len(t, items, 2)
+	assert.Equal(t, book.ID, items[0].Book.ID)
+	assert.Equal(t, other.ID, items[1].Book.ID)
+}
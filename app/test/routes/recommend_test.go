@@ -0,0 +1,302 @@
+package routes_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sd0ric4/book-reader-backend/app/database"
+	"github.com/sd0ric4/book-reader-backend/app/models"
+	"github.com/sd0ric4/book-reader-backend/app/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createTaggedBook 创建带标签和简介的公开书籍
+func createTaggedBook(t *testing.T, title string, owner *models.User, tags, description string) *models.Book {
+	book, _ := createBookWithChapters(t, title, owner)
+	book.Tags = tags
+	book.Description = description
+	require.NoError(t, models.UpdateBook(database.MySQLDB, book))
+	return book
+}
+
+func getRecommendations(t *testing.T, router *gin.Engine, token, query string) []services.Recommendation {
+	w := doRequest(router, "GET", "/users/me/recommendations"+query, token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		Recommendations []services.Recommendation `json:"recommendations"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp.Recommendations
+}
+
+func recommendedTitles(recommendations []services.Recommendation) []string {
+	titles := make([]string, len(recommendations))
+	for i, recommendation := range recommendations {
+		titles[i] = recommendation.Book.Title
+	}
+	return titles
+}
+
+func TestBookRating(t *testing.T) {
+	router := setupTestEnv(t)
+	owner := createUserWithRole(t, "owner", models.RoleUploader)
+	reader := createUserWithRole(t, "reader", models.RoleReader)
+	book, _ := createBookWithChapters(t, "book", owner)
+	path := fmt.Sprintf("/books/%d/rating", book.ID)
+
+	w := doRequest(router, "PUT", path, tokenFor(t, reader), gin.H{"rating": 6})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	for _, step := range []struct {
+		user   *models.User
+		rating int
+	}{{reader, 2}, {reader, 4}, {owner, 5}} {
+		w = doRequest(router, "PUT", path, tokenFor(t, step.user), gin.H{"rating": step.rating})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+
+	w = doRequest(router, "GET", path, tokenFor(t, reader), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Stats  models.BookRatingStats `json:"stats"`
+		Rating *models.BookRating     `json:"rating"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, int64(2), resp.Stats.Count)
+	assert.InDelta(t, 4.5, resp.Stats.Average, 0.001)
+	require.NotNil(t, resp.Rating)
+	assert.Equal(t, 4, resp.Rating.Rating)
+
+	w = doRequest(router, "DELETE", path, tokenFor(t, reader), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	w = doRequest(router, "GET", path, tokenFor(t, reader), nil)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, int64(1), resp.Stats.Count)
+	assert.Nil(t, resp.Rating)
+}
+
+func TestUserRecommendations(t *testing.T) {
+	router := setupTestEnv(t)
+	owner := createUserWithRole(t, "owner", models.RoleUploader)
+	reader := createUserWithRole(t, "reader", models.RoleReader)
+	fan := createUserWithRole(t, "fan", models.RoleReader)
+	token := tokenFor(t, reader)
+
+	threeBody := createTaggedBook(t, "三体", owner, `["科幻","太空"]`, "文明与宇宙社会学")
+	foundation := createTaggedBook(t, "银河帝国", owner, `["科幻","太空"]`, "银河帝国的衰落与宇宙")
+	createTaggedBook(t, "流浪地球", owner, `["科幻"]`, "带着地球去流浪")
+	romance := createTaggedBook(t, "傲慢与偏见", owner, `["言情","经典"]`, "爱情故事")
+	classic := createTaggedBook(t, "红楼梦", owner, `["经典","古典"]`, "家族的兴衰")
+	private := createTaggedBook(t, "私藏科幻", owner, `["科幻","太空"]`, "宇宙")
+	require.NoError(t, private.SetVisibility(models.VisibilityPrivate))
+	require.NoError(t, models.UpdateBook(database.MySQLDB, private))
+	// 直接写入数据库的书籍需要重建索引
+	require.NoError(t, services.InitRecommendIndex(database.MySQLDB))
+
+	w := doRequest(router, "GET", "/users/me/recommendations", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = doRequest(router, "GET", "/users/me/recommendations?limit=0", token, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// 没有行为时按流行度推荐
+	require.NoError(t, models.AddFavorite(database.MySQLDB, fan.ID, romance.ID))
+	recommendations := getRecommendations(t, router, token, "")
+	titles := recommendedTitles(recommendations)
+	require.Len(t, titles, 5)
+	assert.Equal(t, "傲慢与偏见", titles[0])
+	assert.NotContains(t, titles, "私藏科幻")
+	// 只有被其他读者收藏过的书说明为受欢迎，其余补足的书使用中性的说明
+	assert.Equal(t, "Popular with other readers", recommendations[0].Explanation.Reason)
+	for _, recommendation := range recommendations[1:] {
+		assert.Equal(t, "You might also like", recommendation.Explanation.Reason)
+	}
+
+	// 读过科幻书后推荐相似的科幻书，读过、收藏过和评过分的书不再推荐
+	w = doRequest(router, "PUT", fmt.Sprintf("/books/%d/progress", threeBody.ID), token, gin.H{"percentage": 100})
+	require.Equal(t, http.StatusOK, w.Code)
+	w = doRequest(router, "PUT", fmt.Sprintf("/books/%d/rating", romance.ID), token, gin.H{"rating": 1})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	recommendations = getRecommendations(t, router, token, "?limit=2")
+	titles = recommendedTitles(recommendations)
+	assert.Equal(t, []string{"银河帝国", "流浪地球"}, titles)
+	assert.Greater(t, recommendations[0].Score, recommendations[1].Score)
+
+	w = doRequest(router, "POST", fmt.Sprintf("/books/%d/favorite", foundation.ID), token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	titles = recommendedTitles(getRecommendations(t, router, token, ""))
+	assert.Equal(t, []string{"流浪地球", "红楼梦"}, titles)
+
+	// 修改简介后索引随之更新
+	before := getRecommendations(t, router, token, "")[1]
+	require.Equal(t, "红楼梦", before.Book.Title)
+	w = doRequest(router, "PUT", fmt.Sprintf("/books/%d", classic.ID), tokenFor(t, owner), gin.H{"description": "科幻太空宇宙文明"})
+	require.Equal(t, http.StatusOK, w.Code)
+	after := getRecommendations(t, router, token, "")[1]
+	require.Equal(t, "红楼梦", after.Book.Title)
+	assert.Greater(t, after.Score, before.Score)
+
+	// 删除后不再推荐
+	w = doRequest(router, "DELETE", fmt.Sprintf("/books/%d", classic.ID), tokenFor(t, owner), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 5, services.RecommendIndex.Len())
+	titles = recommendedTitles(getRecommendations(t, router, token, ""))
+	assert.Equal(t, []string{"流浪地球"}, titles)
+}
+
+func TestSimilarBooks(t *testing.T) {
+	router := setupTestEnv(t)
+	owner := createUserWithRole(t, "owner", models.RoleUploader)
+	threeBody := createTaggedBook(t, "三体", owner, `["科幻","太空"]`, "文明与宇宙社会学")
+	createTaggedBook(t, "银河帝国", owner, `["科幻","太空"]`, "银河帝国的衰落")
+	history := createTaggedBook(t, "万历十五年", owner, `["历史"]`, "明朝的一年")
+	createTaggedBook(t, "傲慢与偏见", owner, `["言情"]`, "爱情故事")
+	private := createTaggedBook(t, "私藏", owner, `["历史"]`, "明朝")
+	require.NoError(t, private.SetVisibility(models.VisibilityPrivate))
+	require.NoError(t, models.UpdateBook(database.MySQLDB, private))
+	require.NoError(t, services.InitRecommendIndex(database.MySQLDB))
+
+	// 两位读者都读了三体和万历十五年
+	for _, name := range []string{"r1", "r2"} {
+		reader := createUserWithRole(t, name, models.RoleReader)
+		for _, book := range []*models.Book{threeBody, history, private} {
+			progress := &models.ReadingProgress{UserID: reader.ID, BookID: book.ID, Percentage: 100}
+			require.NoError(t, models.UpsertReadingProgress(database.MySQLDB, progress))
+		}
+	}
+	services.ResetCollaborative()
+
+	w := doRequest(router, "GET", fmt.Sprintf("/books/%d/similar?limit=5", threeBody.ID), "", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		Similar []services.Recommendation `json:"similar"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	// 内容相似的银河帝国和共同读者读过的万历十五年，不包含自身、无关书籍和不可见的书
+	assert.ElementsMatch(t, []string{"银河帝国", "万历十五年"}, recommendedTitles(resp.Similar))
+
+	w = doRequest(router, "GET", fmt.Sprintf("/books/%d/similar", private.ID), "", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// 个性化推荐中协同过滤把万历十五年排在无关的傲慢与偏见之前
+	reader := createUserWithRole(t, "reader", models.RoleReader)
+	token := tokenFor(t, reader)
+	w = doRequest(router, "PUT", fmt.Sprintf("/books/%d/progress", threeBody.ID), token, gin.H{"percentage": 50})
+	require.Equal(t, http.StatusOK, w.Code)
+	services.ResetCollaborative()
+	titles := recommendedTitles(getRecommendations(t, router, token, ""))
+	assert.Equal(t, []string{"银河帝国", "万历十五年", "傲慢与偏见"}, titles)
+}
+
+func TestRecommendationExplanations(t *testing.T) {
+	router := setupTestEnv(t)
+	owner := createUserWithRole(t, "owner", models.RoleUploader)
+	threeBody := createTaggedBook(t, "三体", owner, `["科幻","太空"]`, "文明与宇宙社会学")
+	createTaggedBook(t, "银河帝国", owner, `["科幻","太空"]`, "银河帝国的衰落")
+	require.NoError(t, services.InitRecommendIndex(database.MySQLDB))
+
+	// 旧接口在书籍少于 limit 时返回全部书籍
+	w := doRequest(router, "POST", "/books/recommend", "", gin.H{
+		"user_books": []gin.H{{"tags": "科幻 太空"}},
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var legacy struct {
+		Recommendations []services.Recommendation `json:"recommendations"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &legacy))
+	require.Len(t, legacy.Recommendations, 2)
+	terms := legacy.Recommendations[0].Explanation.Terms
+	require.Len(t, terms, 2)
+	assert.ElementsMatch(t, []string{"科幻", "太空"}, []string{terms[0].Term, terms[1].Term})
+	assert.Equal(t, "Matches your interests: "+terms[0].Term+", "+terms[1].Term, legacy.Recommendations[0].Explanation.Reason)
+
+	w = doRequest(router, "POST", "/books/recommend", "", gin.H{"user_books": []gin.H{{"tags": "科幻"}}, "limit": 1})
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &legacy))
+	assert.Len(t, legacy.Recommendations, 1)
+	w = doRequest(router, "POST", "/books/recommend", "", gin.H{"user_books": []gin.H{{"tags": "科幻"}}, "limit": 100})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// 个性化推荐说明是因为读过哪本书
+	reader := createUserWithRole(t, "reader", models.RoleReader)
+	token := tokenFor(t, reader)
+	w = doRequest(router, "PUT", fmt.Sprintf("/books/%d/progress", threeBody.ID), token, gin.H{"percentage": 30})
+	require.Equal(t, http.StatusOK, w.Code)
+	recommendations := getRecommendations(t, router, token, "")
+	require.Len(t, recommendations, 1)
+	explanation := recommendations[0].Explanation
+	require.Len(t, explanation.BecauseYouRead, 1)
+	assert.Equal(t, threeBody.ID, explanation.BecauseYouRead[0].BookID)
+	assert.Equal(t, "三体", explanation.BecauseYouRead[0].Title)
+	assert.Equal(t, "Because you read 三体", explanation.Reason)
+	assert.NotEmpty(t, explanation.Terms)
+
+	w = doRequest(router, "GET", fmt.Sprintf("/books/%d/similar", threeBody.ID), "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Similar to 三体")
+
+	// 读过的书设为私有后，说明中不再出现这本书
+	require.NoError(t, threeBody.SetVisibility(models.VisibilityPrivate))
+	require.NoError(t, models.UpdateBook(database.MySQLDB, threeBody))
+	recommendations = getRecommendations(t, router, token, "")
+	require.Len(t, recommendations, 1)
+	assert.Empty(t, recommendations[0].Explanation.BecauseYouRead)
+	assert.NotContains(t, recommendations[0].Explanation.Reason, "三体")
+}
+
+func TestRecommendationDiversity(t *testing.T) {
+	router := setupTestEnv(t)
+	owner := createUserWithRole(t, "owner", models.RoleUploader)
+	for i, author := range []string{"刘慈欣", "刘慈欣", "刘慈欣", "刘慈欣", "阿西莫夫"} {
+		book := createTaggedBook(t, fmt.Sprintf("科幻%d", i), owner, `["科幻"]`, "宇宙")
+		book.Author = author
+		require.NoError(t, models.UpdateBook(database.MySQLDB, book))
+	}
+	require.NoError(t, services.InitRecommendIndex(database.MySQLDB))
+
+	// 五本书相关度相同，不做多样化时前两名都是刘慈欣的书（ID较小），多样化后阿西莫夫的书进入前两名
+	w := doRequest(router, "POST", "/books/recommend", "", gin.H{"user_books": []gin.H{{"tags": "科幻", "description": "宇宙"}}, "limit": 2})
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Recommendations []services.Recommendation `json:"recommendations"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Recommendations, 2)
+	assert.Equal(t, "刘慈欣", resp.Recommendations[0].Book.Author)
+	assert.Equal(t, "阿西莫夫", resp.Recommendations[1].Book.Author)
+}
+
+func TestRecommendationsSkipInvisibleCandidates(t *testing.T) {
+	router := setupTestEnv(t)
+	owner := createUserWithRole(t, "owner", models.RoleUploader)
+	// 相关度最高的书都是私密的，需要扩大候选范围才能找到公开的书
+	for i := 0; i < 12; i++ {
+		private := createTaggedBook(t, fmt.Sprintf("私藏%d", i), owner, `["科幻","太空"]`, "宇宙")
+		require.NoError(t, private.SetVisibility(models.VisibilityPrivate))
+		require.NoError(t, models.UpdateBook(database.MySQLDB, private))
+	}
+	// 无关的书ID较小，只靠补足时会先选到它
+	createTaggedBook(t, "红楼梦", owner, `["经典"]`, "家族的兴衰")
+	createTaggedBook(t, "流浪地球", owner, `["科幻"]`, "带着地球去流浪")
+	require.NoError(t, services.InitRecommendIndex(database.MySQLDB))
+
+	w := doRequest(router, "POST", "/books/recommend", "", gin.H{"user_books": []gin.H{{"tags": "科幻 太空", "description": "宇宙"}}, "limit": 1})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		Recommendations []services.Recommendation `json:"recommendations"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, []string{"流浪地球"}, recommendedTitles(resp.Recommendations))
+
+	// 相关的书不足时用其他公开书籍补足，不包含私密的书
+	w = doRequest(router, "POST", "/books/recommend", "", gin.H{"user_books": []gin.H{{"tags": "科幻 太空", "description": "宇宙"}}, "limit": 5})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, []string{"流浪地球", "红楼梦"}, recommendedTitles(resp.Recommendations))
+	assert.Zero(t, resp.Recommendations[1].Score)
+	assert.Equal(t, "You might also like", resp.Recommendations[1].Explanation.Reason)
+}
@@ -0,0 +1,450 @@
+package routes_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/sd0ric4/book-reader-backend/app/config"
+	"github.com/sd0ric4/book-reader-backend/app/database"
+	"github.com/sd0ric4/book-reader-backend/app/models"
+	"github.com/sd0ric4/book-reader-backend/app/routes"
+	"github.com/sd0ric4/book-reader-backend/app/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestEnv(t *testing.T) *gin.Engine {
+	var err error
+	database.MySQLDB, err = gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	// 内存数据库每个连接都是独立的库，并发请求必须共用同一个连接
+	sqlDB, err := database.MySQLDB.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	models.Migrate(database.MySQLDB)
+
+	mr := miniredis.RunT(t)
+	database.RedisDB = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+
+	config.Config = &config.ConfigStruct{
+		JWT: config.JWTConfig{Secret: "test-secret", Expire: 900, RefreshExpire: 3600},
+	}
+	require.NoError(t, services.InitSearchIndex(database.MySQLDB, ""))
+	require.NoError(t, services.InitRecommendIndex(database.MySQLDB))
+	services.ResetCollaborative()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	routes.SetupRoutes(router)
+	return router
+}
+
+func createUser(t *testing.T, username string) *models.User {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	user := &models.User{
+		Username: username,
+		Email:    username + "@example.com",
+		Password: string(hashedPassword),
+	}
+	require.NoError(t, database.MySQLDB.Create(user).Error)
+	return user
+}
+
+func doRequest(router *gin.Engine, method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	var payload []byte
+	if body != nil {
+		payload, _ = json.Marshal(body)
+	}
+	req, _ := http.NewRequest(method, path, bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+type loginResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+func login(t *testing.T, router *gin.Engine, username string) loginResponse {
+	w := doRequest(router, "POST", "/users/login", "", gin.H{"username": username, "password": "password123"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp loginResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp
+}
+
+func TestSessionLifecycle(t *testing.T) {
+	router := setupTestEnv(t)
+	createUser(t, "reader")
+
+	first := login(t, router, "reader")
+	assert.Equal(t, int64(900), first.ExpiresIn)
+	assert.NotEmpty(t, first.RefreshToken)
+
+	second := login(t, router, "reader")
+
+	// 两个设备都应出现在会话列表中
+	w := doRequest(router, "GET", "/users/sessions", first.Token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var sessions []map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &sessions))
+	assert.Len(t, sessions, 2)
+
+	// 刷新令牌会被轮换
+	w = doRequest(router, "POST", "/users/refresh", "", gin.H{"refresh_token": first.RefreshToken})
+	require.Equal(t, http.StatusOK, w.Code)
+	var refreshed loginResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &refreshed))
+	assert.NotEqual(t, first.RefreshToken, refreshed.RefreshToken)
+
+	// 旧刷新令牌被重复使用时吊销整个会话
+	w = doRequest(router, "POST", "/users/refresh", "", gin.H{"refresh_token": first.RefreshToken})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = doRequest(router, "POST", "/users/refresh", "", gin.H{"refresh_token": refreshed.RefreshToken})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = doRequest(router, "GET", "/users/sessions", refreshed.Token, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// 另一个设备不受影响，注销后令牌失效
+	w = doRequest(router, "POST", "/users/logout", second.Token, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = doRequest(router, "GET", "/users/sessions", second.Token, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestLogoutAll(t *testing.T) {
+	router := setupTestEnv(t)
+	createUser(t, "reader")
+
+	phone := login(t, router, "reader")
+	ereader := login(t, router, "reader")
+
+	w := doRequest(router, "POST", "/users/logout-all", phone.Token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	for _, tokens := range []loginResponse{phone, ereader} {
+		w = doRequest(router, "GET", "/users/sessions", tokens.Token, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		w = doRequest(router, "POST", "/users/refresh", "", gin.H{"refresh_token": tokens.RefreshToken})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	}
+}
+
+func tokenFor(t *testing.T, user *models.User) string {
+	tokens, err := services.CreateSession(context.Background(), user.ID, "test", "127.0.0.1")
+	require.NoError(t, err)
+	return tokens.AccessToken
+}
+
+func createUserWithRole(t *testing.T, username, role string) *models.User {
+	user := createUser(t, username)
+	require.NoError(t, models.UpdateUserRole(database.MySQLDB, user.ID, role))
+	user.Role = role
+	return user
+}
+
+func TestRegisterCannotChooseRole(t *testing.T) {
+	router := setupTestEnv(t)
+
+	for _, username := range []string{"first", "second"} {
+		w := doRequest(router, "POST", "/users/register", "", gin.H{
+			"username": username,
+			"email":    username + "@example.com",
+			"password": "password123",
+			"role":     models.RoleAdmin,
+		})
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	// 包括第一个注册的用户在内都是普通读者，管理员只能通过启动参数 -admin 指定
+	for _, email := range []string{"first@example.com", "second@example.com"} {
+		user, err := models.GetUserByEmail(database.MySQLDB, email)
+		require.NoError(t, err)
+		assert.Equal(t, models.RoleReader, user.Role)
+	}
+}
+
+func TestAdminUsersOmitPassword(t *testing.T) {
+	router := setupTestEnv(t)
+	admin := createUserWithRole(t, "admin", models.RoleAdmin)
+	createUser(t, "reader")
+
+	w := doRequest(router, "GET", "/admin/users", tokenFor(t, admin), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var users []map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &users))
+	require.Len(t, users, 2)
+	for _, user := range users {
+		assert.NotContains(t, user, "password")
+		assert.NotEmpty(t, user["username"])
+	}
+}
+
+func TestRoutePolicies(t *testing.T) {
+	router := setupTestEnv(t)
+
+	reader := createUserWithRole(t, "reader", models.RoleReader)
+	owner := createUserWithRole(t, "owner", models.RoleUploader)
+	otherUploader := createUserWithRole(t, "uploader", models.RoleUploader)
+	admin := createUserWithRole(t, "admin", models.RoleAdmin)
+
+	tokens := map[string]string{
+		"anonymous": "",
+		"reader":    tokenFor(t, reader),
+		"owner":     tokenFor(t, owner),
+		"uploader":  tokenFor(t, otherUploader),
+		"admin":     tokenFor(t, admin),
+	}
+
+	newBook := func() string {
+		book := &models.Book{Title: "Book", Author: "Author", BookURL: "http://example.com/book.epub", Tags: `[]`, OwnerID: &owner.ID}
+		require.NoError(t, models.CreateBook(database.MySQLDB, book))
+		return fmt.Sprintf("/books/%d", book.ID)
+	}
+
+	testCases := []struct {
+		name       string
+		method     string
+		path       func() string
+		body       interface{}
+		expectCode map[string]int
+	}{
+		{
+			name:   "Update book",
+			method: "PUT",
+			path:   newBook,
+			body:   gin.H{"title": "New title"},
+			expectCode: map[string]int{
+				"anonymous": http.StatusUnauthorized,
+				"reader":    http.StatusForbidden,
+				"uploader":  http.StatusForbidden,
+				"owner":     http.StatusOK,
+				"admin":     http.StatusOK,
+			},
+		},
+		{
+			name:   "Delete book",
+			method: "DELETE",
+			path:   newBook,
+			expectCode: map[string]int{
+				"anonymous": http.StatusUnauthorized,
+				"reader":    http.StatusForbidden,
+				"uploader":  http.StatusForbidden,
+				"owner":     http.StatusOK,
+				"admin":     http.StatusOK,
+			},
+		},
+		{
+			// 通过权限检查后因缺少表单字段返回 400
+			name:   "Upload book",
+			method: "POST",
+			path:   func() string { return "/books/upload" },
+			expectCode: map[string]int{
+				"anonymous": http.StatusUnauthorized,
+				"reader":    http.StatusForbidden,
+				"uploader":  http.StatusBadRequest,
+				"admin":     http.StatusBadRequest,
+			},
+		},
+		{
+			name:   "Summarize book",
+			method: "POST",
+			path:   func() string { return "/books/summarize" },
+			expectCode: map[string]int{
+				"anonymous": http.StatusUnauthorized,
+			},
+		},
+		{
+			name:   "Change password",
+			method: "PUT",
+			path:   func() string { return "/users/change-password" },
+			body:   gin.H{"old_password": "wrong-password", "new_password": "password456"},
+			expectCode: map[string]int{
+				"anonymous": http.StatusUnauthorized,
+				"reader":    http.StatusUnauthorized,
+			},
+		},
+		{
+			name:   "List users",
+			method: "GET",
+			path:   func() string { return "/admin/users" },
+			expectCode: map[string]int{
+				"anonymous": http.StatusUnauthorized,
+				"reader":    http.StatusForbidden,
+				"uploader":  http.StatusForbidden,
+				"admin":     http.StatusOK,
+			},
+		},
+		{
+			name:   "Update user role",
+			method: "PUT",
+			path:   func() string { return fmt.Sprintf("/admin/users/%d/role", reader.ID) },
+			body:   gin.H{"role": models.RoleUploader},
+			expectCode: map[string]int{
+				"anonymous": http.StatusUnauthorized,
+				"reader":    http.StatusForbidden,
+				"owner":     http.StatusForbidden,
+				"admin":     http.StatusOK,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		for caller, expectCode := range tc.expectCode {
+			t.Run(tc.name+"/"+caller, func(t *testing.T) {
+				w := doRequest(router, tc.method, tc.path(), tokens[caller], tc.body)
+				assert.Equal(t, expectCode, w.Code, w.Body.String())
+			})
+		}
+	}
+}
+
+func TestBookVisibility(t *testing.T) {
+	router := setupTestEnv(t)
+
+	alice := createUserWithRole(t, "alice", models.RoleUploader)
+	bob := createUserWithRole(t, "bob", models.RoleUploader)
+	admin := createUserWithRole(t, "admin", models.RoleAdmin)
+
+	createBook := func(title, visibility string, owner *models.User) *models.Book {
+		book := &models.Book{Title: title, Author: "Author", BookURL: "http://example.com/" + title, Tags: `[]`, OwnerID: &owner.ID}
+		require.NoError(t, book.SetVisibility(visibility))
+		require.NoError(t, models.CreateBook(database.MySQLDB, book))
+		return book
+	}
+	public := createBook("public", models.VisibilityPublic, alice)
+	private := createBook("private", models.VisibilityPrivate, alice)
+	shared := createBook("shared", models.VisibilityLink, alice)
+	require.NotEmpty(t, shared.ShareToken)
+
+	listTitles := func(token string) []string {
+		w := doRequest(router, "GET", "/books/list", token, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var page models.BookPage
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		titles := make([]string, 0, len(page.Items))
+		for _, book := range page.Items {
+			titles = append(titles, book.Title)
+		}
+		return titles
+	}
+
+	assert.ElementsMatch(t, []string{"public"}, listTitles(""))
+	assert.ElementsMatch(t, []string{"public"}, listTitles(tokenFor(t, bob)))
+	assert.ElementsMatch(t, []string{"public", "private", "shared"}, listTitles(tokenFor(t, alice)))
+	assert.ElementsMatch(t, []string{"public", "private", "shared"}, listTitles(tokenFor(t, admin)))
+
+	bobToken := tokenFor(t, bob)
+	testCases := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		body       interface{}
+		expectCode int
+	}{
+		{"Anonymous reads public book", "GET", fmt.Sprintf("/books/%d", public.ID), "", nil, http.StatusOK},
+		{"Anonymous cannot read private book", "GET", fmt.Sprintf("/books/%d", private.ID), "", nil, http.StatusNotFound},
+		{"Other user cannot read private book", "GET", fmt.Sprintf("/books/%d", private.ID), bobToken, nil, http.StatusNotFound},
+		{"Owner reads private book", "GET", fmt.Sprintf("/books/%d", private.ID), tokenFor(t, alice), nil, http.StatusOK},
+		{"Link book needs token", "GET", fmt.Sprintf("/books/%d", shared.ID), bobToken, nil, http.StatusNotFound},
+		{"Link book with wrong token", "GET", fmt.Sprintf("/books/%d?share_token=wrong", shared.ID), "", nil, http.StatusNotFound},
+		{"Link book with token", "GET", fmt.Sprintf("/books/%d?share_token=%s", shared.ID, shared.ShareToken), "", nil, http.StatusOK},
+		{"Other user cannot see private book to update it", "PUT", fmt.Sprintf("/books/%d", private.ID), bobToken, gin.H{"title": "x"}, http.StatusNotFound},
+		{"Other user cannot see private book to delete it", "DELETE", fmt.Sprintf("/books/%d", private.ID), bobToken, nil, http.StatusNotFound},
+		{"Invalid visibility", "PUT", fmt.Sprintf("/books/%d", public.ID), tokenFor(t, alice), gin.H{"visibility": "secret"}, http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := doRequest(router, tc.method, tc.path, tc.token, tc.body)
+			assert.Equal(t, tc.expectCode, w.Code, w.Body.String())
+		})
+	}
+
+	// 取消分享后旧链接失效
+	w := doRequest(router, "PUT", fmt.Sprintf("/books/%d", shared.ID), tokenFor(t, alice), gin.H{"visibility": models.VisibilityPrivate})
+	require.Equal(t, http.StatusOK, w.Code)
+	w = doRequest(router, "GET", fmt.Sprintf("/books/%d?share_token=%s", shared.ID, shared.ShareToken), "", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestDeleteBookCascade(t *testing.T) {
+	router := setupTestEnv(t)
+	owner := createUserWithRole(t, "owner", models.RoleUploader)
+	reader := createUserWithRole(t, "reader", models.RoleReader)
+	book, chapters := createBookWithChapters(t, "book", owner, "第一章的内容", "第二章的内容")
+	db := database.MySQLDB
+
+	require.NoError(t, models.UpsertReadingProgress(db, &models.ReadingProgress{UserID: reader.ID, BookID: book.ID, Percentage: 50}))
+	require.NoError(t, models.AddFavorite(db, reader.ID, book.ID))
+	require.NoError(t, models.UpsertBookRating(db, &models.BookRating{UserID: reader.ID, BookID: book.ID, Rating: 4}))
+	require.NoError(t, models.CreateBookmark(db, &models.Bookmark{UserID: reader.ID, BookID: book.ID, TextAnchor: models.TextAnchor{ChapterID: chapters[0].ID}}))
+	require.NoError(t, models.UpsertChapterSummary(db, &models.ChapterSummary{BookID: book.ID, ChapterID: chapters[0].ID, Summary: "摘要"}))
+	reviewBookID := uint64(book.ID)
+	require.NoError(t, models.CreateReview(db, &models.Review{BookID: &reviewBookID, Title: book.Title, Language: "zh"}))
+
+	counts := func() map[string]int64 {
+		result := make(map[string]int64)
+		for name, model := range map[string]interface{}{
+			"books":             &models.Book{},
+			"chapters":          &models.BookChapter{},
+			"progress":          &models.ReadingProgress{},
+			"favorites":         &models.FavoriteBook{},
+			"ratings":           &models.BookRating{},
+			"bookmarks":         &models.Bookmark{},
+			"chapter_summaries": &models.ChapterSummary{},
+			"reviews":           &models.Review{},
+		} {
+			column := "book_id"
+			if name == "books" {
+				column = "id"
+			}
+			var count int64
+			require.NoError(t, db.Model(model).Where(column+" = ?", book.ID).Count(&count).Error)
+			result[name] = count
+		}
+		return result
+	}
+	before := counts()
+	for name, count := range before {
+		require.NotZero(t, count, name)
+	}
+
+	// 中途失败时整个删除回滚
+	require.NoError(t, db.Migrator().DropTable(&models.Review{}))
+	w := doRequest(router, "DELETE", fmt.Sprintf("/books/%d", book.ID), tokenFor(t, owner), nil)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	require.NoError(t, db.AutoMigrate(&models.Review{}))
+	after := counts()
+	delete(before, "reviews")
+	delete(after, "reviews")
+	assert.Equal(t, before, after)
+
+	w = doRequest(router, "DELETE", fmt.Sprintf("/books/%d", book.ID), tokenFor(t, owner), nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	for name, count := range counts() {
+		assert.Zero(t, count, name)
+	}
+}
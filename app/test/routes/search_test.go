@@ -0,0 +1,146 @@
+package routes_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sd0ric4/book-reader-backend/app/database"
+	"github.com/sd0ric4/book-reader-backend/app/models"
+	"github.com/sd0ric4/book-reader-backend/app/services"
+	"github.com/sd0ric4/book-reader-backend/app/services/search"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type searchResponse struct {
+	Total int                 `json:"total"`
+	Items []search.BookResult `json:"items"`
+}
+
+func searchBooks(t *testing.T, router *gin.Engine, query, token string) searchResponse {
+	w := doRequest(router, "GET", "/search?q="+url.QueryEscape(query), token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp searchResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp
+}
+
+func TestSearch(t *testing.T) {
+	router := setupTestEnv(t)
+	owner := createUserWithRole(t, "owner", models.RoleUploader)
+	ownerToken := tokenFor(t, owner)
+
+	public, chapters := createBookWithChapters(t, "呐喊", owner, "我家门前有两株树，一株是枣树，还有一株也是枣树。")
+	private, _ := createBookWithChapters(t, "彷徨", owner, "祥林嫂的枣树故事。")
+	require.NoError(t, private.SetVisibility(models.VisibilityPrivate))
+	require.NoError(t, models.UpdateBook(database.MySQLDB, private))
+	updated, err := services.SyncSearchIndex(database.MySQLDB, false)
+	require.NoError(t, err)
+	assert.Equal(t, 2, updated)
+
+	w := doRequest(router, "GET", "/search", "", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// 匿名用户只能搜到公开书籍
+	resp := searchBooks(t, router, "枣树", "")
+	require.Equal(t, 1, resp.Total)
+	assert.Equal(t, public.ID, resp.Items[0].Book.ID)
+	match := resp.Items[0].Matches[0]
+	assert.Equal(t, chapters[0].ID, match.ChapterID)
+	assert.Equal(t, "第1章", match.ChapterName)
+	assert.Contains(t, match.Snippet, "<mark>枣树</mark>")
+
+	resp = searchBooks(t, router, "枣树", ownerToken)
+	assert.Equal(t, 2, resp.Total)
+
+	// 修改书籍后索引随之更新
+	w = doRequest(router, "PUT", fmt.Sprintf("/books/%d", public.ID), ownerToken, gin.H{"description": "鲁迅的小说集"})
+	require.Equal(t, http.StatusOK, w.Code)
+	resp = searchBooks(t, router, "鲁迅", "")
+	require.Equal(t, 1, resp.Total)
+	assert.Equal(t, "description", resp.Items[0].Matches[0].Field)
+
+	// 删除后搜不到
+	w = doRequest(router, "DELETE", fmt.Sprintf("/books/%d", public.ID), ownerToken, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	resp = searchBooks(t, router, "枣树", "")
+	assert.Zero(t, resp.Total)
+
+	// 只有管理员可以重建索引
+	w = doRequest(router, "POST", "/admin/search/reindex", ownerToken, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	admin := createUserWithRole(t, "admin", models.RoleAdmin)
+	w = doRequest(router, "POST", "/admin/search/reindex", tokenFor(t, admin), nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	resp = searchBooks(t, router, "祥林嫂", tokenFor(t, admin))
+	assert.Equal(t, 1, resp.Total)
+}
+
+type findResponse struct {
+	Total     int                  `json:"total"`
+	Truncated bool                 `json:"truncated"`
+	Matches   []services.FindMatch `json:"matches"`
+}
+
+func findInBook(t *testing.T, router *gin.Engine, bookID uint, query string) findResponse {
+	w := doRequest(router, "GET", fmt.Sprintf("/books/%d/search?%s", bookID, query), "", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp findResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp
+}
+
+func TestFindInBook(t *testing.T) {
+	router := setupTestEnv(t)
+	owner := createUserWithRole(t, "owner", models.RoleUploader)
+	book, chapters := createBookWithChapters(t, "测试", owner,
+		"孔乙己是站着喝酒而穿长衫的唯一的人。Cat and cat.",
+		"他对人说话，总是满口之乎者也。Category: catalog, CAT!",
+	)
+
+	// 默认不区分大小写，偏移按字符计算
+	resp := findInBook(t, router, book.ID, "q=cat")
+	require.Equal(t, 5, resp.Total)
+	first := resp.Matches[0]
+	assert.Equal(t, chapters[0].ID, first.ChapterID)
+	assert.Equal(t, "第1章", first.ChapterName)
+	assert.Equal(t, 18, first.Offset)
+	assert.Equal(t, "Cat", first.Text)
+	assert.Equal(t, "孔乙己是站着喝酒而穿长衫的唯一的人。", first.Before)
+	assert.Equal(t, " and cat.", first.After)
+	assert.Equal(t, chapters[1].ID, resp.Matches[4].ChapterID)
+
+	resp = findInBook(t, router, book.ID, "q=cat&case_sensitive=true")
+	assert.Equal(t, 2, resp.Total)
+
+	// 全词匹配不匹配 Category 和 catalog，中文不受词边界限制
+	resp = findInBook(t, router, book.ID, "q=cat&mode=word")
+	assert.Equal(t, 3, resp.Total)
+	resp = findInBook(t, router, book.ID, "q="+url.QueryEscape("之乎")+"&mode=word")
+	assert.Equal(t, 1, resp.Total)
+
+	resp = findInBook(t, router, book.ID, "q="+url.QueryEscape(`cat\w+`)+"&mode=regex")
+	require.Equal(t, 2, resp.Total)
+	assert.Equal(t, "Category", resp.Matches[0].Text)
+
+	// 超过 limit 的匹配只计数
+	resp = findInBook(t, router, book.ID, "q=cat&limit=2")
+	assert.Equal(t, 5, resp.Total)
+	assert.Len(t, resp.Matches, 2)
+	assert.True(t, resp.Truncated)
+
+	w := doRequest(router, "GET", fmt.Sprintf("/books/%d/search?q=%s&mode=regex", book.ID, url.QueryEscape("(")), "", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = doRequest(router, "GET", fmt.Sprintf("/books/%d/search?q=cat&mode=fuzzy", book.ID), "", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// 私有书籍对其他人不可见
+	require.NoError(t, book.SetVisibility(models.VisibilityPrivate))
+	require.NoError(t, models.UpdateBook(database.MySQLDB, book))
+	w = doRequest(router, "GET", fmt.Sprintf("/books/%d/search?q=cat", book.ID), "", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,194 @@
+package routes_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sd0ric4/book-reader-backend/app/database"
+	"github.com/sd0ric4/book-reader-backend/app/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeBookIDs(t *testing.T, body []byte) []uint {
+	var page models.BookPage
+	require.NoError(t, json.Unmarshal(body, &page))
+	ids := make([]uint, len(page.Items))
+	for i, book := range page.Items {
+		ids[i] = book.ID
+	}
+	return ids
+}
+
+func TestFavorites(t *testing.T) {
+	router := setupTestEnv(t)
+	owner := createUserWithRole(t, "owner", models.RoleUploader)
+	reader := createUserWithRole(t, "reader", models.RoleReader)
+	token := tokenFor(t, reader)
+
+	first, _ := createBookWithChapters(t, "first", owner)
+	second, _ := createBookWithChapters(t, "second", owner)
+	private, _ := createBookWithChapters(t, "private", owner)
+	require.NoError(t, private.SetVisibility(models.VisibilityPrivate))
+	require.NoError(t, models.UpdateBook(database.MySQLDB, private))
+
+	w := doRequest(router, "POST", fmt.Sprintf("/books/%d/favorite", private.ID), token, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// 后上传的书先收藏，列表按收藏时间而不是上传时间倒序
+	for _, book := range []*models.Book{second, first, second} {
+		w = doRequest(router, "POST", fmt.Sprintf("/books/%d/favorite", book.ID), token, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+
+	w = doRequest(router, "GET", "/users/me/favorites", token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []uint{first.ID, second.ID}, decodeBookIDs(t, w.Body.Bytes()))
+
+	w = doRequest(router, "DELETE", fmt.Sprintf("/books/%d/favorite", first.ID), token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	w = doRequest(router, "GET", "/users/me/favorites", token, nil)
+	assert.Equal(t, []uint{second.ID}, decodeBookIDs(t, w.Body.Bytes()))
+}
+
+func TestShelves(t *testing.T) {
+	router := setupTestEnv(t)
+	owner := createUserWithRole(t, "owner", models.RoleUploader)
+	reader := createUserWithRole(t, "reader", models.RoleReader)
+	other := createUserWithRole(t, "other", models.RoleReader)
+	token := tokenFor(t, reader)
+	otherToken := tokenFor(t, other)
+
+	// 第一次查看时创建默认书架
+	w := doRequest(router, "GET", "/shelves", token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var shelves []models.Shelf
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &shelves))
+	require.Len(t, shelves, len(models.DefaultShelfNames))
+	assert.Equal(t, "To read", shelves[0].Name)
+
+	w = doRequest(router, "POST", "/shelves", token, gin.H{"name": "Sci-fi"})
+	require.Equal(t, http.StatusOK, w.Code)
+	var shelf models.Shelf
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &shelf))
+	assert.Equal(t, 3, shelf.Position)
+
+	// 移动到最前面
+	w = doRequest(router, "PUT", fmt.Sprintf("/shelves/%d", shelf.ID), token, gin.H{"position": 0})
+	require.Equal(t, http.StatusOK, w.Code)
+	w = doRequest(router, "GET", "/shelves", token, nil)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &shelves))
+	assert.Equal(t, "Sci-fi", shelves[0].Name)
+	assert.Equal(t, "To read", shelves[1].Name)
+
+	a, _ := createBookWithChapters(t, "a", owner)
+	b, _ := createBookWithChapters(t, "b", owner)
+	c, _ := createBookWithChapters(t, "c", owner)
+	booksPath := fmt.Sprintf("/shelves/%d/books", shelf.ID)
+	for _, book := range []*models.Book{a, b} {
+		w = doRequest(router, "POST", booksPath, token, gin.H{"book_id": book.ID})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+	w = doRequest(router, "POST", booksPath, token, gin.H{"book_id": c.ID, "position": 0})
+	require.Equal(t, http.StatusOK, w.Code)
+	w = doRequest(router, "POST", booksPath, token, gin.H{"book_id": a.ID})
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = doRequest(router, "GET", booksPath, token, nil)
+	assert.Equal(t, []uint{c.ID, a.ID, b.ID}, decodeBookIDs(t, w.Body.Bytes()))
+
+	// 按书架内顺序的游标分页，也可以按其他字段排序
+	w = doRequest(router, "GET", booksPath+"?page_size=2", token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var page models.BookPage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.EqualValues(t, 3, page.Total)
+	require.NotEmpty(t, page.NextCursor)
+	assert.Equal(t, []uint{c.ID, a.ID}, decodeBookIDs(t, w.Body.Bytes()))
+	w = doRequest(router, "GET", booksPath+"?page_size=2&cursor="+page.NextCursor, token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, []uint{b.ID}, decodeBookIDs(t, w.Body.Bytes()))
+	w = doRequest(router, "GET", booksPath+"?sort=title&order=desc", token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, []uint{c.ID, b.ID, a.ID}, decodeBookIDs(t, w.Body.Bytes()))
+	w = doRequest(router, "GET", "/books/list?sort=position", token, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// 书架内调整顺序
+	w = doRequest(router, "PUT", fmt.Sprintf("%s/%d", booksPath, c.ID), token, gin.H{"position": 2})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = doRequest(router, "GET", booksPath, token, nil)
+	assert.Equal(t, []uint{a.ID, b.ID, c.ID}, decodeBookIDs(t, w.Body.Bytes()))
+
+	// 移动到另一个书架
+	toRead := shelves[1]
+	w = doRequest(router, "PUT", fmt.Sprintf("%s/%d", booksPath, a.ID), token, gin.H{"shelf_id": toRead.ID})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = doRequest(router, "GET", booksPath, token, nil)
+	assert.Equal(t, []uint{b.ID, c.ID}, decodeBookIDs(t, w.Body.Bytes()))
+	w = doRequest(router, "GET", fmt.Sprintf("/shelves/%d/books", toRead.ID), token, nil)
+	assert.Equal(t, []uint{a.ID}, decodeBookIDs(t, w.Body.Bytes()))
+
+	// 其他用户无法访问或移入自己的书架
+	w = doRequest(router, "GET", booksPath, otherToken, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = doRequest(router, "GET", "/shelves", otherToken, nil)
+	var otherShelves []models.Shelf
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &otherShelves))
+	w = doRequest(router, "PUT", fmt.Sprintf("%s/%d", booksPath, b.ID), token, gin.H{"shelf_id": otherShelves[0].ID})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = doRequest(router, "DELETE", fmt.Sprintf("%s/%d", booksPath, b.ID), token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	w = doRequest(router, "DELETE", fmt.Sprintf("%s/%d", booksPath, b.ID), token, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = doRequest(router, "DELETE", fmt.Sprintf("/shelves/%d", shelf.ID), token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var count int64
+	database.MySQLDB.Model(&models.ShelfItem{}).Where("shelf_id = ?", shelf.ID).Count(&count)
+	assert.Zero(t, count)
+}
+
+func TestSharedShelf(t *testing.T) {
+	router := setupTestEnv(t)
+	owner := createUserWithRole(t, "owner", models.RoleUploader)
+	token := tokenFor(t, owner)
+
+	public, _ := createBookWithChapters(t, "public", owner)
+	private, _ := createBookWithChapters(t, "private", owner)
+	require.NoError(t, private.SetVisibility(models.VisibilityPrivate))
+	require.NoError(t, models.UpdateBook(database.MySQLDB, private))
+
+	w := doRequest(router, "POST", "/shelves", token, gin.H{"name": "Mine"})
+	var shelf models.Shelf
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &shelf))
+	for _, book := range []*models.Book{private, public} {
+		w = doRequest(router, "POST", fmt.Sprintf("/shelves/%d/books", shelf.ID), token, gin.H{"book_id": book.ID})
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	w = doRequest(router, "POST", fmt.Sprintf("/shelves/%d/share", shelf.ID), token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &shelf))
+	require.NotEmpty(t, shelf.ShareToken)
+
+	// 匿名访问只能看到公开书籍
+	w = doRequest(router, "GET", "/shared/shelves/"+shelf.ShareToken, "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var shared struct {
+		Books models.BookPage `json:"books"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &shared))
+	assert.EqualValues(t, 1, shared.Books.Total)
+	require.Len(t, shared.Books.Items, 1)
+	assert.Equal(t, public.ID, shared.Books.Items[0].ID)
+
+	w = doRequest(router, "DELETE", fmt.Sprintf("/shelves/%d/share", shelf.ID), token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	w = doRequest(router, "GET", "/shared/shelves/"+shelf.ShareToken, "", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
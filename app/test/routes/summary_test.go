@@ -0,0 +1,527 @@
+package routes_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sd0ric4/book-reader-backend/app/config"
+	"github.com/sd0ric4/book-reader-backend/app/database"
+	"github.com/sd0ric4/book-reader-backend/app/models"
+	"github.com/sd0ric4/book-reader-backend/app/services"
+	"github.com/sd0ric4/book-reader-backend/app/services/jobs"
+	"github.com/sd0ric4/book-reader-backend/app/services/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+const fakeReviewJSON = "```json\n" + `{
+    "title": "三体",
+    "author": "刘慈欣",
+    "characters": [{"name": "叶文洁", "role": "天体物理学家，向三体文明发出信号。"}],
+    "synopsis": "人类文明与三体文明的第一次接触。"
+}` + "\n```"
+
+// newFakeSummaryLLM 返回按提示词类型给出固定回复的假模型，修正输出时按最初的提示词回复
+func newFakeSummaryLLM(window int) *llm.Fake {
+	fake := llm.NewFake(func(req llm.Request) (string, error) {
+		prompt := req.Messages[0].Content
+		switch {
+		case strings.Contains(prompt, "JSON格式"), strings.Contains(prompt, "Output JSON"):
+			return fakeReviewJSON, nil
+		case strings.Contains(prompt, "合并为一段"):
+			return "合并概要", nil
+		default:
+			return "片段概要", nil
+		}
+	})
+	fake.Window = window
+	return fake
+}
+
+// startJobWorkers 启动后台任务 worker，测试结束时停止
+func startJobWorkers(t *testing.T, cfg config.JobsConfig) {
+	services.InitJobs(database.RedisDB, cfg)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		services.RunJobs(ctx, cfg)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		services.Jobs = nil
+	})
+}
+
+// waitForJob 轮询任务直到结束
+func waitForJob(t *testing.T, router *gin.Engine, token, id string) jobs.Job {
+	var job jobs.Job
+	require.Eventually(t, func() bool {
+		w := doRequest(router, "GET", "/jobs/"+id, token, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+		return !job.Active()
+	}, 5*time.Second, 20*time.Millisecond)
+	return job
+}
+
+// submitSummary 提交摘要任务并等待完成
+func submitSummary(t *testing.T, router *gin.Engine, token string, request interface{}) jobs.Job {
+	w := doRequest(router, "POST", "/books/summarize", token, request)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var job jobs.Job
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	assert.Equal(t, jobs.StatusQueued, job.Status)
+	return waitForJob(t, router, token, job.ID)
+}
+
+func TestSummarizeBook(t *testing.T) {
+	router := setupTestEnv(t)
+	startJobWorkers(t, config.JobsConfig{Workers: 1})
+	owner := createUserWithRole(t, "owner", models.RoleUploader)
+	token := tokenFor(t, owner)
+
+	book, chapters := createBookWithChapters(t, "三体", owner, "叶文洁在红岸基地工作。", strings.Repeat("三体舰队正在驶向地球。\n", 60), "")
+	request := map[string]string{"book_title": book.Title, "author": book.Author}
+
+	services.LLM = nil
+	defer func() { services.LLM = nil }()
+	w := doRequest(router, "POST", "/books/summarize", token, request)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	// 上下文窗口很小，第二章需要分段概括后再合并
+	fake := newFakeSummaryLLM(700)
+	services.LLM = fake
+	job := submitSummary(t, router, token, request)
+	require.Equal(t, jobs.StatusSucceeded, job.Status, job.Error)
+	assert.Equal(t, 100, job.Progress)
+
+	var resp services.BookSummary
+	require.NoError(t, json.Unmarshal(job.Result, &resp))
+	assert.Equal(t, "三体", resp.Review.Title)
+	assert.Equal(t, "Author", resp.Review.Author)
+	assert.Equal(t, "叶文洁", resp.Review.Characters[0].Name)
+	require.Len(t, resp.Chapters, 2)
+	assert.Equal(t, chapters[0].ID, resp.Chapters[0].ChapterID)
+	assert.Equal(t, "片段概要", resp.Chapters[0].Summary)
+	assert.Equal(t, "合并概要", resp.Chapters[1].Summary)
+	assert.Equal(t, llm.ProviderFake, resp.Chapters[1].Model)
+
+	// 全书摘要的提示词包含章节概要
+	requests := fake.Requests()
+	last := requests[len(requests)-1].Messages[0].Content
+	assert.Contains(t, last, "第1章\n片段概要\n\n第2章\n合并概要")
+	for _, req := range requests {
+		assert.LessOrEqual(t, llm.EstimateTokens(req.Messages[0].Content), 700)
+	}
+
+	saved, err := models.GetChapterSummaries(database.MySQLDB, book.ID)
+	require.NoError(t, err)
+	assert.Len(t, saved, 2)
+
+	// 已有摘要时直接返回，不再请求模型
+	count := len(fake.Requests())
+	w = doRequest(router, "POST", "/books/summarize", token, request)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Len(t, resp.Chapters, 2)
+	assert.Len(t, fake.Requests(), count)
+
+	// 章节内容未变化时复用章节概要，只重新生成全书摘要
+	_, err = services.SummarizeBook(context.Background(), database.MySQLDB, fake, book, nil)
+	require.NoError(t, err)
+	assert.Len(t, fake.Requests(), count+1)
+
+	// 章节内容变化后重新概括该章节
+	require.NoError(t, models.UpdateChapterContent(database.MySQLDB, chapters[0].ID, "叶文洁按下了发射按钮。"))
+	_, err = services.SummarizeBook(context.Background(), database.MySQLDB, fake, book, nil)
+	require.NoError(t, err)
+	assert.Len(t, fake.Requests(), count+3)
+
+	// 没有章节内容的书只根据书名和作者生成
+	empty, _ := createBookWithChapters(t, "球状闪电", owner, "")
+	_, err = services.SummarizeBook(context.Background(), database.MySQLDB, fake, empty, nil)
+	assert.ErrorIs(t, err, services.ErrNoChapterContent)
+
+	job = submitSummary(t, router, token, map[string]string{"book_title": "球状闪电", "author": "Author"})
+	require.Equal(t, jobs.StatusSucceeded, job.Status, job.Error)
+	var titleOnly services.BookSummary
+	require.NoError(t, json.Unmarshal(job.Result, &titleOnly))
+	assert.Equal(t, "球状闪电", titleOnly.Review.Title)
+	assert.Empty(t, titleOnly.Chapters)
+	assert.Contains(t, fake.Requests()[len(fake.Requests())-1].Messages[0].Content, "现在请总结 Author 的《球状闪电》")
+}
+
+func TestSummaryJobs(t *testing.T) {
+	router := setupTestEnv(t)
+	// 只执行一次，失败后立即结束而不是等待自动重试
+	startJobWorkers(t, config.JobsConfig{Workers: 1, MaxAttempts: 1})
+	owner := createUserWithRole(t, "owner", models.RoleUploader)
+	other := createUserWithRole(t, "other", models.RoleReader)
+	token := tokenFor(t, owner)
+
+	book, _ := createBookWithChapters(t, "三体", owner, "叶文洁在红岸基地工作。")
+	request := map[string]string{"book_title": book.Title, "author": book.Author}
+
+	// 模型不可用时任务失败
+	release := make(chan struct{})
+	var failing atomic.Bool
+	failing.Store(true)
+	fake := newFakeSummaryLLM(0)
+	reply := fake.Reply
+	fake.Reply = func(req llm.Request) (string, error) {
+		<-release
+		if failing.Load() {
+			return "", errors.New("model unavailable")
+		}
+		return reply(req)
+	}
+	services.LLM = fake
+	defer func() { services.LLM = nil }()
+
+	w := doRequest(router, "POST", "/books/summarize", token, request)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var first jobs.Job
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &first))
+
+	// 同一本书在任务结束前重复提交返回同一个任务
+	w = doRequest(router, "POST", "/books/summarize", token, request)
+	require.Equal(t, http.StatusAccepted, w.Code)
+	var second jobs.Job
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &second))
+	assert.Equal(t, first.ID, second.ID)
+
+	// 只有提交者可以查看任务
+	w = doRequest(router, "GET", "/jobs/"+first.ID, tokenFor(t, other), nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// 其他用户请求同一本书时提交自己的任务，可以查看
+	w = doRequest(router, "POST", "/books/summarize", tokenFor(t, other), request)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var others jobs.Job
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &others))
+	assert.NotEqual(t, first.ID, others.ID)
+	w = doRequest(router, "GET", "/jobs/"+others.ID, tokenFor(t, other), nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = doRequest(router, "GET", "/jobs/missing", token, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = doRequest(router, "POST", "/jobs/"+first.ID+"/retry", token, nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	close(release)
+	job := waitForJob(t, router, token, first.ID)
+	assert.Equal(t, jobs.StatusFailed, job.Status)
+	assert.Equal(t, "model unavailable", job.Error)
+
+	// 手动重试后成功
+	failing.Store(false)
+	w = doRequest(router, "POST", "/jobs/"+first.ID+"/retry", token, nil)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	job = waitForJob(t, router, token, first.ID)
+	require.Equal(t, jobs.StatusSucceeded, job.Status, job.Error)
+
+	// 摘要已保存后直接返回，不再提交任务
+	w = doRequest(router, "POST", "/books/summarize", token, request)
+	require.Equal(t, http.StatusOK, w.Code)
+	var cached services.BookSummary
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &cached))
+	assert.Equal(t, "三体", cached.Review.Title)
+	assert.Len(t, cached.Chapters, 1)
+}
+
+func TestSummaryJobVisibility(t *testing.T) {
+	router := setupTestEnv(t)
+	startJobWorkers(t, config.JobsConfig{Workers: 1, MaxAttempts: 1})
+	owner := createUserWithRole(t, "owner", models.RoleUploader)
+	other := createUserWithRole(t, "other", models.RoleReader)
+	ownerToken := tokenFor(t, owner)
+	otherToken := tokenFor(t, other)
+
+	book, _ := createBookWithChapters(t, "三体", owner, "叶文洁在红岸基地工作。")
+	request := map[string]string{"book_title": book.Title, "author": book.Author}
+
+	var failing atomic.Bool
+	failing.Store(true)
+	fake := newFakeSummaryLLM(0)
+	reply := fake.Reply
+	fake.Reply = func(req llm.Request) (string, error) {
+		if failing.Load() {
+			return "", errors.New("model unavailable")
+		}
+		return reply(req)
+	}
+	services.LLM = fake
+	defer func() { services.LLM = nil }()
+
+	job := submitSummary(t, router, otherToken, request)
+	require.Equal(t, jobs.StatusFailed, job.Status)
+
+	// 书籍设为私有后，所有者生成并保存了摘要
+	require.NoError(t, book.SetVisibility(models.VisibilityPrivate))
+	require.NoError(t, models.UpdateBook(database.MySQLDB, book))
+	failing.Store(false)
+	owned := submitSummary(t, router, ownerToken, request)
+	require.Equal(t, jobs.StatusSucceeded, owned.Status, owned.Error)
+
+	// 重试时重新检查可见性，不会返回已保存的摘要
+	w := doRequest(router, "POST", "/jobs/"+job.ID+"/retry", otherToken, nil)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	job = waitForJob(t, router, otherToken, job.ID)
+	assert.Equal(t, jobs.StatusFailed, job.Status)
+	assert.Equal(t, services.ErrSummaryBookUnavailable.Error(), job.Error)
+	assert.Nil(t, job.Result)
+}
+
+type sseEvent struct {
+	Event string
+	Data  string
+}
+
+// parseSSE 解析 Server-Sent Events 响应
+func parseSSE(body string) []sseEvent {
+	var events []sseEvent
+	for _, block := range strings.Split(body, "\n\n") {
+		var event sseEvent
+		for _, line := range strings.Split(block, "\n") {
+			if value, ok := strings.CutPrefix(line, "event:"); ok {
+				event.Event = value
+			} else if value, ok := strings.CutPrefix(line, "data:"); ok {
+				event.Data = value
+			}
+		}
+		if event.Event != "" {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func TestStreamSummary(t *testing.T) {
+	router := setupTestEnv(t)
+	owner := createUserWithRole(t, "owner", models.RoleUploader)
+	token := tokenFor(t, owner)
+
+	book, _ := createBookWithChapters(t, "三体", owner, "叶文洁在红岸基地工作。")
+	request := map[string]string{"book_title": book.Title, "author": book.Author}
+
+	services.LLM = nil
+	defer func() { services.LLM = nil }()
+	w := doRequest(router, "POST", "/books/summarize/stream", token, request)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	// 第一次输出的全书摘要不完整，重新生成
+	fake := newFakeSummaryLLM(0)
+	fake.ChunkSize = 5
+	reply := fake.Reply
+	var reviews atomic.Int32
+	fake.Reply = func(req llm.Request) (string, error) {
+		content, err := reply(req)
+		if content == fakeReviewJSON && reviews.Add(1) == 1 {
+			return `{"title": "三体", "characters": []}`, nil
+		}
+		return content, err
+	}
+	services.LLM = fake
+
+	w = doRequest(router, "POST", "/books/summarize/stream", token, request)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+
+	events := parseSSE(w.Body.String())
+	counts := map[string]int{}
+	var synopsis string
+	var character models.Character
+	var tokens strings.Builder
+	for _, event := range events {
+		counts[event.Event]++
+		switch event.Event {
+		case services.SummaryEventToken:
+			var data map[string]string
+			require.NoError(t, json.Unmarshal([]byte(event.Data), &data))
+			tokens.WriteString(data["text"])
+		case services.SummaryEventSynopsis:
+			var data map[string]string
+			require.NoError(t, json.Unmarshal([]byte(event.Data), &data))
+			synopsis = data["synopsis"]
+		case services.SummaryEventCharacter:
+			require.NoError(t, json.Unmarshal([]byte(event.Data), &character))
+		case services.SummaryEventRetry:
+			assert.Contains(t, event.Data, "$.synopsis: is required")
+		}
+	}
+	assert.Equal(t, 1, counts[services.SummaryEventProgress])
+	assert.Equal(t, 1, counts[services.SummaryEventRetry])
+	assert.Greater(t, counts[services.SummaryEventSynopsis], 1)
+	assert.Equal(t, 1, counts[services.SummaryEventCharacter])
+	assert.Equal(t, `{"title": "三体", "characters": []}`+fakeReviewJSON, tokens.String())
+	assert.Equal(t, "人类文明与三体文明的第一次接触。", synopsis)
+	assert.Equal(t, "叶文洁", character.Name)
+
+	last := events[len(events)-1]
+	require.Equal(t, services.SummaryEventSummary, last.Event)
+	var resp services.BookSummary
+	require.NoError(t, json.Unmarshal([]byte(last.Data), &resp))
+	assert.Equal(t, "三体", resp.Review.Title)
+	assert.Len(t, resp.Chapters, 1)
+
+	// 摘要已保存，再次请求时只发送 summary 事件
+	review, err := models.GetBookReview(database.MySQLDB, book.ID, "zh")
+	require.NoError(t, err)
+	assert.Equal(t, "叶文洁", review.ReviewData.Items[0].Characters[0].Name)
+
+	count := len(fake.Requests())
+	w = doRequest(router, "POST", "/books/summarize/stream", token, request)
+	events = parseSSE(w.Body.String())
+	require.Len(t, events, 1)
+	assert.Equal(t, services.SummaryEventSummary, events[0].Event)
+	assert.Len(t, fake.Requests(), count)
+}
+
+func TestSummaryLinkage(t *testing.T) {
+	router := setupTestEnv(t)
+	startJobWorkers(t, config.JobsConfig{Workers: 1})
+	owner := createUserWithRole(t, "owner", models.RoleUploader)
+	reader := createUserWithRole(t, "reader", models.RoleReader)
+	token := tokenFor(t, owner)
+
+	fake := newFakeSummaryLLM(0)
+	services.LLM = fake
+	defer func() { services.LLM = nil }()
+
+	// 同名的两本书各自保存摘要
+	first, _ := createBookWithChapters(t, "三体", owner, "叶文洁在红岸基地工作。")
+	second, _ := createBookWithChapters(t, "三体", owner, "汪淼看到了倒计时。")
+	require.NoError(t, database.MySQLDB.Model(second).Update("author", "刘慈欣").Error)
+	for _, book := range []*models.Book{first, second} {
+		job := submitSummary(t, router, token, map[string]interface{}{"book_id": book.ID})
+		require.Equal(t, jobs.StatusSucceeded, job.Status, job.Error)
+
+		review, err := models.GetBookReview(database.MySQLDB, book.ID, "zh")
+		require.NoError(t, err)
+		assert.EqualValues(t, owner.ID, review.UserID)
+		assert.Equal(t, llm.ProviderFake, review.Model)
+		assert.Equal(t, "book/zh-v1", review.PromptVersion)
+	}
+	w := doRequest(router, "POST", "/books/summarize", token, map[string]interface{}{"book_id": second.ID})
+	require.Equal(t, http.StatusOK, w.Code)
+	var summary services.BookSummary
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &summary))
+	assert.Equal(t, "刘慈欣", summary.Review.Author)
+	require.Len(t, summary.Chapters, 1)
+	assert.Equal(t, second.ID, summary.Chapters[0].BookID)
+	assert.Equal(t, "book/zh-v1", summary.PromptVersion)
+
+	// 只有书名时作者也是摘要的一部分
+	for _, author := range []string{"甲", "乙"} {
+		job := submitSummary(t, router, token, map[string]string{"book_title": "球状闪电", "author": author})
+		require.Equal(t, jobs.StatusSucceeded, job.Status, job.Error)
+		review, err := models.GetTitleReview(database.MySQLDB, "球状闪电", author, "zh")
+		require.NoError(t, err)
+		assert.Nil(t, review.BookID)
+		assert.Equal(t, "title/zh-v1", review.PromptVersion)
+		assert.Equal(t, author, review.ReviewData.Items[0].Author)
+	}
+
+	w = doRequest(router, "POST", "/books/summarize", token, map[string]interface{}{"book_id": 9999})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = doRequest(router, "POST", "/books/summarize", token, map[string]string{})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// 只有上传者或管理员可以重新生成和删除摘要
+	path := fmt.Sprintf("/books/%d/summary", first.ID)
+	w = doRequest(router, "POST", path+"/regenerate", tokenFor(t, reader), nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = doRequest(router, "DELETE", path, tokenFor(t, reader), nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	count := len(fake.Requests())
+	w = doRequest(router, "POST", path+"/regenerate", token, nil)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var job jobs.Job
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	job = waitForJob(t, router, token, job.ID)
+	require.Equal(t, jobs.StatusSucceeded, job.Status, job.Error)
+	assert.Greater(t, len(fake.Requests()), count)
+	var reviews int64
+	database.MySQLDB.Model(&models.Review{}).Where("book_id = ?", first.ID).Count(&reviews)
+	assert.EqualValues(t, 1, reviews)
+
+	w = doRequest(router, "DELETE", path, token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	_, err := models.GetBookReview(database.MySQLDB, first.ID, "zh")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	chapters, err := models.GetChapterSummaries(database.MySQLDB, first.ID)
+	require.NoError(t, err)
+	assert.Empty(t, chapters)
+	_, err = models.GetBookReview(database.MySQLDB, second.ID, "zh")
+	assert.NoError(t, err)
+
+	w = doRequest(router, "POST", "/books/summarize", token, map[string]interface{}{"book_id": first.ID})
+	assert.Equal(t, http.StatusAccepted, w.Code)
+}
+
+func TestSummaryLanguage(t *testing.T) {
+	router := setupTestEnv(t)
+	startJobWorkers(t, config.JobsConfig{Workers: 1})
+	owner := createUserWithRole(t, "owner", models.RoleUploader)
+	token := tokenFor(t, owner)
+
+	fake := newFakeSummaryLLM(0)
+	services.LLM = fake
+	defer func() { services.LLM = nil }()
+
+	// 没有设置语言的书根据章节内容判断，章节概要和全书摘要都使用英文模板
+	book, _ := createBookWithChapters(t, "Pride and Prejudice", owner,
+		"It is a truth universally acknowledged, that a single man in possession of a good fortune must be in want of a wife.")
+	job := submitSummary(t, router, token, map[string]interface{}{"book_id": book.ID})
+	require.Equal(t, jobs.StatusSucceeded, job.Status, job.Error)
+	var summary services.BookSummary
+	require.NoError(t, json.Unmarshal(job.Result, &summary))
+	assert.Equal(t, "en", summary.Language)
+	assert.Equal(t, "book/en-v1", summary.PromptVersion)
+	requests := fake.Requests()
+	assert.Contains(t, requests[0].Messages[0].Content, `the chapter "第1章" from "Pride and Prejudice"`)
+	assert.Contains(t, requests[len(requests)-1].Messages[0].Content, "writing all text in English")
+
+	// 指定其他语言时另外生成并保存，章节概要仍然使用书籍的语言
+	job = submitSummary(t, router, token, map[string]interface{}{"book_id": book.ID, "language": "zh-CN"})
+	require.Equal(t, jobs.StatusSucceeded, job.Status, job.Error)
+	require.NoError(t, json.Unmarshal(job.Result, &summary))
+	assert.Equal(t, "zh", summary.Language)
+	assert.Equal(t, "book/zh-v1", summary.PromptVersion)
+	requests = fake.Requests()
+	assert.Contains(t, requests[len(requests)-1].Messages[0].Content, "所有内容使用中文")
+
+	for _, language := range []string{"en", "zh"} {
+		review, err := models.GetBookReview(database.MySQLDB, book.ID, language)
+		require.NoError(t, err)
+		assert.Equal(t, language, review.Language)
+	}
+	w := doRequest(router, "POST", "/books/summarize", token, map[string]interface{}{"book_id": book.ID, "language": "en"})
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &summary))
+	assert.Equal(t, "en", summary.Language)
+
+	// 书籍设置的语言优先于根据内容判断的语言
+	require.NoError(t, database.MySQLDB.Model(book).Update("language", "zh").Error)
+	w = doRequest(router, "POST", "/books/summarize", token, map[string]interface{}{"book_id": book.ID})
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &summary))
+	assert.Equal(t, "zh", summary.Language)
+
+	w = doRequest(router, "POST", "/books/summarize", token, map[string]interface{}{"book_id": book.ID, "language": "fr"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = doRequest(router, "POST", fmt.Sprintf("/books/%d/summary/regenerate?language=fr", book.ID), token, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
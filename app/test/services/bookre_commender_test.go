@@ -84,7 +84,7 @@ func TestRecommendBooks(t *testing.T) {
 			assert.Len(t, recommendations, tc.expectedLength)
 			// 验证推荐结果按相似度降序排列
 			for i := 1; i < len(recommendations); i++ {
-				t.Logf("Rank %d: %s (%f) ", i-1, recommendations[i-1].Title, recommendations[i-1].Score)
+				t.Logf("Rank %d: %s (%f) ", i-1, recommendations[i-1].Book.Title, recommendations[i-1].Score)
 				assert.GreaterOrEqual(t, recommendations[i-1].Score, recommendations[i].Score)
 			}
 		})
@@ -265,9 +265,49 @@ func GetEpubMetadata(epubPath string) (map[string]string, error) {
 		metadata[k] = strings.TrimRight(v, "\x00")
 	}
 
+	// go-fitz 不提供语言，从 OPF 的 dc:language 读取
+	if language := readEpubLanguage(epubPath); language != "" {
+		metadata["language"] = language
+	}
+
 	return metadata, nil
 }
 
+// readEpubLanguage 读取 OPF 中的第一个 dc:language，读取失败时返回空
+func readEpubLanguage(epubPath string) string {
+	zipReader, err := zip.OpenReader(epubPath)
+	if err != nil {
+		return ""
+	}
+	defer zipReader.Close()
+
+	containerFile, err := findFileInZip(&zipReader.Reader, "META-INF/container.xml")
+	if err != nil {
+		return ""
+	}
+	defer containerFile.Close()
+	container, err := readContainer(containerFile)
+	if err != nil || len(container.RootFiles) == 0 {
+		return ""
+	}
+
+	opfFile, err := findFileInZip(&zipReader.Reader, container.RootFiles[0].FullPath)
+	if err != nil {
+		return ""
+	}
+	defer opfFile.Close()
+	var pkg Package
+	if err := xml.NewDecoder(opfFile).Decode(&pkg); err != nil {
+		return ""
+	}
+	for _, language := range pkg.Metadata.Language {
+		if language = strings.TrimSpace(language); language != "" {
+			return language
+		}
+	}
+	return ""
+}
+
 // Helper functions
 func findFileInZip(reader *zip.Reader, path string) (io.ReadCloser, error) {
 	for _, f := range reader.File {
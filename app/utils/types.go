@@ -84,7 +84,8 @@ type RootFile struct {
 }
 
 type Metadata struct {
-	Meta []Meta `xml:"meta"`
+	Meta     []Meta   `xml:"meta"`
+	Language []string `xml:"language"`
 }
 
 type Meta struct {
@@ -0,0 +1,13 @@
+-- 回填早期的摘要，升级到按书籍和语言保存摘要的版本后执行一次。
+-- 早期的摘要没有记录模型，且不一定对应真实的书籍：改为按书名和作者保存的中文摘要，
+-- 模型记为 legacy，需要时可以通过重新生成替换。
+USE bookdb;
+
+UPDATE reviews
+SET book_id = NULL,
+    title = COALESCE(JSON_UNQUOTE(JSON_EXTRACT(review_data, '$[0].title')), ''),
+    author = COALESCE(JSON_UNQUOTE(JSON_EXTRACT(review_data, '$[0].author')), ''),
+    language = 'zh',
+    model = 'legacy',
+    prompt_version = 'legacy'
+WHERE model = '';
@@ -1,3 +1,6 @@
+-- 只用于创建数据库和早期的基础表。表结构以 app/models/migrate.go 中的 models.Migrate 为准：
+-- 服务启动时会通过 AutoMigrate 创建之后新增的表（阅读设备同步状态、书架、书签和高亮、评分、章节概要等）
+-- 并补齐新增的列和索引，修改表结构时不需要同步修改本文件。
 CREATE DATABASE IF NOT EXISTS bookdb;
 USE bookdb;
 
@@ -7,6 +10,7 @@ CREATE TABLE IF NOT EXISTS users (
     username VARCHAR(50) UNIQUE NOT NULL,
     email VARCHAR(100) UNIQUE NOT NULL,
     password VARCHAR(255) NOT NULL,
+    role VARCHAR(20) NOT NULL DEFAULT 'reader',
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     INDEX (username),
@@ -23,11 +27,19 @@ CREATE TABLE IF NOT EXISTS books (
     cover_url VARCHAR(255),
     format VARCHAR(50),
     tags JSON,
+    language VARCHAR(20),
     score FLOAT,
+    owner_id BIGINT UNSIGNED,
+    visibility VARCHAR(20) NOT NULL DEFAULT 'public',
+    share_token VARCHAR(64),
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (owner_id) REFERENCES users(id),
     INDEX (title),
-    INDEX (author)
+    INDEX (author),
+    INDEX (owner_id),
+    INDEX (visibility),
+    INDEX (share_token)
 );
 
 -- 阅读进度表
@@ -35,13 +47,21 @@ CREATE TABLE IF NOT EXISTS reading_progress (
     id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
     user_id BIGINT UNSIGNED,
     book_id BIGINT UNSIGNED,
-    progress INT DEFAULT 0,
+    chapter_id BIGINT UNSIGNED COMMENT '当前章节，对应 book_chapters.id',
+    char_offset INT DEFAULT 0 COMMENT '章节内的字符偏移',
+    cfi VARCHAR(255) COMMENT 'EPUB CFI',
+    percentage DOUBLE DEFAULT 0,
+    last_read_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    version BIGINT NOT NULL DEFAULT 0 COMMENT '每次位置变化加一，用于多设备同步',
+    device_id VARCHAR(100) COMMENT '写入当前位置的设备',
+    device_updated_at TIMESTAMP NULL COMMENT '设备本地记录该位置的时间',
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     FOREIGN KEY (user_id) REFERENCES users(id),
     FOREIGN KEY (book_id) REFERENCES books(id),
-    INDEX (user_id),
-    INDEX (book_id)
+    UNIQUE INDEX idx_reading_progress_user_book (user_id, book_id),
+    INDEX (book_id),
+    INDEX (last_read_at)
 );
 
 -- 收藏书籍表
@@ -53,7 +73,7 @@ CREATE TABLE IF NOT EXISTS favorite_books (
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     FOREIGN KEY (user_id) REFERENCES users(id),
     FOREIGN KEY (book_id) REFERENCES books(id),
-    INDEX (user_id),
+    UNIQUE INDEX idx_favorite_user_book (user_id, book_id),
     INDEX (book_id)
 );
 
@@ -62,13 +82,19 @@ CREATE TABLE IF NOT EXISTS reviews (
     id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
     user_id BIGINT UNSIGNED,
     book_id BIGINT UNSIGNED,
+    title VARCHAR(255) NOT NULL DEFAULT '',
+    author VARCHAR(255) NOT NULL DEFAULT '',
+    language VARCHAR(20) NOT NULL DEFAULT 'zh',
+    model VARCHAR(100) NOT NULL DEFAULT '',
+    prompt_version VARCHAR(50) NOT NULL DEFAULT '',
     review_data JSON NOT NULL,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     FOREIGN KEY (user_id) REFERENCES users(id),
     FOREIGN KEY (book_id) REFERENCES books(id),
     INDEX (user_id),
-    INDEX (book_id)
+    INDEX (book_id),
+    INDEX idx_reviews_title_author (title, author)
 );
 
 -- epub章节结构表